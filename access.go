@@ -15,17 +15,27 @@ var _ Controller = &Client{}
 // Client is the main access control client for permission checking and user management.
 type Client struct {
 	userManager *userManager
+	manager     UserManager
+	actor       ActorFunc
 }
 
-// New creates a new Client with specified domains and adapter. Errors if user manager initialization fails.
-func New(domains Domains, adapter Adapter) (*Client, error) {
-	userManager, err := newUserManager(domains, adapter)
+// New creates a new Client with specified domains, adapter and options. Errors if user manager initialization fails.
+func New(domains Domains, adapter Adapter, opts ...Option) (*Client, error) {
+	o := newOptions(opts...)
+	userManager, err := newUserManager(domains, adapter, o)
 	if err != nil {
 		return nil, errors.Wrap(err, "newUserManager()")
 	}
 
+	var manager UserManager = userManager
+	if o.auditSink != nil {
+		manager = NewAuditedUserManager(userManager, o.auditSink, o.auditOptions...)
+	}
+
 	return &Client{
 		userManager: userManager,
+		manager:     manager,
+		actor:       o.actor,
 	}, nil
 }
 
@@ -39,25 +49,56 @@ func (c *Client) RequireAll(ctx context.Context, username accesstypes.User, doma
 	ctx, span := tracer.Start(ctx)
 	defer span.End()
 
-	if exists, err := c.userManager.DomainExists(ctx, domain); err != nil {
+	missing, err := c.missingPermissions(ctx, username, domain, perms...)
+	if err != nil {
 		return err
-	} else if !exists {
-		return httpio.NewBadRequestMessage("Invalid Domain")
 	}
 
-	for _, perm := range perms {
-		authorized, err := c.userManager.Enforcer().Enforce(username.Marshal(), domain.Marshal(), accesstypes.GlobalResource.Marshal(), perm.Marshal())
-		if err != nil {
-			return errors.Wrap(err, "casbin.IEnforcer Enforce()")
-		}
-		if !authorized {
-			return httpio.NewForbiddenMessagef("user %s does not have %s", username, perm)
-		}
+	if len(missing) > 0 {
+		return httpio.NewForbiddenMessagef("user %s does not have %s", username, missing[0])
 	}
 
 	return nil
 }
 
+// HasAll checks if user has all permissions in domain.
+// Returns ok=true if the user has them all, ok=false with the missing permissions otherwise. Errors if domain is invalid
+// or the policy can't be loaded or enforced.
+func (c *Client) HasAll(
+	ctx context.Context, username accesstypes.User, domain accesstypes.Domain, perms ...accesstypes.Permission,
+) (bool, []accesstypes.Permission, error) {
+	ctx, span := tracer.Start(ctx)
+	defer span.End()
+
+	missing, err := c.missingPermissions(ctx, username, domain, perms...)
+	if err != nil {
+		return false, nil, err
+	}
+
+	if len(missing) > 0 {
+		return false, missing, nil
+	}
+
+	return true, nil, nil
+}
+
+// HasAny checks if user has at least one of permissions in domain.
+// Returns ok=true with the permissions the user lacks if any is held, ok=false with all permissions otherwise.
+// Errors if domain is invalid or the policy can't be loaded or enforced.
+func (c *Client) HasAny(
+	ctx context.Context, username accesstypes.User, domain accesstypes.Domain, perms ...accesstypes.Permission,
+) (bool, []accesstypes.Permission, error) {
+	ctx, span := tracer.Start(ctx)
+	defer span.End()
+
+	missing, err := c.missingPermissions(ctx, username, domain, perms...)
+	if err != nil {
+		return false, nil, err
+	}
+
+	return len(missing) < len(perms), missing, nil
+}
+
 // RequireResources checks if user has permission for resources in domain.
 // Returns ok=true if all accessible, ok=false with missing resources otherwise. Errors if domain invalid.
 func (c *Client) RequireResources(
@@ -82,7 +123,7 @@ func (c *Client) RoleRequireResources(
 
 // UserManager returns the UserManager for managing users, roles, and permissions.
 func (c *Client) UserManager() UserManager {
-	return c.userManager
+	return c.manager
 }
 
 func (c *Client) requireResources(
@@ -97,9 +138,14 @@ func (c *Client) requireResources(
 		return false, nil, httpio.NewBadRequestMessage("Invalid Domain")
 	}
 
+	enforcer, err := c.userManager.Enforcer()
+	if err != nil {
+		return false, nil, errors.Wrap(err, "userManager.Enforcer()")
+	}
+
 	missing := make([]accesstypes.Resource, 0)
 	for _, resource := range resources {
-		authorized, err := c.userManager.Enforcer().Enforce(subject, domain.Marshal(), resource.Marshal(), perm.Marshal())
+		authorized, err := enforcer.Enforce(subject, domain.Marshal(), resource.Marshal(), perm.Marshal())
 		if err != nil {
 			return false, nil, errors.Wrap(err, "casbin.IEnforcer Enforce()")
 		}
@@ -114,3 +160,34 @@ func (c *Client) requireResources(
 
 	return true, nil, nil
 }
+
+func (c *Client) missingPermissions(
+	ctx context.Context, username accesstypes.User, domain accesstypes.Domain, perms ...accesstypes.Permission,
+) ([]accesstypes.Permission, error) {
+	ctx, span := tracer.Start(ctx)
+	defer span.End()
+
+	if exists, err := c.userManager.DomainExists(ctx, domain); err != nil {
+		return nil, err
+	} else if !exists {
+		return nil, httpio.NewBadRequestMessage("Invalid Domain")
+	}
+
+	enforcer, err := c.userManager.Enforcer()
+	if err != nil {
+		return nil, errors.Wrap(err, "userManager.Enforcer()")
+	}
+
+	var missing []accesstypes.Permission
+	for _, perm := range perms {
+		authorized, err := enforcer.Enforce(username.Marshal(), domain.Marshal(), accesstypes.GlobalResource.Marshal(), perm.Marshal())
+		if err != nil {
+			return nil, errors.Wrap(err, "casbin.IEnforcer Enforce()")
+		}
+		if !authorized {
+			missing = append(missing, perm)
+		}
+	}
+
+	return missing, nil
+}
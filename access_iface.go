@@ -2,6 +2,7 @@ package access
 
 import (
 	"context"
+	"time"
 
 	"github.com/cccteam/ccc/accesstypes"
 )
@@ -13,6 +14,20 @@ type Controller interface {
 	// RequireAll checks if user has all specified permissions in domain.
 	RequireAll(ctx context.Context, user accesstypes.User, domain accesstypes.Domain, permissions ...accesstypes.Permission) error
 
+	// HasAll checks if user has all specified permissions in domain.
+	// Returns ok=true if all are held, ok=false with missing permissions otherwise. Errors if domain is invalid or the
+	// policy can't be loaded or enforced.
+	HasAll(
+		ctx context.Context, user accesstypes.User, domain accesstypes.Domain, permissions ...accesstypes.Permission,
+	) (ok bool, missing []accesstypes.Permission, err error)
+
+	// HasAny checks if user has at least one of the specified permissions in domain.
+	// Returns ok=true if any is held, along with the missing permissions. Errors if domain is invalid or the policy
+	// can't be loaded or enforced.
+	HasAny(
+		ctx context.Context, user accesstypes.User, domain accesstypes.Domain, permissions ...accesstypes.Permission,
+	) (ok bool, missing []accesstypes.Permission, err error)
+
 	// RequireResources checks if user has permission for resources in domain.
 	// Returns ok=true if all resources are accessible, ok=false with missing resources otherwise.
 	RequireResources(
@@ -25,6 +40,15 @@ type Controller interface {
 		ctx context.Context, role accesstypes.Role, domain accesstypes.Domain, perm accesstypes.Permission, resources ...accesstypes.Resource,
 	) (ok bool, missing []accesstypes.Resource, err error)
 
+	// CheckMany makes many permission checks and returns a result for each request in the same order.
+	// Requests with an invalid domain get an error in their result instead of failing the call.
+	CheckMany(ctx context.Context, requests []CheckRequest) ([]CheckResult, error)
+
+	// Explain checks if user has perm on resource in domain and reports the roles and policies that decided it.
+	Explain(
+		ctx context.Context, user accesstypes.User, domain accesstypes.Domain, perm accesstypes.Permission, resource accesstypes.Resource,
+	) (*Explanation, error)
+
 	// UserManager returns the UserManager for managing users, roles, and permissions.
 	UserManager() UserManager
 
@@ -39,12 +63,19 @@ type UserManager interface {
 	// AddRoleUsers assigns role to users in domain. Errors if role doesn't exist.
 	AddRoleUsers(ctx context.Context, domain accesstypes.Domain, role accesstypes.Role, users ...accesstypes.User) error
 
+	// AddRoleUsersUntil assigns role to users in domain until expiresAt, replacing existing time-bound assignments.
+	// Errors if role doesn't exist, expiresAt isn't in the future or a user already has role permanently.
+	AddRoleUsersUntil(ctx context.Context, domain accesstypes.Domain, role accesstypes.Role, expiresAt time.Time, users ...accesstypes.User) error
+
 	// AddUserRoles assigns roles to user in domain. Errors if any role doesn't exist.
 	AddUserRoles(ctx context.Context, domain accesstypes.Domain, user accesstypes.User, roles ...accesstypes.Role) error
 
 	// DeleteRoleUsers removes users from role in domain. Errors if role doesn't exist.
 	DeleteRoleUsers(ctx context.Context, domain accesstypes.Domain, role accesstypes.Role, users ...accesstypes.User) error
 
+	// DeleteExpiredRoleUsers deletes expired role assignments from storage and returns the ones deleted.
+	DeleteExpiredRoleUsers(ctx context.Context) ([]RoleAssignment, error)
+
 	// DeleteUserRoles removes role assignments from user in domain.
 	DeleteUserRoles(ctx context.Context, domain accesstypes.Domain, user accesstypes.User, roles ...accesstypes.Role) error
 
@@ -54,10 +85,13 @@ type UserManager interface {
 	// Users returns all users with roles and permissions. If domains unspecified, returns all domains.
 	Users(ctx context.Context, domain ...accesstypes.Domain) ([]*UserAccess, error)
 
-	// UserRoles returns user's roles. If domains unspecified, returns all domains.
+	// UserRoles returns user's roles. If domains unspecified, returns all domains. With WithGlobalDomainGrants, roles
+	// assigned in the global domain are included in every domain.
 	UserRoles(ctx context.Context, user accesstypes.User, domain ...accesstypes.Domain) (accesstypes.RoleCollection, error)
 
-	// UserPermissions returns user's effective permissions. If domains unspecified, returns all domains.
+	// UserPermissions returns user's effective permissions, including permissions inherited through parent roles and excluding
+	// denied permissions. If domains unspecified, returns all domains. With WithGlobalDomainGrants, includes the permissions
+	// the user's global domain roles have in each domain.
 	UserPermissions(ctx context.Context, user accesstypes.User, domain ...accesstypes.Domain) (accesstypes.UserPermissionCollection, error)
 
 	// AddRole creates role in domain. Errors if domain doesn't exist or role already exists.
@@ -65,15 +99,37 @@ type UserManager interface {
 	// Note: Adds internal "noop" user to role for casbin enumeration.
 	AddRole(ctx context.Context, domain accesstypes.Domain, role accesstypes.Role) error
 
-	// RoleExists returns true if role exists in domain.
-	RoleExists(ctx context.Context, domain accesstypes.Domain, role accesstypes.Role) bool
+	// RoleExists returns true if role exists in domain. Errors if the policy cannot be loaded.
+	RoleExists(ctx context.Context, domain accesstypes.Domain, role accesstypes.Role) (bool, error)
 
 	// Roles returns all roles in domain. Errors if domain doesn't exist.
 	Roles(ctx context.Context, domain accesstypes.Domain) ([]accesstypes.Role, error)
 
-	// DeleteRole removes role from domain. Returns false with error if role has users assigned.
+	// Apply applies changes in order as a single unit, holding off other writes until it is done. If a write fails, the
+	// writes already made are rolled back. Errors if any change is invalid, references a role that doesn't exist or
+	// leaves a role inheriting itself, in which case nothing is written.
+	Apply(ctx context.Context, changes ...Change) error
+
+	// DeleteRole removes role, its permissions and its parent and child role links from domain, leaving the role in
+	// other domains untouched.
+	// Returns false with error if role has users assigned in domain.
 	DeleteRole(ctx context.Context, domain accesstypes.Domain, role accesstypes.Role) (bool, error)
 
+	// DeleteRoleAllDomains removes role and its permissions from every domain.
+	// Returns false with error if role has users assigned in any domain.
+	DeleteRoleAllDomains(ctx context.Context, role accesstypes.Role) (bool, error)
+
+	// AddRoleParents makes role inherit the permissions and denials of parents in domain. Users of role are granted
+	// everything granted to its parents and their parents. Errors if role or any parent doesn't exist, or if a parent
+	// already inherits role, which would create a cycle.
+	AddRoleParents(ctx context.Context, domain accesstypes.Domain, role accesstypes.Role, parents ...accesstypes.Role) error
+
+	// DeleteRoleParents stops role inheriting parents in domain. Errors if role doesn't exist.
+	DeleteRoleParents(ctx context.Context, domain accesstypes.Domain, role accesstypes.Role, parents ...accesstypes.Role) error
+
+	// RoleParents returns the roles that role directly inherits in domain. Errors if role doesn't exist.
+	RoleParents(ctx context.Context, domain accesstypes.Domain, role accesstypes.Role) ([]accesstypes.Role, error)
+
 	// AddRolePermissions grants global permissions to role in domain. Errors if role doesn't exist.
 	AddRolePermissions(ctx context.Context, domain accesstypes.Domain, role accesstypes.Role, permissions ...accesstypes.Permission) error
 
@@ -86,15 +142,33 @@ type UserManager interface {
 	// DeleteRolePermissionResources removes resource-specific permissions from role in domain. Errors if role doesn't exist.
 	DeleteRolePermissionResources(ctx context.Context, domain accesstypes.Domain, role accesstypes.Role, permission accesstypes.Permission, resources ...accesstypes.Resource) error
 
-	// DeleteAllRolePermissions removes all permissions from role in domain.
+	// AddRolePermissionDenials denies global permissions to role in domain. Denials take precedence over any role
+	// that allows the same permission. Errors if role doesn't exist.
+	AddRolePermissionDenials(ctx context.Context, domain accesstypes.Domain, role accesstypes.Role, permissions ...accesstypes.Permission) error
+
+	// AddRolePermissionResourceDenials denies resource-specific permissions to role in domain. Denials take precedence
+	// over any role that allows the same permission. Errors if role doesn't exist.
+	AddRolePermissionResourceDenials(ctx context.Context, domain accesstypes.Domain, role accesstypes.Role, permission accesstypes.Permission, resources ...accesstypes.Resource) error
+
+	// DeleteRolePermissionDenials removes global permission denials from role in domain. Errors if role doesn't exist.
+	DeleteRolePermissionDenials(ctx context.Context, domain accesstypes.Domain, role accesstypes.Role, permissions ...accesstypes.Permission) error
+
+	// DeleteRolePermissionResourceDenials removes resource-specific permission denials from role in domain. Errors if role doesn't exist.
+	DeleteRolePermissionResourceDenials(ctx context.Context, domain accesstypes.Domain, role accesstypes.Role, permission accesstypes.Permission, resources ...accesstypes.Resource) error
+
+	// DeleteAllRolePermissions removes all permissions and permission denials from role in domain, global and
+	// resource-specific.
 	DeleteAllRolePermissions(ctx context.Context, domain accesstypes.Domain, role accesstypes.Role) error
 
-	// RoleUsers returns users assigned to role in domain. Excludes internal "noop" user.
+	// RoleUsers returns users assigned to role in domain. Excludes internal "noop" user and roles that inherit role.
 	RoleUsers(ctx context.Context, domain accesstypes.Domain, role accesstypes.Role) ([]accesstypes.User, error)
 
-	// RolePermissions returns permissions for role in domain as map of permissions to resources. Errors if role doesn't exist.
+	// RolePermissions returns allowed permissions for role in domain as map of permissions to resources. Errors if role doesn't exist.
 	RolePermissions(ctx context.Context, domain accesstypes.Domain, role accesstypes.Role) (accesstypes.RolePermissionCollection, error)
 
+	// RolePermissionDenials returns denied permissions for role in domain as map of permissions to resources. Errors if role doesn't exist.
+	RolePermissionDenials(ctx context.Context, domain accesstypes.Domain, role accesstypes.Role) (accesstypes.RolePermissionCollection, error)
+
 	// Domains returns all domains including global domain.
 	Domains(ctx context.Context) ([]accesstypes.Domain, error)
 
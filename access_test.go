@@ -1,7 +1,15 @@
 package access
 
 import (
+	"context"
+	"sync"
 	"testing"
+
+	"github.com/casbin/casbin/v2/persist"
+	fileadapter "github.com/casbin/casbin/v2/persist/file-adapter"
+	"github.com/cccteam/ccc/accesstypes"
+	"github.com/google/go-cmp/cmp"
+	"go.uber.org/mock/gomock"
 )
 
 func TestNew(t *testing.T) {
@@ -38,3 +46,145 @@ func TestNew(t *testing.T) {
 		})
 	}
 }
+
+type fileAdapter string
+
+func (f fileAdapter) NewAdapter() (persist.Adapter, error) {
+	return fileadapter.NewAdapter(string(f)), nil
+}
+
+type fakeWatcher struct {
+	mu       sync.Mutex
+	updates  int
+	callback func(string)
+}
+
+func (w *fakeWatcher) SetUpdateCallback(callback func(string)) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.callback = callback
+
+	return nil
+}
+
+func (w *fakeWatcher) Update() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.updates++
+
+	return nil
+}
+
+func (w *fakeWatcher) Close() {}
+
+func TestNew_WithWatcher(t *testing.T) {
+	t.Parallel()
+
+	watcher := &fakeWatcher{}
+	got, err := New(&MockDomains{}, fileAdapter("testdata/policy.csv"), WithWatcher(watcher))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	enforcer, err := got.userManager.Enforcer()
+	if err != nil {
+		t.Fatalf("userManager.Enforcer() error = %v", err)
+	}
+	if _, err := enforcer.AddPolicy("role:Viewer", "domain:tenant1", "resource:global", "perm:ViewUsers", "allow"); err != nil {
+		t.Fatalf("casbin.IEnforcer.AddPolicy() error = %v", err)
+	}
+	if watcher.updates != 1 {
+		t.Errorf("Watcher.Update() calls = %d, want 1", watcher.updates)
+	}
+
+	if !got.userManager.policyLoaded {
+		t.Fatal("policyLoaded = false, want true")
+	}
+	watcher.callback("")
+	if got.userManager.policyLoaded {
+		t.Error("policyLoaded = true after watcher callback, want false")
+	}
+}
+
+func TestClient_HasAllHasAny(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		user        accesstypes.User
+		domain      accesstypes.Domain
+		perms       []accesstypes.Permission
+		wantAll     bool
+		wantAny     bool
+		wantMissing []accesstypes.Permission
+		wantErr     bool
+	}{
+		{
+			name:    "has every permission",
+			user:    "alice",
+			domain:  "tenant1",
+			perms:   []accesstypes.Permission{"ViewUsers", "DeleteUsers"},
+			wantAll: true,
+			wantAny: true,
+		},
+		{
+			name:        "has some permissions",
+			user:        "carol",
+			domain:      "tenant1",
+			perms:       []accesstypes.Permission{"ViewUsers", "DeleteUsers"},
+			wantAny:     true,
+			wantMissing: []accesstypes.Permission{"DeleteUsers"},
+		},
+		{
+			name:        "has no permissions",
+			user:        "dave",
+			domain:      "tenant1",
+			perms:       []accesstypes.Permission{"ViewUsers", "DeleteUsers"},
+			wantMissing: []accesstypes.Permission{"ViewUsers", "DeleteUsers"},
+		},
+		{
+			name:    "invalid domain",
+			user:    "alice",
+			domain:  "tenant2",
+			perms:   []accesstypes.Permission{"ViewUsers"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			domains := NewMockDomains(gomock.NewController(t))
+			domains.EXPECT().DomainExists(gomock.Any(), string(tt.domain)).Return(tt.domain == "tenant1", nil).Times(2)
+
+			client, err := New(domains, fileAdapter("testdata/policy_inheritance.csv"))
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+
+			gotAll, missing, err := client.HasAll(context.Background(), tt.user, tt.domain, tt.perms...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Client.HasAll() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if gotAll != tt.wantAll {
+				t.Errorf("Client.HasAll() ok = %v, want %v", gotAll, tt.wantAll)
+			}
+			if diff := cmp.Diff(tt.wantMissing, missing); diff != "" {
+				t.Errorf("Client.HasAll() missing mismatch (-want +got):\n%s", diff)
+			}
+
+			gotAny, missing, err := client.HasAny(context.Background(), tt.user, tt.domain, tt.perms...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Client.HasAny() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if gotAny != tt.wantAny {
+				t.Errorf("Client.HasAny() ok = %v, want %v", gotAny, tt.wantAny)
+			}
+			if diff := cmp.Diff(tt.wantMissing, missing); diff != "" {
+				t.Errorf("Client.HasAny() missing mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package access
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/cccteam/ccc/accesstypes"
+	"github.com/cccteam/httpio"
+)
+
+type actorKey struct{}
+
+// WithActor returns a copy of ctx carrying actor, the principal making changes through UserManager.
+func WithActor(ctx context.Context, actor accesstypes.User) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFrom returns the actor set on ctx with WithActor, and false if there is none.
+func ActorFrom(ctx context.Context) (accesstypes.User, bool) {
+	actor, ok := ctx.Value(actorKey{}).(accesstypes.User)
+
+	return actor, ok
+}
+
+// ActorFunc returns the principal making a request to the Handlers. Errors are written to the client using httpio.
+type ActorFunc func(r *http.Request) (accesstypes.User, error)
+
+// actorHandler wraps logHandler so handlers run with the actor returned by actor set on the request context.
+func actorHandler(logHandler LogHandler, actor ActorFunc) LogHandler {
+	if actor == nil {
+		return logHandler
+	}
+
+	return func(handler func(w http.ResponseWriter, r *http.Request) error) http.HandlerFunc {
+		return logHandler(func(w http.ResponseWriter, r *http.Request) error {
+			user, err := actor(r)
+			if err != nil {
+				return httpio.NewEncoder(w).ClientMessage(r.Context(), err)
+			}
+
+			return handler(w, r.WithContext(WithActor(r.Context(), user)))
+		})
+	}
+}
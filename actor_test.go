@@ -0,0 +1,97 @@
+package access
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cccteam/ccc/accesstypes"
+	"github.com/cccteam/httpio"
+)
+
+func TestActorFrom(t *testing.T) {
+	t.Parallel()
+
+	if actor, ok := ActorFrom(context.Background()); ok {
+		t.Errorf("ActorFrom() = %q, true, want no actor", actor)
+	}
+
+	actor, ok := ActorFrom(WithActor(context.Background(), "admin"))
+	if !ok || actor != "admin" {
+		t.Errorf("ActorFrom() = %q, %v, want admin, true", actor, ok)
+	}
+}
+
+func TestClient_Handlers_actor(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		header     string
+		wantCode   int
+		wantActors []string
+	}{
+		{
+			name:       "sets the actor for the change",
+			header:     "admin",
+			wantCode:   http.StatusOK,
+			wantActors: []string{"admin"},
+		},
+		{
+			name:     "rejects requests without an actor",
+			wantCode: http.StatusUnauthorized,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			sink := NewMemoryAuditSink()
+			client, err := New(tenant1Domains(t), fileAdapter("testdata/policy_inheritance.csv"),
+				WithAuditSink(sink),
+				WithActorExtractor(func(r *http.Request) (accesstypes.User, error) {
+					user := r.Header.Get("X-User")
+					if user == "" {
+						return "", httpio.NewUnauthorizedMessage("no user")
+					}
+
+					return accesstypes.User(user), nil
+				}),
+			)
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+
+			handlers := client.Handlers(func(handler func(w http.ResponseWriter, r *http.Request) error) http.HandlerFunc {
+				return func(w http.ResponseWriter, r *http.Request) {
+					if err := handler(w, r); err != nil {
+						_ = httpio.NewEncoder(w).ClientMessage(r.Context(), err)
+					}
+				}
+			})
+
+			req, err := createHTTPRequest(http.MethodPost, strings.NewReader(`{"roleName": "Auditor2"}`), map[httpio.ParamType]string{paramDomain: "tenant1"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("X-User", tt.header)
+
+			rr := httptest.NewRecorder()
+			httpio.WithParams(handlers.AddRole()).ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantCode {
+				t.Fatalf("Handlers.AddRole() code = %d, want %d: %s", rr.Code, tt.wantCode, rr.Body.String())
+			}
+
+			var actors []string
+			for _, event := range sink.Events() {
+				actors = append(actors, event.Actor)
+			}
+			if len(actors) != len(tt.wantActors) || (len(actors) > 0 && actors[0] != tt.wantActors[0]) {
+				t.Errorf("AuditEvent actors = %v, want %v", actors, tt.wantActors)
+			}
+		})
+	}
+}
@@ -1,10 +1,15 @@
 package access
 
 import (
+	"context"
+	"fmt"
+	"strings"
+
 	"github.com/casbin/casbin/v2/persist"
 	spanneradapter "github.com/flowerinthenight/casbin-spanner-adapter"
 	"github.com/go-playground/errors/v5"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 	pgxadapter "github.com/pckhoi/casbin-pgx-adapter/v3"
 )
 
@@ -17,7 +22,9 @@ type Adapter interface {
 // PostgresAdapter provides PostgreSQL persistence for casbin policies.
 type PostgresAdapter struct {
 	connConfig   *pgx.ConnConfig
+	pool         *pgxpool.Pool
 	databaseName string
+	schemaName   string
 	tableName    string
 }
 
@@ -30,8 +37,23 @@ func NewPostgresAdapter(connConfig *pgx.ConnConfig, databaseName, tableName stri
 	}
 }
 
-// NewAdapter creates PostgreSQL casbin adapter.
+// NewPostgresPoolAdapter creates PostgreSQL adapter for storing casbin policies in tableName in schemaName using an
+// existing pool, so policy queries share its configuration, limits and tracing. An empty schemaName uses the search
+// path. The pool is not closed by the adapter.
+func NewPostgresPoolAdapter(pool *pgxpool.Pool, schemaName, tableName string) *PostgresAdapter {
+	return &PostgresAdapter{
+		pool:       pool,
+		schemaName: schemaName,
+		tableName:  tableName,
+	}
+}
+
+// NewAdapter creates PostgreSQL casbin adapter. Creates the policy table if it doesn't exist.
 func (p *PostgresAdapter) NewAdapter() (persist.Adapter, error) {
+	if p.pool != nil {
+		return p.newPoolAdapter()
+	}
+
 	a, err := pgxadapter.NewAdapter(p.connConfig, pgxadapter.WithDatabase(p.databaseName), pgxadapter.WithTableName(p.tableName))
 	if err != nil {
 		return nil, errors.Wrap(err, "pgxadapter.NewAdapter()")
@@ -40,6 +62,64 @@ func (p *PostgresAdapter) NewAdapter() (persist.Adapter, error) {
 	return a, nil
 }
 
+// newPoolAdapter creates the casbin adapter on the pool. The table is created here because pgxadapter closes the pool
+// when it fails to create the table.
+func (p *PostgresAdapter) newPoolAdapter() (persist.Adapter, error) {
+	ctx := context.Background()
+
+	identifier := func(name string) pgx.Identifier {
+		if p.schemaName != "" {
+			return pgx.Identifier{p.schemaName, name}
+		}
+
+		return pgx.Identifier{name}
+	}
+
+	if p.schemaName != "" {
+		if _, err := p.pool.Exec(ctx, fmt.Sprintf("CREATE SCHEMA IF NOT EXISTS %s", pgx.Identifier{p.schemaName}.Sanitize())); err != nil {
+			return nil, errors.Wrap(err, "pgxpool.Pool.Exec()")
+		}
+	}
+
+	// like pgxadapter, refuse a mixed case name when a table was created with its unquoted, lower case, spelling
+	if lower := strings.ToLower(p.tableName); p.tableName != pgxadapter.DefaultTableName && lower != p.tableName {
+		var exists bool
+		if err := p.pool.QueryRow(ctx, "SELECT to_regclass($1) IS NOT NULL", identifier(lower).Sanitize()).Scan(&exists); err != nil {
+			return nil, errors.Wrap(err, "pgxpool.Pool.QueryRow()")
+		}
+		if exists {
+			return nil, errors.Newf("found table with similar name only in lower case: %q. Either use this table name exactly, or choose a different name", lower)
+		}
+	}
+	table := identifier(p.tableName)
+
+	// the table layout of pgxadapter
+	if _, err := p.pool.Exec(ctx, fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+		id text PRIMARY KEY,
+		p_type text,
+		v0 text,
+		v1 text,
+		v2 text,
+		v3 text,
+		v4 text,
+		v5 text
+	)`, table.Sanitize())); err != nil {
+		return nil, errors.Wrap(err, "pgxpool.Pool.Exec()")
+	}
+
+	a, err := pgxadapter.NewAdapter(nil,
+		pgxadapter.WithConnectionPool(p.pool),
+		pgxadapter.WithSchema(p.schemaName),
+		pgxadapter.WithTableName(p.tableName),
+		pgxadapter.WithSkipTableCreate(),
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "pgxadapter.NewAdapter()")
+	}
+
+	return a, nil
+}
+
 // SpannerAdapter provides Spanner persistence for casbin policies.
 type SpannerAdapter struct {
 	databaseName string
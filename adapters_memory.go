@@ -0,0 +1,341 @@
+package access
+
+import (
+	"bufio"
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/casbin/casbin/v2/model"
+	"github.com/casbin/casbin/v2/persist"
+	"github.com/go-playground/errors/v5"
+)
+
+var (
+	_ Adapter              = &MemoryAdapter{}
+	_ persist.BatchAdapter = &MemoryAdapter{}
+	_ Adapter              = &FileAdapter{}
+	_ persist.BatchAdapter = &FileAdapter{}
+)
+
+// MemoryAdapter keeps casbin policies in memory. It reads and writes the CSV format of policy files, one rule per
+// line such as "g, user:alice, role:Manager, domain:tenant1", and is meant for tests and local development.
+// Clients created with the same MemoryAdapter share its policy.
+type MemoryAdapter struct {
+	mu    sync.Mutex
+	rules [][]string
+}
+
+// NewMemoryAdapter creates a MemoryAdapter holding policy, which is in the CSV format of a policy file and can be empty.
+func NewMemoryAdapter(policy string) (*MemoryAdapter, error) {
+	rules, err := parsePolicy(policy)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MemoryAdapter{rules: rules}, nil
+}
+
+// NewAdapter returns the MemoryAdapter itself, so the policy is kept across reloads.
+func (m *MemoryAdapter) NewAdapter() (persist.Adapter, error) {
+	return m, nil
+}
+
+// Policy returns the rules held by the adapter in the CSV format of a policy file.
+func (m *MemoryAdapter) Policy() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return formatPolicy(m.rules)
+}
+
+// LoadPolicy loads all rules into model.
+func (m *MemoryAdapter) LoadPolicy(model model.Model) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return loadRules(m.rules, model)
+}
+
+// SavePolicy replaces the rules with the ones in model.
+func (m *MemoryAdapter) SavePolicy(model model.Model) error {
+	return m.modify(func([][]string) [][]string { return modelRules(model) })
+}
+
+// AddPolicy adds a rule.
+func (m *MemoryAdapter) AddPolicy(_, ptype string, rule []string) error {
+	return m.AddPolicies("", ptype, [][]string{rule})
+}
+
+// AddPolicies adds rules.
+func (m *MemoryAdapter) AddPolicies(_, ptype string, rules [][]string) error {
+	return m.modify(func(stored [][]string) [][]string { return addRules(stored, ptype, rules) })
+}
+
+// RemovePolicy removes a rule.
+func (m *MemoryAdapter) RemovePolicy(_, ptype string, rule []string) error {
+	return m.RemovePolicies("", ptype, [][]string{rule})
+}
+
+// RemovePolicies removes rules.
+func (m *MemoryAdapter) RemovePolicies(_, ptype string, rules [][]string) error {
+	return m.modify(func(stored [][]string) [][]string { return removeRules(stored, ptype, rules) })
+}
+
+// RemoveFilteredPolicy removes the rules matching fieldValues from fieldIndex on. Empty values match any value.
+func (m *MemoryAdapter) RemoveFilteredPolicy(_, ptype string, fieldIndex int, fieldValues ...string) error {
+	return m.modify(func(stored [][]string) [][]string {
+		return removeFilteredRules(stored, ptype, fieldIndex, fieldValues)
+	})
+}
+
+func (m *MemoryAdapter) modify(fn func(rules [][]string) [][]string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.rules = fn(m.rules)
+
+	return nil
+}
+
+// FileAdapter stores casbin policies in a CSV policy file, the format of the files in testdata, and is meant for
+// tests and local development. Unlike casbin's file adapter it saves each change to the file as it is made.
+// The file must exist, and can be empty.
+type FileAdapter struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewFileAdapter creates a FileAdapter storing policies in the file at path.
+func NewFileAdapter(path string) *FileAdapter {
+	return &FileAdapter{path: path}
+}
+
+// NewAdapter returns the FileAdapter itself.
+func (f *FileAdapter) NewAdapter() (persist.Adapter, error) {
+	return f, nil
+}
+
+// LoadPolicy loads all rules in the file into model.
+func (f *FileAdapter) LoadPolicy(model model.Model) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	rules, err := f.read()
+	if err != nil {
+		return err
+	}
+
+	return loadRules(rules, model)
+}
+
+// SavePolicy replaces the rules in the file with the ones in model.
+func (f *FileAdapter) SavePolicy(model model.Model) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.write(modelRules(model))
+}
+
+// AddPolicy adds a rule to the file.
+func (f *FileAdapter) AddPolicy(_, ptype string, rule []string) error {
+	return f.AddPolicies("", ptype, [][]string{rule})
+}
+
+// AddPolicies adds rules to the file.
+func (f *FileAdapter) AddPolicies(_, ptype string, rules [][]string) error {
+	return f.modify(func(stored [][]string) [][]string { return addRules(stored, ptype, rules) })
+}
+
+// RemovePolicy removes a rule from the file.
+func (f *FileAdapter) RemovePolicy(_, ptype string, rule []string) error {
+	return f.RemovePolicies("", ptype, [][]string{rule})
+}
+
+// RemovePolicies removes rules from the file.
+func (f *FileAdapter) RemovePolicies(_, ptype string, rules [][]string) error {
+	return f.modify(func(stored [][]string) [][]string { return removeRules(stored, ptype, rules) })
+}
+
+// RemoveFilteredPolicy removes the rules matching fieldValues from fieldIndex on from the file. Empty values match
+// any value.
+func (f *FileAdapter) RemoveFilteredPolicy(_, ptype string, fieldIndex int, fieldValues ...string) error {
+	return f.modify(func(stored [][]string) [][]string {
+		return removeFilteredRules(stored, ptype, fieldIndex, fieldValues)
+	})
+}
+
+// modify rewrites the file with the rules returned by fn. The file is read again first so changes made to it since
+// the policy was loaded are kept.
+func (f *FileAdapter) modify(fn func(rules [][]string) [][]string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	rules, err := f.read()
+	if err != nil {
+		return err
+	}
+
+	return f.write(fn(rules))
+}
+
+func (f *FileAdapter) read() ([][]string, error) {
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		return nil, errors.Wrap(err, "os.ReadFile()")
+	}
+
+	return parsePolicy(string(data))
+}
+
+// write replaces the file through a temporary file in the same directory, so readers never see a partial policy.
+func (f *FileAdapter) write(rules [][]string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), filepath.Base(f.path)+".*")
+	if err != nil {
+		return errors.Wrap(err, "os.CreateTemp()")
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString(formatPolicy(rules)); err != nil {
+		_ = tmp.Close()
+
+		return errors.Wrap(err, "os.File.WriteString()")
+	}
+	if err := tmp.Close(); err != nil {
+		return errors.Wrap(err, "os.File.Close()")
+	}
+
+	if info, err := os.Stat(f.path); err == nil {
+		if err := os.Chmod(tmp.Name(), info.Mode()); err != nil {
+			return errors.Wrap(err, "os.Chmod()")
+		}
+	}
+
+	if err := os.Rename(tmp.Name(), f.path); err != nil {
+		return errors.Wrap(err, "os.Rename()")
+	}
+
+	return nil
+}
+
+// parsePolicy parses a policy in the CSV format of a policy file into rules starting with their policy type.
+// Blank lines and lines starting with # are skipped.
+func parsePolicy(policy string) ([][]string, error) {
+	var rules [][]string
+	scanner := bufio.NewScanner(strings.NewReader(policy))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		r := csv.NewReader(strings.NewReader(line))
+		r.TrimLeadingSpace = true
+		rule, err := r.Read()
+		if err != nil {
+			return nil, errors.Wrapf(err, "csv.Reader.Read(): line %d", n)
+		}
+
+		if len(rule) < 2 || rule[0] == "" {
+			return nil, errors.Newf("line %d: rule must have a policy type and at least one field", n)
+		}
+
+		rules = append(rules, rule)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrap(err, "bufio.Scanner.Scan()")
+	}
+
+	return rules, nil
+}
+
+// formatPolicy formats rules in the CSV format of a policy file, quoting fields only when needed.
+func formatPolicy(rules [][]string) string {
+	var b strings.Builder
+	for _, rule := range rules {
+		for i, field := range rule {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			if strings.ContainsAny(field, ",\"#\r\n") || strings.TrimSpace(field) != field {
+				field = `"` + strings.ReplaceAll(field, `"`, `""`) + `"`
+			}
+			b.WriteString(field)
+		}
+		b.WriteString("\n")
+	}
+
+	return b.String()
+}
+
+func loadRules(rules [][]string, model model.Model) error {
+	for _, rule := range rules {
+		if err := persist.LoadPolicyArray(rule, model); err != nil {
+			return errors.Wrap(err, "persist.LoadPolicyArray()")
+		}
+	}
+
+	return nil
+}
+
+// modelRules returns the rules in model, policies first, then role assignments.
+func modelRules(model model.Model) [][]string {
+	var rules [][]string
+	for _, sec := range []string{"p", "g"} {
+		assertions := model[sec]
+		ptypes := make([]string, 0, len(assertions))
+		for ptype := range assertions {
+			ptypes = append(ptypes, ptype)
+		}
+		slices.Sort(ptypes)
+
+		for _, ptype := range ptypes {
+			for _, rule := range assertions[ptype].Policy {
+				rules = append(rules, append([]string{ptype}, rule...))
+			}
+		}
+	}
+
+	return rules
+}
+
+// addRules appends the rules to stored, skipping the ones already there.
+func addRules(stored [][]string, ptype string, rules [][]string) [][]string {
+	for _, rule := range rules {
+		rule = append([]string{ptype}, rule...)
+		if !slices.ContainsFunc(stored, func(r []string) bool { return slices.Equal(r, rule) }) {
+			stored = append(stored, rule)
+		}
+	}
+
+	return stored
+}
+
+func removeRules(stored [][]string, ptype string, rules [][]string) [][]string {
+	return slices.DeleteFunc(stored, func(r []string) bool {
+		return r[0] == ptype && slices.ContainsFunc(rules, func(rule []string) bool { return slices.Equal(r[1:], rule) })
+	})
+}
+
+func removeFilteredRules(stored [][]string, ptype string, fieldIndex int, fieldValues []string) [][]string {
+	return slices.DeleteFunc(stored, func(r []string) bool {
+		if r[0] != ptype {
+			return false
+		}
+
+		fields := r[1:]
+		for i, value := range fieldValues {
+			if value == "" {
+				continue
+			}
+			if fieldIndex+i >= len(fields) || fields[fieldIndex+i] != value {
+				return false
+			}
+		}
+
+		return true
+	})
+}
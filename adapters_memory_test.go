@@ -0,0 +1,183 @@
+package access
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/cccteam/ccc/accesstypes"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestNewMemoryAdapter(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		policy  string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:   "empty policy",
+			policy: "",
+			want:   "",
+		},
+		{
+			name: "skips comments and blank lines",
+			policy: `# viewers
+p, role:Viewer,  domain:tenant1, resource:global, perm:ViewUsers, allow
+
+g,user:carol,role:Viewer,domain:tenant1
+`,
+			want: "p, role:Viewer, domain:tenant1, resource:global, perm:ViewUsers, allow\ng, user:carol, role:Viewer, domain:tenant1\n",
+		},
+		{
+			name:   "quotes fields with commas",
+			policy: `g, "user:doe, jane", role:Viewer, domain:tenant1`,
+			want:   "g, \"user:doe, jane\", role:Viewer, domain:tenant1\n",
+		},
+		{
+			name:    "rule without fields",
+			policy:  "p\n",
+			wantErr: true,
+		},
+		{
+			name:    "invalid CSV",
+			policy:  `g, "user:alice, role:Viewer`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := NewMemoryAdapter(tt.policy)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewMemoryAdapter() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			if diff := cmp.Diff(tt.want, got.Policy()); diff != "" {
+				t.Errorf("MemoryAdapter.Policy() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestAdapters_persistChanges(t *testing.T) {
+	t.Parallel()
+
+	policy, err := os.ReadFile("testdata/policy_inheritance.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		newAdapter func(t *testing.T) Adapter
+	}{
+		{
+			name: "MemoryAdapter",
+			newAdapter: func(t *testing.T) Adapter {
+				t.Helper()
+
+				adapter, err := NewMemoryAdapter(string(policy))
+				if err != nil {
+					t.Fatalf("NewMemoryAdapter() error = %v", err)
+				}
+
+				return adapter
+			},
+		},
+		{
+			name: "FileAdapter",
+			newAdapter: func(t *testing.T) Adapter {
+				t.Helper()
+
+				path := filepath.Join(t.TempDir(), "policy.csv")
+				if err := os.WriteFile(path, policy, 0o600); err != nil {
+					t.Fatal(err)
+				}
+
+				return NewFileAdapter(path)
+			},
+		},
+		{
+			name: "SQLAdapter",
+			newAdapter: func(t *testing.T) Adapter {
+				t.Helper()
+
+				return newSQLiteAdapter(t, "testdata/policy_inheritance.csv")
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+			adapter := tt.newAdapter(t)
+
+			client, err := New(tenant1Domains(t), adapter)
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+			manager := client.UserManager()
+
+			if err := manager.AddRoleUsers(ctx, "tenant1", "Auditor", "dave", "erin"); err != nil {
+				t.Fatalf("UserManager.AddRoleUsers() error = %v", err)
+			}
+			if err := manager.DeleteRoleUsers(ctx, "tenant1", "Viewer", "carol"); err != nil {
+				t.Fatalf("UserManager.DeleteRoleUsers() error = %v", err)
+			}
+			if err := manager.AddRolePermissions(ctx, "tenant1", "Auditor", accesstypes.Permission("ViewUsers")); err != nil {
+				t.Fatalf("UserManager.AddRolePermissions() error = %v", err)
+			}
+
+			// a new client reads the policy back from the adapter
+			reloaded, err := New(tenant1Domains(t), adapter)
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+
+			auditors, err := reloaded.UserManager().RoleUsers(ctx, "tenant1", "Auditor")
+			if err != nil {
+				t.Fatalf("UserManager.RoleUsers() error = %v", err)
+			}
+			slices.Sort(auditors)
+			if diff := cmp.Diff([]accesstypes.User{"dave", "erin"}, auditors); diff != "" {
+				t.Errorf("UserManager.RoleUsers(Auditor) mismatch (-want +got):\n%s", diff)
+			}
+
+			viewers, err := reloaded.UserManager().RoleUsers(ctx, "tenant1", "Viewer")
+			if err != nil {
+				t.Fatalf("UserManager.RoleUsers() error = %v", err)
+			}
+			if len(viewers) != 0 {
+				t.Errorf("UserManager.RoleUsers(Viewer) = %v, want none", viewers)
+			}
+
+			if err := reloaded.RequireAll(ctx, "dave", "tenant1", accesstypes.Permission("ViewUsers")); err != nil {
+				t.Errorf("Client.RequireAll() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestFileAdapter_missingFile(t *testing.T) {
+	t.Parallel()
+
+	client, err := New(tenant1Domains(t), NewFileAdapter(filepath.Join(t.TempDir(), "missing.csv")), WithLoadRetry(1, 0))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	if _, err := client.UserManager().Roles(context.Background(), "tenant1"); err == nil {
+		t.Errorf("UserManager.Roles() error = nil, want error")
+	}
+}
@@ -0,0 +1,503 @@
+package access
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/casbin/casbin/v2/model"
+	"github.com/casbin/casbin/v2/persist"
+	"github.com/go-playground/errors/v5"
+)
+
+// sqlFields is the number of policy fields stored by SQLAdapter, in columns v0 to v5.
+const sqlFields = 6
+
+var (
+	_ Adapter                 = &SQLAdapter{}
+	_ persist.BatchAdapter    = &SQLAdapter{}
+	_ persist.FilteredAdapter = &SQLAdapter{}
+	_ SQLDialect              = SQLiteDialect{}
+	_ SQLDialect              = PostgresDialect{}
+)
+
+// SQLDialect adapts the statements of SQLAdapter to a database.
+type SQLDialect interface {
+	// Placeholder returns the bind parameter for the nth argument of a statement, starting at 1.
+	Placeholder(n int) string
+	// QuoteIdentifier quotes a table, column or index name.
+	QuoteIdentifier(name string) string
+	// TextType returns the column type of the policy fields. The columns are indexed.
+	TextType() string
+	// CreateIndex returns the statement creating index on the columns of table if it doesn't exist. table holds the
+	// schema, if any, and the name of the table, and the index is created in the schema of the table. The names are
+	// quoted.
+	CreateIndex(index string, table []string, columns ...string) string
+	// CreateUniqueIndex returns the statement creating unique index on the columns of table if it doesn't exist, like
+	// CreateIndex.
+	CreateUniqueIndex(index string, table []string, columns ...string) string
+	// InsertIgnore returns the statement inserting values into the columns of table that does nothing when the row
+	// is already there. The names are quoted and values are placeholders.
+	InsertIgnore(table string, columns, values []string) string
+}
+
+// SQLiteDialect is the SQLDialect for SQLite.
+type SQLiteDialect struct{}
+
+// Placeholder returns ?.
+func (SQLiteDialect) Placeholder(int) string { return "?" }
+
+// QuoteIdentifier quotes name with double quotes.
+func (SQLiteDialect) QuoteIdentifier(name string) string { return quoteIdentifier(name) }
+
+// TextType returns TEXT.
+func (SQLiteDialect) TextType() string { return "TEXT" }
+
+// CreateIndex returns a CREATE INDEX IF NOT EXISTS statement. SQLite qualifies the index with the schema of the table
+// rather than the table itself.
+func (SQLiteDialect) CreateIndex(index string, table []string, columns ...string) string {
+	return sqliteCreateIndex("INDEX", index, table, columns)
+}
+
+// CreateUniqueIndex returns a CREATE UNIQUE INDEX IF NOT EXISTS statement, like CreateIndex.
+func (SQLiteDialect) CreateUniqueIndex(index string, table []string, columns ...string) string {
+	return sqliteCreateIndex("UNIQUE INDEX", index, table, columns)
+}
+
+func sqliteCreateIndex(kind, index string, table []string, columns []string) string {
+	schema := table[:len(table)-1]
+
+	return createIndex(kind, strings.Join(append(slices.Clone(schema), index), "."), table[len(table)-1], columns)
+}
+
+// InsertIgnore returns an INSERT OR IGNORE statement.
+func (SQLiteDialect) InsertIgnore(table string, columns, values []string) string {
+	return fmt.Sprintf("INSERT OR IGNORE INTO %s (%s) VALUES (%s)", table, strings.Join(columns, ", "), strings.Join(values, ", "))
+}
+
+// PostgresDialect is the SQLDialect for PostgreSQL, for use with drivers such as pgx's stdlib package.
+type PostgresDialect struct{}
+
+// Placeholder returns $n.
+func (PostgresDialect) Placeholder(n int) string { return fmt.Sprintf("$%d", n) }
+
+// QuoteIdentifier quotes name with double quotes.
+func (PostgresDialect) QuoteIdentifier(name string) string { return quoteIdentifier(name) }
+
+// TextType returns TEXT.
+func (PostgresDialect) TextType() string { return "TEXT" }
+
+// CreateIndex returns a CREATE INDEX IF NOT EXISTS statement.
+func (PostgresDialect) CreateIndex(index string, table []string, columns ...string) string {
+	return createIndex("INDEX", index, strings.Join(table, "."), columns)
+}
+
+// CreateUniqueIndex returns a CREATE UNIQUE INDEX IF NOT EXISTS statement.
+func (PostgresDialect) CreateUniqueIndex(index string, table []string, columns ...string) string {
+	return createIndex("UNIQUE INDEX", index, strings.Join(table, "."), columns)
+}
+
+// InsertIgnore returns an INSERT statement with ON CONFLICT DO NOTHING.
+func (PostgresDialect) InsertIgnore(table string, columns, values []string) string {
+	return fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s) ON CONFLICT DO NOTHING", table, strings.Join(columns, ", "), strings.Join(values, ", "))
+}
+
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+func createIndex(kind, index, table string, columns []string) string {
+	return fmt.Sprintf("CREATE %s IF NOT EXISTS %s ON %s (%s)", kind, index, table, strings.Join(columns, ", "))
+}
+
+// SQLFilter selects the rules loaded by SQLAdapter.LoadFilteredPolicy. P and G hold the values the fields of
+// policies and role assignments must have, from the first field on. Empty values match any value, and a nil P or G
+// loads all policies or role assignments. SkipP and SkipG load no policies or role assignments.
+type SQLFilter struct {
+	P     []string
+	G     []string
+	SkipP bool
+	SkipG bool
+}
+
+// SQLAdapter stores casbin policies in a table using database/sql, with the casbin rule layout of a ptype column and
+// fields v0 to v5. Use Migrate to create the table.
+type SQLAdapter struct {
+	db       *sql.DB
+	dialect  SQLDialect
+	table    []string
+	mu       sync.Mutex
+	filtered bool
+}
+
+// NewSQLAdapter creates an adapter storing policies in tableName, which may be qualified by a schema name.
+func NewSQLAdapter(db *sql.DB, dialect SQLDialect, tableName string) *SQLAdapter {
+	return &SQLAdapter{
+		db:      db,
+		dialect: dialect,
+		table:   strings.Split(tableName, "."),
+	}
+}
+
+// NewAdapter returns the SQLAdapter itself.
+func (s *SQLAdapter) NewAdapter() (persist.Adapter, error) {
+	return s, nil
+}
+
+// Migrate creates the policy table and its indexes if they don't exist, adding the indexes to tables created before
+// them. Policies are indexed by subject and by domain, and a unique index on all columns keeps a rule from being stored
+// twice. Migrate fails on a table that already holds duplicate rules, they must be removed first.
+func (s *SQLAdapter) Migrate(ctx context.Context) error {
+	columns := make([]string, 0, sqlFields+1)
+	for _, column := range s.columns() {
+		columns = append(columns, fmt.Sprintf("%s %s NOT NULL DEFAULT ''", column, s.dialect.TextType()))
+	}
+
+	statements := []string{
+		fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s)", s.tableName(), strings.Join(columns, ", ")),
+		s.dialect.CreateUniqueIndex(s.indexName("rule_idx"), s.quotedTable(), s.columns()...),
+		// v0 is the subject of both policies and role assignments
+		s.dialect.CreateIndex(s.indexName("subject_idx"), s.quotedTable(), s.column("ptype"), s.column("v0")),
+		// v1 is the domain of policies
+		s.dialect.CreateIndex(s.indexName("domain_idx"), s.quotedTable(), s.column("ptype"), s.column("v1")),
+		// v2 is the domain of role assignments
+		s.dialect.CreateIndex(s.indexName("role_domain_idx"), s.quotedTable(), s.column("ptype"), s.column("v2")),
+	}
+
+	for _, statement := range statements {
+		if _, err := s.db.ExecContext(ctx, statement); err != nil {
+			return errors.Wrap(err, "sql.DB.ExecContext()")
+		}
+	}
+
+	return nil
+}
+
+// LoadPolicy loads all rules into model.
+func (s *SQLAdapter) LoadPolicy(model model.Model) error {
+	if err := s.load(model, "", nil); err != nil {
+		return err
+	}
+	s.setFiltered(false)
+
+	return nil
+}
+
+// LoadFilteredPolicy loads the rules matching filter, a SQLFilter or *SQLFilter, into model.
+func (s *SQLAdapter) LoadFilteredPolicy(model model.Model, filter any) error {
+	var f SQLFilter
+	switch v := filter.(type) {
+	case SQLFilter:
+		f = v
+	case *SQLFilter:
+		if v != nil {
+			f = *v
+		}
+	case nil:
+	default:
+		return errors.Newf("invalid filter type %T, want access.SQLFilter", filter)
+	}
+
+	if f.P == nil && f.G == nil && !f.SkipP && !f.SkipG {
+		return s.LoadPolicy(model)
+	}
+
+	var conditions []string
+	var args []any
+	for _, sec := range []struct {
+		name   string
+		values []string
+		skip   bool
+	}{{"p", f.P, f.SkipP}, {"g", f.G, f.SkipG}} {
+		if sec.skip {
+			continue
+		}
+
+		condition := []string{fmt.Sprintf("%s LIKE %s", s.column("ptype"), s.dialect.Placeholder(len(args)+1))}
+		args = append(args, sec.name+"%")
+
+		where, whereArgs := s.fieldConditions(0, sec.values, len(args)+1)
+		condition = append(condition, where...)
+		args = append(args, whereArgs...)
+
+		conditions = append(conditions, "("+strings.Join(condition, " AND ")+")")
+	}
+
+	if len(conditions) > 0 {
+		if err := s.load(model, " WHERE "+strings.Join(conditions, " OR "), args); err != nil {
+			return err
+		}
+	}
+	s.setFiltered(true)
+
+	return nil
+}
+
+// IsFiltered returns true if the last policy loaded was filtered.
+func (s *SQLAdapter) IsFiltered() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.filtered
+}
+
+// SavePolicy replaces all rules with the ones in model.
+func (s *SQLAdapter) SavePolicy(model model.Model) error {
+	return s.transaction(func(ctx context.Context, tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM "+s.tableName()); err != nil {
+			return errors.Wrap(err, "sql.Tx.ExecContext()")
+		}
+
+		for _, rule := range modelRules(model) {
+			if err := s.insert(ctx, tx, rule[0], rule[1:]); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// AddPolicy adds a rule.
+func (s *SQLAdapter) AddPolicy(_, ptype string, rule []string) error {
+	return s.insert(context.Background(), s.db, ptype, rule)
+}
+
+// AddPolicies adds rules in a transaction.
+func (s *SQLAdapter) AddPolicies(_, ptype string, rules [][]string) error {
+	return s.transaction(func(ctx context.Context, tx *sql.Tx) error {
+		for _, rule := range rules {
+			if err := s.insert(ctx, tx, ptype, rule); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// RemovePolicy removes a rule.
+func (s *SQLAdapter) RemovePolicy(_, ptype string, rule []string) error {
+	return s.remove(context.Background(), s.db, ptype, rule)
+}
+
+// RemovePolicies removes rules in a transaction.
+func (s *SQLAdapter) RemovePolicies(_, ptype string, rules [][]string) error {
+	return s.transaction(func(ctx context.Context, tx *sql.Tx) error {
+		for _, rule := range rules {
+			if err := s.remove(ctx, tx, ptype, rule); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// RemoveFilteredPolicy removes the rules matching fieldValues from fieldIndex on. Empty values match any value.
+func (s *SQLAdapter) RemoveFilteredPolicy(_, ptype string, fieldIndex int, fieldValues ...string) error {
+	if fieldIndex < 0 || fieldIndex+len(fieldValues) > sqlFields {
+		return errors.Newf("field index %d with %d values is out of range", fieldIndex, len(fieldValues))
+	}
+
+	where, args := s.fieldConditions(fieldIndex, fieldValues, 2)
+	where = append([]string{fmt.Sprintf("%s = %s", s.column("ptype"), s.dialect.Placeholder(1))}, where...)
+	args = append([]any{ptype}, args...)
+
+	query := fmt.Sprintf("DELETE FROM %s WHERE %s", s.tableName(), strings.Join(where, " AND "))
+	if _, err := s.db.ExecContext(context.Background(), query, args...); err != nil {
+		return errors.Wrap(err, "sql.DB.ExecContext()")
+	}
+
+	return nil
+}
+
+// execer runs statements on a *sql.DB or *sql.Tx.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+func (s *SQLAdapter) load(model model.Model, where string, args []any) error {
+	ctx := context.Background()
+
+	query := fmt.Sprintf("SELECT %s FROM %s%s", strings.Join(s.columns(), ", "), s.tableName(), where)
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return errors.Wrap(err, "sql.DB.QueryContext()")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		rule := make([]string, sqlFields+1)
+		dest := make([]any, len(rule))
+		for i := range rule {
+			dest[i] = &rule[i]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return errors.Wrap(err, "sql.Rows.Scan()")
+		}
+
+		// fields a rule doesn't have are stored empty, the fields the model defines are kept even when empty
+		for len(rule) > 1+definedFields(model, rule[0]) && rule[len(rule)-1] == "" {
+			rule = rule[:len(rule)-1]
+		}
+
+		if err := persist.LoadPolicyArray(rule, model); err != nil {
+			return errors.Wrap(err, "persist.LoadPolicyArray()")
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return errors.Wrap(err, "sql.Rows.Err()")
+	}
+
+	return nil
+}
+
+// definedFields returns the number of fields model defines for the rules of ptype, or 1 for a ptype it doesn't have.
+func definedFields(model model.Model, ptype string) int {
+	if ptype == "" {
+		return 1
+	}
+	if assertion, ok := model[ptype[:1]][ptype]; ok && len(assertion.Tokens) > 0 {
+		return len(assertion.Tokens)
+	}
+
+	return 1
+}
+
+func (s *SQLAdapter) insert(ctx context.Context, db execer, ptype string, rule []string) error {
+	values, err := sqlValues(ptype, rule)
+	if err != nil {
+		return err
+	}
+
+	placeholders := make([]string, len(values))
+	for i := range values {
+		placeholders[i] = s.dialect.Placeholder(i + 1)
+	}
+
+	// rules already stored are skipped, as they are by casbin's model
+	query := s.dialect.InsertIgnore(s.tableName(), s.columns(), placeholders)
+	if _, err := db.ExecContext(ctx, query, values...); err != nil {
+		return errors.Wrap(err, "ExecContext()")
+	}
+
+	return nil
+}
+
+func (s *SQLAdapter) remove(ctx context.Context, db execer, ptype string, rule []string) error {
+	values, err := sqlValues(ptype, rule)
+	if err != nil {
+		return err
+	}
+
+	where := make([]string, len(values))
+	for i, column := range s.columns() {
+		where[i] = fmt.Sprintf("%s = %s", column, s.dialect.Placeholder(i+1))
+	}
+
+	query := fmt.Sprintf("DELETE FROM %s WHERE %s", s.tableName(), strings.Join(where, " AND "))
+	if _, err := db.ExecContext(ctx, query, values...); err != nil {
+		return errors.Wrap(err, "ExecContext()")
+	}
+
+	return nil
+}
+
+func (s *SQLAdapter) transaction(fn func(ctx context.Context, tx *sql.Tx) error) error {
+	ctx := context.Background()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "sql.DB.BeginTx()")
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := fn(ctx, tx); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.Wrap(err, "sql.Tx.Commit()")
+	}
+
+	return nil
+}
+
+// fieldConditions returns the conditions matching the non-empty values to the fields from fieldIndex on, numbering
+// their placeholders after the first n-1.
+func (s *SQLAdapter) fieldConditions(fieldIndex int, values []string, n int) ([]string, []any) {
+	var conditions []string
+	var args []any
+	for i, value := range values {
+		if value == "" || fieldIndex+i >= sqlFields {
+			continue
+		}
+
+		conditions = append(conditions, fmt.Sprintf("%s = %s", s.column(fmt.Sprintf("v%d", fieldIndex+i)), s.dialect.Placeholder(n+len(args))))
+		args = append(args, value)
+	}
+
+	return conditions, args
+}
+
+func (s *SQLAdapter) setFiltered(filtered bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.filtered = filtered
+}
+
+func (s *SQLAdapter) tableName() string {
+	return strings.Join(s.quotedTable(), ".")
+}
+
+// quotedTable returns the quoted schema, if any, and name of the table.
+func (s *SQLAdapter) quotedTable() []string {
+	parts := make([]string, len(s.table))
+	for i, part := range s.table {
+		parts[i] = s.dialect.QuoteIdentifier(part)
+	}
+
+	return parts
+}
+
+// indexName returns the name of an index on the table, which is created in the schema of the table.
+func (s *SQLAdapter) indexName(suffix string) string {
+	return s.dialect.QuoteIdentifier(s.table[len(s.table)-1] + "_" + suffix)
+}
+
+func (s *SQLAdapter) column(name string) string {
+	return s.dialect.QuoteIdentifier(name)
+}
+
+// columns returns the quoted ptype and field columns.
+func (s *SQLAdapter) columns() []string {
+	columns := []string{s.column("ptype")}
+	for i := range sqlFields {
+		columns = append(columns, s.column(fmt.Sprintf("v%d", i)))
+	}
+
+	return columns
+}
+
+// sqlValues returns the column values of a rule, with empty strings for the fields it doesn't have.
+func sqlValues(ptype string, rule []string) ([]any, error) {
+	if len(rule) > sqlFields {
+		return nil, errors.Newf("rule has %d fields, at most %d are supported", len(rule), sqlFields)
+	}
+
+	values := make([]any, sqlFields+1)
+	values[0] = ptype
+	for i := range sqlFields {
+		values[i+1] = ""
+		if i < len(rule) {
+			values[i+1] = rule[i]
+		}
+	}
+
+	return values, nil
+}
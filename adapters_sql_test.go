@@ -0,0 +1,284 @@
+package access
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/casbin/casbin/v2/model"
+	"github.com/cccteam/ccc/accesstypes"
+	"github.com/google/go-cmp/cmp"
+	_ "modernc.org/sqlite"
+)
+
+// newSQLiteAdapter returns a SQLAdapter on a new SQLite database holding the rules of the policy file.
+func newSQLiteAdapter(t *testing.T, policyFile string) *SQLAdapter {
+	t.Helper()
+
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "policy.db"))
+	if err != nil {
+		t.Fatalf("sql.Open() error = %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	adapter := NewSQLAdapter(db, SQLiteDialect{}, "casbin_rule")
+	if err := adapter.Migrate(context.Background()); err != nil {
+		t.Fatalf("SQLAdapter.Migrate() error = %v", err)
+	}
+
+	if policyFile != "" {
+		policy, err := os.ReadFile(policyFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		memory, err := NewMemoryAdapter(string(policy))
+		if err != nil {
+			t.Fatalf("NewMemoryAdapter() error = %v", err)
+		}
+
+		m := newTestModel(t)
+		if err := memory.LoadPolicy(m); err != nil {
+			t.Fatalf("MemoryAdapter.LoadPolicy() error = %v", err)
+		}
+		if err := adapter.SavePolicy(m); err != nil {
+			t.Fatalf("SQLAdapter.SavePolicy() error = %v", err)
+		}
+	}
+
+	return adapter
+}
+
+func newTestModel(t *testing.T) model.Model {
+	t.Helper()
+
+	m, err := model.NewModelFromString(rbacModel())
+	if err != nil {
+		t.Fatalf("model.NewModelFromString() error = %v", err)
+	}
+
+	return m
+}
+
+func TestSQLAdapter_Migrate(t *testing.T) {
+	t.Parallel()
+
+	adapter := newSQLiteAdapter(t, "testdata/policy_inheritance.csv")
+
+	// migrating again keeps the table and its rules
+	if err := adapter.Migrate(context.Background()); err != nil {
+		t.Fatalf("SQLAdapter.Migrate() error = %v", err)
+	}
+
+	rows, err := adapter.db.QueryContext(context.Background(), `SELECT name FROM sqlite_master WHERE type = 'index' AND tbl_name = 'casbin_rule' ORDER BY name`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	var indexes []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			t.Fatal(err)
+		}
+		indexes = append(indexes, name)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff([]string{"casbin_rule_domain_idx", "casbin_rule_role_domain_idx", "casbin_rule_rule_idx", "casbin_rule_subject_idx"}, indexes); diff != "" {
+		t.Errorf("indexes mismatch (-want +got):\n%s", diff)
+	}
+
+	m := newTestModel(t)
+	if err := adapter.LoadPolicy(m); err != nil {
+		t.Fatalf("SQLAdapter.LoadPolicy() error = %v", err)
+	}
+	if got := len(modelRules(m)); got != 15 {
+		t.Errorf("SQLAdapter.LoadPolicy() loaded %d rules, want 15", got)
+	}
+}
+
+func TestSQLAdapter_Migrate_schema(t *testing.T) {
+	t.Parallel()
+
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "policy.db"))
+	if err != nil {
+		t.Fatalf("sql.Open() error = %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	// attached databases belong to the connection
+	db.SetMaxOpenConns(1)
+
+	ctx := context.Background()
+	if _, err := db.ExecContext(ctx, "ATTACH DATABASE ? AS access", filepath.Join(t.TempDir(), "access.db")); err != nil {
+		t.Fatal(err)
+	}
+
+	adapter := NewSQLAdapter(db, SQLiteDialect{}, "access.casbin_rule")
+	if err := adapter.Migrate(ctx); err != nil {
+		t.Fatalf("SQLAdapter.Migrate() error = %v", err)
+	}
+
+	var indexes int
+	if err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM access.sqlite_master WHERE type = 'index' AND tbl_name = 'casbin_rule'`).Scan(&indexes); err != nil {
+		t.Fatal(err)
+	}
+	if indexes != 4 {
+		t.Errorf("indexes = %d, want 4", indexes)
+	}
+}
+
+func TestSQLAdapter_LoadFilteredPolicy(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name         string
+		filter       any
+		want         [][]string
+		wantFiltered bool
+		wantErr      bool
+	}{
+		{
+			name:   "role assignments of a user",
+			filter: SQLFilter{SkipP: true, G: []string{"user:alice"}},
+			want: [][]string{
+				{"g", "user:alice", "role:Manager", "domain:tenant1"},
+			},
+			wantFiltered: true,
+		},
+		{
+			name:   "policies of a role and assignments to it",
+			filter: &SQLFilter{P: []string{"role:Intern", "domain:tenant1"}, G: []string{"", "role:Intern"}},
+			want: [][]string{
+				{"p", "role:Intern", "domain:tenant1", "resource:Users.name", "perm:Update", "deny"},
+				{"g", "user:bob", "role:Intern", "domain:tenant1"},
+				{"g", "noop", "role:Intern", "domain:tenant1"},
+			},
+			wantFiltered: true,
+		},
+		{
+			name:   "all policies without assignments",
+			filter: SQLFilter{SkipG: true},
+			want: [][]string{
+				{"p", "role:Viewer", "domain:tenant1", "resource:global", "perm:ViewUsers", "allow"},
+				{"p", "role:Editor", "domain:tenant1", "resource:Users.name", "perm:Update", "allow"},
+				{"p", "role:Manager", "domain:tenant1", "resource:global", "perm:DeleteUsers", "allow"},
+				{"p", "role:Intern", "domain:tenant1", "resource:Users.name", "perm:Update", "deny"},
+			},
+			wantFiltered: true,
+		},
+		{
+			name:         "nothing",
+			filter:       SQLFilter{SkipP: true, SkipG: true},
+			wantFiltered: true,
+		},
+		{
+			name:    "invalid filter",
+			filter:  "user:alice",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			adapter := newSQLiteAdapter(t, "testdata/policy_inheritance.csv")
+
+			m := newTestModel(t)
+			if err := adapter.LoadFilteredPolicy(m, tt.filter); (err != nil) != tt.wantErr {
+				t.Fatalf("SQLAdapter.LoadFilteredPolicy() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if diff := cmp.Diff(tt.want, modelRules(m)); diff != "" {
+				t.Errorf("SQLAdapter.LoadFilteredPolicy() mismatch (-want +got):\n%s", diff)
+			}
+			if got := adapter.IsFiltered(); got != tt.wantFiltered {
+				t.Errorf("SQLAdapter.IsFiltered() = %v, want %v", got, tt.wantFiltered)
+			}
+		})
+	}
+}
+
+func TestSQLAdapter_writes(t *testing.T) {
+	t.Parallel()
+
+	adapter := newSQLiteAdapter(t, "")
+
+	if err := adapter.AddPolicies("g", "g", [][]string{
+		{"user:alice", "role:Viewer", "domain:tenant1"},
+		{"user:bob", "role:Viewer", "domain:tenant1"},
+		{"user:bob", "role:Editor", "domain:tenant2", "2030-01-01T00:00:00Z"},
+	}); err != nil {
+		t.Fatalf("SQLAdapter.AddPolicies() error = %v", err)
+	}
+	if err := adapter.AddPolicy("p", "p", []string{"role:Viewer", "domain:tenant1", "resource:global", "perm:ViewUsers", "allow"}); err != nil {
+		t.Fatalf("SQLAdapter.AddPolicy() error = %v", err)
+	}
+	// a rule already stored is skipped
+	if err := adapter.AddPolicies("p", "p", [][]string{{"role:Viewer", "domain:tenant1", "resource:global", "perm:ViewUsers", "allow"}}); err != nil {
+		t.Fatalf("SQLAdapter.AddPolicies() error = %v for a stored rule", err)
+	}
+	if _, err := adapter.db.ExecContext(context.Background(), `INSERT INTO casbin_rule (ptype, v0, v1, v2, v3, v4) VALUES ('p', 'role:Viewer', 'domain:tenant1', 'resource:global', 'perm:ViewUsers', 'allow')`); err == nil {
+		t.Errorf("INSERT error = nil, want unique index violation for a duplicate rule")
+	}
+	if err := adapter.RemovePolicies("g", "g", [][]string{{"user:alice", "role:Viewer", "domain:tenant1"}}); err != nil {
+		t.Fatalf("SQLAdapter.RemovePolicies() error = %v", err)
+	}
+	// the rule without an expiry doesn't remove the time-bound one
+	if err := adapter.RemovePolicy("g", "g", []string{"user:bob", "role:Editor", "domain:tenant2"}); err != nil {
+		t.Fatalf("SQLAdapter.RemovePolicy() error = %v", err)
+	}
+	if err := adapter.RemoveFilteredPolicy("g", "g", 1, "role:Viewer", "domain:tenant1"); err != nil {
+		t.Fatalf("SQLAdapter.RemoveFilteredPolicy() error = %v", err)
+	}
+	// empty fields the model defines are kept
+	if err := adapter.AddPolicy("p", "p", []string{"role:Viewer", "domain:tenant1", "resource:global", "", ""}); err != nil {
+		t.Fatalf("SQLAdapter.AddPolicy() error = %v", err)
+	}
+	if err := adapter.AddPolicy("p", "p", []string{"1", "2", "3", "4", "5", "6", "7"}); err == nil {
+		t.Errorf("SQLAdapter.AddPolicy() error = nil, want error for too many fields")
+	}
+
+	m := newTestModel(t)
+	if err := adapter.LoadPolicy(m); err != nil {
+		t.Fatalf("SQLAdapter.LoadPolicy() error = %v", err)
+	}
+
+	want := [][]string{
+		{"p", "role:Viewer", "domain:tenant1", "resource:global", "perm:ViewUsers", "allow"},
+		{"p", "role:Viewer", "domain:tenant1", "resource:global", "", ""},
+		{"g", "user:bob", "role:Editor", "domain:tenant2", "2030-01-01T00:00:00Z"},
+	}
+	if diff := cmp.Diff(want, modelRules(m)); diff != "" {
+		t.Errorf("SQLAdapter.LoadPolicy() mismatch (-want +got):\n%s", diff)
+	}
+	if adapter.IsFiltered() {
+		t.Errorf("SQLAdapter.IsFiltered() = true, want false")
+	}
+}
+
+func TestSQLAdapter_WithPolicyFilter(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	client, err := New(tenant1Domains(t), newSQLiteAdapter(t, "testdata/policy_inheritance.csv"),
+		WithPolicyFilter(SQLFilter{G: []string{"user:alice"}}))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	// only alice's role assignments are loaded
+	if err := client.RequireAll(ctx, "alice", "tenant1", accesstypes.Permission("DeleteUsers")); err != nil {
+		t.Errorf("Client.RequireAll(alice) error = %v", err)
+	}
+	if err := client.RequireAll(ctx, "carol", "tenant1", accesstypes.Permission("ViewUsers")); err == nil {
+		t.Error("Client.RequireAll(carol) error = nil, want error for a user left out by the filter")
+	}
+}
@@ -0,0 +1,87 @@
+package access
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func TestPostgresAdapter_NewAdapter_pool(t *testing.T) {
+	t.Parallel()
+
+	// nothing listens on port 1, so creating the table fails
+	pool, err := pgxpool.New(context.Background(), "postgres://access@127.0.0.1:1/access?connect_timeout=1")
+	if err != nil {
+		t.Fatalf("pgxpool.New() error = %v", err)
+	}
+	defer pool.Close()
+
+	if _, err := NewPostgresPoolAdapter(pool, "access", "casbin_rule").NewAdapter(); err == nil {
+		t.Fatalf("PostgresAdapter.NewAdapter() error = nil, want error")
+	}
+
+	// the pool belongs to the caller and must stay open
+	if err := pool.Ping(context.Background()); err == nil || strings.Contains(err.Error(), "closed pool") {
+		t.Errorf("pgxpool.Pool.Ping() error = %v, want connection error", err)
+	}
+}
+
+// TestPostgresAdapter_NewAdapter_poolIntegration runs against the database in ACCESS_TEST_POSTGRES_DSN and is skipped
+// without it.
+func TestPostgresAdapter_NewAdapter_poolIntegration(t *testing.T) {
+	t.Parallel()
+
+	dsn := os.Getenv("ACCESS_TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("ACCESS_TEST_POSTGRES_DSN is not set")
+	}
+
+	ctx := context.Background()
+	pool, err := pgxpool.New(ctx, dsn)
+	if err != nil {
+		t.Fatalf("pgxpool.New() error = %v", err)
+	}
+	defer pool.Close()
+
+	tableName := fmt.Sprintf("casbin_rule_%d", time.Now().UnixNano())
+	t.Cleanup(func() {
+		for _, name := range []string{tableName, strings.ToLower("Mixed_" + tableName)} {
+			_, _ = pool.Exec(ctx, "DROP TABLE IF EXISTS "+pgx.Identifier{"access_test", name}.Sanitize())
+		}
+	})
+
+	adapter, err := NewPostgresPoolAdapter(pool, "access_test", tableName).NewAdapter()
+	if err != nil {
+		t.Fatalf("PostgresAdapter.NewAdapter() error = %v", err)
+	}
+	if err := adapter.AddPolicy("g", "g", []string{"user:alice", "role:Viewer", "domain:tenant1"}); err != nil {
+		t.Fatalf("persist.Adapter.AddPolicy() error = %v", err)
+	}
+
+	m := newTestModel(t)
+	if err := adapter.LoadPolicy(m); err != nil {
+		t.Fatalf("persist.Adapter.LoadPolicy() error = %v", err)
+	}
+	if diff := cmp.Diff([][]string{{"g", "user:alice", "role:Viewer", "domain:tenant1"}}, modelRules(m)); diff != "" {
+		t.Errorf("persist.Adapter.LoadPolicy() mismatch (-want +got):\n%s", diff)
+	}
+
+	// a mixed case name is refused when the table exists with its lower case spelling
+	if _, err := NewPostgresPoolAdapter(pool, "access_test", strings.ToLower("Mixed_"+tableName)).NewAdapter(); err != nil {
+		t.Fatalf("PostgresAdapter.NewAdapter() error = %v", err)
+	}
+	if _, err := NewPostgresPoolAdapter(pool, "access_test", "Mixed_"+tableName).NewAdapter(); err == nil {
+		t.Error("PostgresAdapter.NewAdapter() error = nil, want error for a table differing only in case")
+	}
+
+	if err := pool.Ping(ctx); err != nil {
+		t.Errorf("pgxpool.Pool.Ping() error = %v, want the pool open", err)
+	}
+}
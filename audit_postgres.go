@@ -0,0 +1,80 @@
+package access
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/go-playground/errors/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// PostgresExecutor runs SQL statements. It is implemented by *pgx.Conn, *pgxpool.Pool and pgx.Tx.
+type PostgresExecutor interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+}
+
+var _ AuditSink = &PostgresAuditSink{}
+
+// PostgresAuditSink is an AuditSink that stores events in a PostgreSQL table. The searchable fields of an event are
+// stored in columns and the whole event as JSON.
+type PostgresAuditSink struct {
+	db    PostgresExecutor
+	table pgx.Identifier
+}
+
+// NewPostgresAuditSink creates an AuditSink storing events in tableName, which may be qualified by a schema name.
+// Use CreateTable to create the table.
+func NewPostgresAuditSink(db PostgresExecutor, tableName string) *PostgresAuditSink {
+	return &PostgresAuditSink{
+		db:    db,
+		table: pgx.Identifier(strings.Split(tableName, ".")),
+	}
+}
+
+// CreateTable creates the audit table and its indexes if they don't exist.
+func (p *PostgresAuditSink) CreateTable(ctx context.Context) error {
+	statements := []string{
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+			id BIGSERIAL PRIMARY KEY,
+			occurred_at TIMESTAMPTZ NOT NULL,
+			actor TEXT NOT NULL,
+			action TEXT NOT NULL,
+			domain TEXT NOT NULL,
+			role TEXT NOT NULL,
+			event JSONB NOT NULL
+		)`, p.table.Sanitize()),
+		fmt.Sprintf(`CREATE INDEX IF NOT EXISTS %s ON %s (domain, occurred_at)`, p.indexName("domain_idx"), p.table.Sanitize()),
+		fmt.Sprintf(`CREATE INDEX IF NOT EXISTS %s ON %s (actor, occurred_at)`, p.indexName("actor_idx"), p.table.Sanitize()),
+	}
+
+	for _, statement := range statements {
+		if _, err := p.db.Exec(ctx, statement); err != nil {
+			return errors.Wrap(err, "PostgresExecutor.Exec()")
+		}
+	}
+
+	return nil
+}
+
+// Record inserts event into the audit table.
+func (p *PostgresAuditSink) Record(ctx context.Context, event *AuditEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return errors.Wrap(err, "json.Marshal()")
+	}
+
+	sql := fmt.Sprintf(`INSERT INTO %s (occurred_at, actor, action, domain, role, event) VALUES ($1, $2, $3, $4, $5, $6)`, p.table.Sanitize())
+	if _, err := p.db.Exec(ctx, sql, event.Time, event.Actor, event.Action, string(event.Domain), string(event.Role), data); err != nil {
+		return errors.Wrap(err, "PostgresExecutor.Exec()")
+	}
+
+	return nil
+}
+
+// indexName returns the name of an index on the table, which is created in the schema of the table.
+func (p *PostgresAuditSink) indexName(suffix string) string {
+	return pgx.Identifier{p.table[len(p.table)-1] + "_" + suffix}.Sanitize()
+}
@@ -0,0 +1,265 @@
+package access
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cccteam/ccc/accesstypes"
+	"github.com/cccteam/httpio"
+	"github.com/go-playground/errors/v5"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/jackc/pgx/v5/pgconn"
+	"go.uber.org/mock/gomock"
+)
+
+func Test_auditedUserManager(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	viewer := func(permissions accesstypes.RolePermissionCollection) AuditState {
+		return AuditState{"tenant1": {"Viewer": {
+			Users:       []accesstypes.User{"carol"},
+			Permissions: permissions,
+			Denials:     accesstypes.RolePermissionCollection{},
+			Parents:     []accesstypes.Role{},
+		}}}
+	}
+
+	tests := []struct {
+		name    string
+		change  func(ctx context.Context, manager UserManager) error
+		want    []AuditEvent
+		wantErr bool
+	}{
+		{
+			name: "records the change with the role before and after",
+			change: func(ctx context.Context, manager UserManager) error {
+				return manager.AddRolePermissions(ctx, "tenant1", "Viewer", "ListRoles")
+			},
+			want: []AuditEvent{{
+				Time: now, Actor: "admin", Action: "AddRolePermissions", Domain: "tenant1", Role: "Viewer",
+				Permissions: []accesstypes.Permission{"ListRoles"},
+				Before:      viewer(accesstypes.RolePermissionCollection{"ViewUsers": {accesstypes.GlobalResource}}),
+				After:       viewer(accesstypes.RolePermissionCollection{"ViewUsers": {accesstypes.GlobalResource}, "ListRoles": {accesstypes.GlobalResource}}),
+			}},
+		},
+		{
+			name: "records failed changes with their error",
+			change: func(ctx context.Context, manager UserManager) error {
+				if err := manager.AddRoleUsers(ctx, "tenant1", "Missing", "dave"); err == nil {
+					t.Error("UserManager.AddRoleUsers() error = nil, want error")
+				}
+
+				return nil
+			},
+			want: []AuditEvent{{
+				Time: now, Actor: "admin", Action: "AddRoleUsers", Domain: "tenant1", Role: "Missing",
+				Users:  []accesstypes.User{"dave"},
+				Before: AuditState{},
+				After:  AuditState{},
+			}},
+			wantErr: true,
+		},
+		{
+			name: "records each change applied",
+			change: func(ctx context.Context, manager UserManager) error {
+				return manager.Apply(ctx,
+					AddRolePermissionResourceDenialsChange("tenant1", "Viewer", "Update", "Users.name"),
+					DeleteRoleUsersChange("tenant1", "Viewer", "carol"),
+				)
+			},
+			want: []AuditEvent{
+				{
+					Time: now, Actor: "admin", Action: "AddRolePermissionResourceDenials", Domain: "tenant1", Role: "Viewer",
+					Permissions: []accesstypes.Permission{"Update"},
+					Resources:   []accesstypes.Resource{"Users.name"},
+					Before:      viewer(accesstypes.RolePermissionCollection{"ViewUsers": {accesstypes.GlobalResource}}),
+					After: AuditState{"tenant1": {"Viewer": {
+						Users:       []accesstypes.User{},
+						Permissions: accesstypes.RolePermissionCollection{"ViewUsers": {accesstypes.GlobalResource}},
+						Denials:     accesstypes.RolePermissionCollection{"Update": {"Users.name"}},
+						Parents:     []accesstypes.Role{},
+					}}},
+				},
+				{
+					Time: now, Actor: "admin", Action: "DeleteRoleUsers", Domain: "tenant1", Role: "Viewer",
+					Users:  []accesstypes.User{"carol"},
+					Before: viewer(accesstypes.RolePermissionCollection{"ViewUsers": {accesstypes.GlobalResource}}),
+					After: AuditState{"tenant1": {"Viewer": {
+						Users:       []accesstypes.User{},
+						Permissions: accesstypes.RolePermissionCollection{"ViewUsers": {accesstypes.GlobalResource}},
+						Denials:     accesstypes.RolePermissionCollection{"Update": {"Users.name"}},
+						Parents:     []accesstypes.Role{},
+					}}},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			sink := NewMemoryAuditSink()
+			client, err := New(tenant1Domains(t), fileAdapter("testdata/policy_inheritance.csv"),
+				WithAuditSink(sink, WithAuditActor(func(context.Context) string { return "admin" })))
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+			manager, ok := client.UserManager().(*auditedUserManager)
+			if !ok {
+				t.Fatalf("Client.UserManager() = %T, want *auditedUserManager", client.UserManager())
+			}
+			manager.now = func() time.Time { return now }
+
+			if err := tt.change(context.Background(), manager); err != nil {
+				t.Fatalf("change error = %v", err)
+			}
+
+			events := sink.Events()
+			for i := range events {
+				if (events[i].Error != "") != tt.wantErr {
+					t.Errorf("AuditEvent.Error = %q, wantErr %v", events[i].Error, tt.wantErr)
+				}
+				events[i].Error = ""
+			}
+			if diff := cmp.Diff(tt.want, events, cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("MemoryAuditSink.Events() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// failingAuditSink fails to record the first event and keeps the others.
+type failingAuditSink struct {
+	MemoryAuditSink
+	calls int
+}
+
+func (f *failingAuditSink) Record(ctx context.Context, event *AuditEvent) error {
+	f.calls++
+	if f.calls == 1 {
+		return errors.New("sink unavailable")
+	}
+
+	return f.MemoryAuditSink.Record(ctx, event)
+}
+
+func Test_auditedUserManager_failures(t *testing.T) {
+	t.Parallel()
+
+	viewer := &RoleState{Users: []accesstypes.User{"carol"}}
+
+	t.Run("records the change when the state after it can't be read", func(t *testing.T) {
+		t.Parallel()
+
+		manager := NewMockUserManager(gomock.NewController(t))
+		gomock.InOrder(
+			manager.EXPECT().RoleExists(gomock.Any(), accesstypes.Domain("tenant1"), accesstypes.Role("Viewer")).Return(true, nil),
+			manager.EXPECT().AddRoleUsers(gomock.Any(), accesstypes.Domain("tenant1"), accesstypes.Role("Viewer"), accesstypes.User("dave")).Return(nil),
+			manager.EXPECT().RoleExists(gomock.Any(), accesstypes.Domain("tenant1"), accesstypes.Role("Viewer")).Return(false, errors.New("connection reset")),
+		)
+		manager.EXPECT().RoleUsers(gomock.Any(), gomock.Any(), gomock.Any()).Return(viewer.Users, nil)
+		manager.EXPECT().RolePermissions(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, nil)
+		manager.EXPECT().RolePermissionDenials(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, nil)
+		manager.EXPECT().RoleParents(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, nil)
+
+		sink := NewMemoryAuditSink()
+		audited := NewAuditedUserManager(manager, sink)
+		if err := audited.AddRoleUsers(context.Background(), "tenant1", "Viewer", "dave"); err != nil {
+			t.Fatalf("UserManager.AddRoleUsers() error = %v", err)
+		}
+
+		events := sink.Events()
+		if len(events) != 1 {
+			t.Fatalf("MemoryAuditSink.Events() = %v, want one event", events)
+		}
+		if diff := cmp.Diff(AuditState{"tenant1": {"Viewer": viewer}}, events[0].Before); diff != "" {
+			t.Errorf("AuditEvent.Before mismatch (-want +got):\n%s", diff)
+		}
+		if len(events[0].After) != 0 {
+			t.Errorf("AuditEvent.After = %v, want empty", events[0].After)
+		}
+		if events[0].StateError == "" {
+			t.Error("AuditEvent.StateError is empty, want the error reading the state")
+		}
+	})
+
+	t.Run("records every event when the sink fails", func(t *testing.T) {
+		t.Parallel()
+
+		manager := NewMockUserManager(gomock.NewController(t))
+		manager.EXPECT().RoleExists(gomock.Any(), gomock.Any(), gomock.Any()).Return(false, nil).AnyTimes()
+		manager.EXPECT().Apply(gomock.Any(), gomock.Any()).Return(httpio.NewConflictMessage("conflict"))
+
+		sink := &failingAuditSink{}
+		audited := NewAuditedUserManager(manager, sink)
+		err := audited.Apply(context.Background(),
+			AddRoleUsersChange("tenant1", "Viewer", "dave"),
+			AddRoleUsersChange("tenant1", "Editor", "erin"),
+		)
+		if !httpio.HasConflict(err) {
+			t.Errorf("UserManager.Apply() error = %v, want the conflict from the change", err)
+		}
+		if err == nil || !strings.Contains(err.Error(), "sink unavailable") {
+			t.Errorf("UserManager.Apply() error = %v, want the error from the sink too", err)
+		}
+
+		events := sink.Events()
+		if len(events) != 1 || events[0].Role != "Editor" || events[0].Error == "" {
+			t.Errorf("MemoryAuditSink.Events() = %v, want the failed change to Editor", events)
+		}
+	})
+}
+
+type recordingExecutor struct {
+	sql  []string
+	args [][]any
+}
+
+func (r *recordingExecutor) Exec(_ context.Context, sql string, arguments ...any) (pgconn.CommandTag, error) {
+	r.sql = append(r.sql, sql)
+	r.args = append(r.args, arguments)
+
+	return pgconn.CommandTag{}, nil
+}
+
+func TestPostgresAuditSink(t *testing.T) {
+	t.Parallel()
+
+	db := &recordingExecutor{}
+	sink := NewPostgresAuditSink(db, "access.audit_events")
+
+	if err := sink.CreateTable(context.Background()); err != nil {
+		t.Fatalf("PostgresAuditSink.CreateTable() error = %v", err)
+	}
+	event := &AuditEvent{Time: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), Actor: "admin", Action: "AddRole", Domain: "tenant1", Role: "Viewer"}
+	if err := sink.Record(context.Background(), event); err != nil {
+		t.Fatalf("PostgresAuditSink.Record() error = %v", err)
+	}
+
+	wantSQL := []string{
+		`CREATE TABLE IF NOT EXISTS "access"."audit_events" (
+			id BIGSERIAL PRIMARY KEY,
+			occurred_at TIMESTAMPTZ NOT NULL,
+			actor TEXT NOT NULL,
+			action TEXT NOT NULL,
+			domain TEXT NOT NULL,
+			role TEXT NOT NULL,
+			event JSONB NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS "audit_events_domain_idx" ON "access"."audit_events" (domain, occurred_at)`,
+		`CREATE INDEX IF NOT EXISTS "audit_events_actor_idx" ON "access"."audit_events" (actor, occurred_at)`,
+		`INSERT INTO "access"."audit_events" (occurred_at, actor, action, domain, role, event) VALUES ($1, $2, $3, $4, $5, $6)`,
+	}
+	if diff := cmp.Diff(wantSQL, db.sql); diff != "" {
+		t.Errorf("PostgresAuditSink SQL mismatch (-want +got):\n%s", diff)
+	}
+
+	wantArgs := []any{event.Time, "admin", "AddRole", "tenant1", "Viewer", []byte(`{"time":"2025-01-01T00:00:00Z","actor":"admin","action":"AddRole","domain":"tenant1","role":"Viewer"}`)}
+	if diff := cmp.Diff(wantArgs, db.args[3]); diff != "" {
+		t.Errorf("PostgresAuditSink.Record() arguments mismatch (-want +got):\n%s", diff)
+	}
+}
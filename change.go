@@ -0,0 +1,502 @@
+package access
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/casbin/casbin/v2"
+	"github.com/casbin/casbin/v2/model"
+	"github.com/casbin/casbin/v2/persist"
+	"github.com/cccteam/ccc/accesstypes"
+	"github.com/cccteam/ccc/tracer"
+	"github.com/cccteam/httpio"
+	"github.com/go-playground/errors/v5"
+)
+
+type policyType int
+
+const (
+	permissionPolicy policyType = iota
+	groupingPolicy
+)
+
+// section returns the casbin section and policy type of the policies, which have the same name in the model.
+func (p policyType) section() string {
+	if p == groupingPolicy {
+		return "g"
+	}
+
+	return "p"
+}
+
+// Change is a set of grouping or permission policies for a role in a domain that UserManager.Apply adds or removes.
+// Create one with the Add* and Delete* change constructors.
+type Change struct {
+	domain  accesstypes.Domain
+	role    accesstypes.Role
+	ptype   policyType
+	remove  bool
+	rules   [][]string
+	invalid string
+
+	// parents are the roles a parent change links role to, they must exist too
+	parents []accesstypes.Role
+	// expiresAt is when the assignments added by a time-bound user change expire
+	expiresAt time.Time
+}
+
+// AddRoleUsersChange assigns role to users in domain, replacing the time-bound assignments the users already have.
+func AddRoleUsersChange(domain accesstypes.Domain, role accesstypes.Role, users ...accesstypes.User) Change {
+	c := Change{domain: domain, role: role, ptype: groupingPolicy}
+	for _, user := range users {
+		if user == "" {
+			c.invalid = "user cannot be empty string"
+		}
+		c.rules = append(c.rules, []string{user.Marshal(), role.Marshal(), domain.Marshal()})
+	}
+
+	return c
+}
+
+// AddRoleUsersUntilChange assigns role to users in domain until expiresAt, replacing the time-bound assignments the
+// users already have. Like UserManager.AddRoleUsersUntil, Apply fails if expiresAt is not in the future or a user
+// already has the role permanently.
+func AddRoleUsersUntilChange(domain accesstypes.Domain, role accesstypes.Role, expiresAt time.Time, users ...accesstypes.User) Change {
+	c := AddRoleUsersChange(domain, role, users...)
+	c.expiresAt = expiresAt
+	for i := range c.rules {
+		c.rules[i] = append(c.rules[i], expiresAt.UTC().Format(expiryLayout))
+	}
+
+	return c
+}
+
+// DeleteRoleUsersChange removes users from role in domain, including their time-bound assignments.
+func DeleteRoleUsersChange(domain accesstypes.Domain, role accesstypes.Role, users ...accesstypes.User) Change {
+	c := AddRoleUsersChange(domain, role, users...)
+	c.remove = true
+
+	return c
+}
+
+// AddRoleParentsChange makes role inherit parents in domain.
+func AddRoleParentsChange(domain accesstypes.Domain, role accesstypes.Role, parents ...accesstypes.Role) Change {
+	c := Change{domain: domain, role: role, ptype: groupingPolicy, parents: parents}
+	for _, parent := range parents {
+		if parent == role {
+			c.invalid = "role cannot inherit itself"
+		}
+		c.rules = append(c.rules, []string{role.Marshal(), parent.Marshal(), domain.Marshal()})
+	}
+
+	return c
+}
+
+// DeleteRoleParentsChange stops role inheriting parents in domain.
+func DeleteRoleParentsChange(domain accesstypes.Domain, role accesstypes.Role, parents ...accesstypes.Role) Change {
+	c := AddRoleParentsChange(domain, role, parents...)
+	c.remove = true
+
+	return c
+}
+
+// AddRolePermissionsChange grants global permissions to role in domain.
+func AddRolePermissionsChange(domain accesstypes.Domain, role accesstypes.Role, permissions ...accesstypes.Permission) Change {
+	return rolePermissionsChange(domain, role, effectAllow, permissions...)
+}
+
+// DeleteRolePermissionsChange removes global permissions from role in domain.
+func DeleteRolePermissionsChange(domain accesstypes.Domain, role accesstypes.Role, permissions ...accesstypes.Permission) Change {
+	c := rolePermissionsChange(domain, role, effectAllow, permissions...)
+	c.remove = true
+
+	return c
+}
+
+// AddRolePermissionResourcesChange grants permission on resources to role in domain.
+func AddRolePermissionResourcesChange(domain accesstypes.Domain, role accesstypes.Role, permission accesstypes.Permission, resources ...accesstypes.Resource) Change {
+	return rolePermissionResourcesChange(domain, role, effectAllow, permission, resources...)
+}
+
+// DeleteRolePermissionResourcesChange removes permission on resources from role in domain.
+func DeleteRolePermissionResourcesChange(domain accesstypes.Domain, role accesstypes.Role, permission accesstypes.Permission, resources ...accesstypes.Resource) Change {
+	c := rolePermissionResourcesChange(domain, role, effectAllow, permission, resources...)
+	c.remove = true
+
+	return c
+}
+
+// AddRolePermissionDenialsChange denies global permissions to role in domain.
+func AddRolePermissionDenialsChange(domain accesstypes.Domain, role accesstypes.Role, permissions ...accesstypes.Permission) Change {
+	return rolePermissionsChange(domain, role, effectDeny, permissions...)
+}
+
+// DeleteRolePermissionDenialsChange removes global permission denials from role in domain.
+func DeleteRolePermissionDenialsChange(domain accesstypes.Domain, role accesstypes.Role, permissions ...accesstypes.Permission) Change {
+	c := rolePermissionsChange(domain, role, effectDeny, permissions...)
+	c.remove = true
+
+	return c
+}
+
+// AddRolePermissionResourceDenialsChange denies permission on resources to role in domain.
+func AddRolePermissionResourceDenialsChange(domain accesstypes.Domain, role accesstypes.Role, permission accesstypes.Permission, resources ...accesstypes.Resource) Change {
+	return rolePermissionResourcesChange(domain, role, effectDeny, permission, resources...)
+}
+
+// DeleteRolePermissionResourceDenialsChange removes permission denials on resources from role in domain.
+func DeleteRolePermissionResourceDenialsChange(
+	domain accesstypes.Domain, role accesstypes.Role, permission accesstypes.Permission, resources ...accesstypes.Resource,
+) Change {
+	c := rolePermissionResourcesChange(domain, role, effectDeny, permission, resources...)
+	c.remove = true
+
+	return c
+}
+
+func rolePermissionsChange(domain accesstypes.Domain, role accesstypes.Role, effect string, permissions ...accesstypes.Permission) Change {
+	c := Change{domain: domain, role: role, ptype: permissionPolicy}
+	for _, permission := range permissions {
+		if permission == "" {
+			c.invalid = "permission cannot be empty string"
+		}
+		c.rules = append(c.rules, []string{role.Marshal(), domain.Marshal(), accesstypes.GlobalResource.Marshal(), permission.Marshal(), effect})
+	}
+
+	return c
+}
+
+func rolePermissionResourcesChange(
+	domain accesstypes.Domain, role accesstypes.Role, effect string, permission accesstypes.Permission, resources ...accesstypes.Resource,
+) Change {
+	c := Change{domain: domain, role: role, ptype: permissionPolicy}
+	for _, resource := range resources {
+		if resource == "" {
+			c.invalid = "resource cannot be empty string"
+		}
+		c.rules = append(c.rules, []string{role.Marshal(), domain.Marshal(), resource.Marshal(), permission.Marshal(), effect})
+	}
+
+	return c
+}
+
+// appliedChange records the policies a change actually added or removed so they can be rolled back.
+type appliedChange struct {
+	ptype  policyType
+	remove bool
+	rules  [][]string
+}
+
+func (a appliedChange) inverse() appliedChange {
+	return appliedChange{ptype: a.ptype, remove: !a.remove, rules: a.rules}
+}
+
+// assignsUsers reports whether the change assigns role to users or removes them from it.
+func (c Change) assignsUsers() bool {
+	return c.ptype == groupingPolicy && c.parents == nil
+}
+
+// plan makes the change to the planned policy and returns the rules it removes and adds. Like UserManager.AddRoleUsers
+// and UserManager.DeleteRoleUsers, role assignments are matched on user, role and domain whatever their expiry, so
+// adding an assignment replaces the time-bound ones and removing it removes them too.
+func (c Change) plan(planned model.Model) ([]appliedChange, error) {
+	sec := c.ptype.section()
+
+	removed := appliedChange{ptype: c.ptype, remove: true}
+	added := appliedChange{ptype: c.ptype}
+	switch {
+	case c.assignsUsers():
+		for _, rule := range c.rules {
+			assignments, err := planned.GetFilteredPolicy(sec, sec, 0, rule[:3]...)
+			if err != nil {
+				return nil, errors.Wrap(err, "model.Model.GetFilteredPolicy()")
+			}
+			for _, assignment := range assignments {
+				_, timed := ruleExpiry(assignment)
+				if !timed && !c.expiresAt.IsZero() {
+					return nil, httpio.NewConflictMessagef("user %q already has role %q permanently", string(accesstypes.UnmarshalUser(rule[0])), string(c.role))
+				}
+				if c.remove || timed {
+					removed.rules = append(removed.rules, assignment)
+				}
+			}
+		}
+		if !c.remove {
+			added.rules = c.rules
+		}
+	case c.remove:
+		removed.rules = c.rules
+	default:
+		added.rules = c.rules
+	}
+
+	if _, err := planned.RemovePolicies(sec, sec, removed.rules); err != nil {
+		return nil, errors.Wrap(err, "model.Model.RemovePolicies()")
+	}
+	if err := planned.AddPolicies(sec, sec, added.rules); err != nil {
+		return nil, errors.Wrap(err, "model.Model.AddPolicies()")
+	}
+
+	return []appliedChange{removed, added}, nil
+}
+
+// Apply validates and applies all changes as a unit. The changes are first made in order to a copy of the policy,
+// which is checked for parents that would leave a role inheriting itself. Their combined effect is then written with
+// one call per policy type to add and one to remove, and if a write fails the writes already made are undone.
+// Adapters that don't implement persist.BatchAdapter write one policy at a time.
+// Apply holds the enforcer's write lock throughout, so other writes and enforcement never see part of the changes.
+func (u *userManager) Apply(ctx context.Context, changes ...Change) error {
+	_, span := tracer.Start(ctx)
+	defer span.End()
+
+	for _, c := range changes {
+		if c.invalid != "" {
+			return httpio.NewBadRequestMessage(c.invalid)
+		}
+		if !c.expiresAt.IsZero() && !c.expiresAt.After(time.Now()) {
+			return httpio.NewBadRequestMessage("expiry must be in the future")
+		}
+	}
+
+	enforcer, err := u.Enforcer()
+	if err != nil {
+		return errors.Wrap(err, "userManager.Enforcer()")
+	}
+
+	enforcer, unlock := lockEnforcer(enforcer)
+	defer unlock()
+
+	for _, c := range changes {
+		roles := enforcer.GetRolesForUserInDomain(accesstypes.NoopUser, c.domain.Marshal())
+		for _, role := range append([]accesstypes.Role{c.role}, c.parents...) {
+			if !slices.Contains(roles, role.Marshal()) {
+				return httpio.NewNotFoundMessagef("role %q is not a valid role. Please check that the role exists.", string(role))
+			}
+		}
+	}
+
+	writes, err := planChanges(enforcer, changes)
+	if err != nil {
+		return err
+	}
+
+	_, batch := enforcer.GetAdapter().(persist.BatchAdapter)
+
+	applied := make([]appliedChange, 0, len(writes))
+	for _, w := range writes {
+		if err := applyChange(enforcer, w, batch); err != nil {
+			if rollbackErr := rollback(enforcer, applied, batch); rollbackErr != nil {
+				return errors.Wrapf(err, "rollback failed: %s", rollbackErr)
+			}
+
+			return err
+		}
+
+		applied = append(applied, w)
+	}
+
+	for _, c := range changes {
+		if !c.expiresAt.IsZero() {
+			u.scheduleExpiry(c.expiresAt)
+		}
+	}
+
+	return nil
+}
+
+// lockEnforcer takes the write lock of a synced enforcer and returns the enforcer to use until unlock is called,
+// which doesn't take the lock again. Other enforcers are returned as they are.
+func lockEnforcer(enforcer casbin.IEnforcer) (locked casbin.IEnforcer, unlock func()) {
+	synced, ok := enforcer.(*casbin.SyncedEnforcer)
+	if !ok {
+		return enforcer, func() {}
+	}
+
+	synced.GetLock().Lock()
+
+	return synced.Enforcer, synced.GetLock().Unlock
+}
+
+// planChanges makes changes in order to a copy of the policy and returns the writes that have the same effect:
+// the rules removed and added for each policy type. Errors if a parent added by changes inherits the role it was
+// added to once all changes are made.
+func planChanges(enforcer casbin.IEnforcer, changes []Change) ([]appliedChange, error) {
+	current := enforcer.GetModel()
+	planned := current.Copy()
+
+	var effects []appliedChange
+	for _, c := range changes {
+		effect, err := c.plan(planned)
+		if err != nil {
+			return nil, err
+		}
+		effects = append(effects, effect...)
+	}
+
+	if err := checkCycles(planned, changes); err != nil {
+		return nil, err
+	}
+
+	var writes []appliedChange
+	for _, remove := range []bool{true, false} {
+		for _, ptype := range []policyType{groupingPolicy, permissionPolicy} {
+			w := appliedChange{ptype: ptype, remove: remove}
+			for _, e := range effects {
+				if e.ptype != ptype || e.remove != remove {
+					continue
+				}
+
+				rules, err := pendingRules(current, planned, ptype, remove, e.rules)
+				if err != nil {
+					return nil, err
+				}
+				for _, rule := range rules {
+					if !slices.ContainsFunc(w.rules, func(r []string) bool { return slices.Equal(r, rule) }) {
+						w.rules = append(w.rules, rule)
+					}
+				}
+			}
+
+			if len(w.rules) > 0 {
+				writes = append(writes, w)
+			}
+		}
+	}
+
+	return writes, nil
+}
+
+// checkCycles returns an error if a parent added by changes inherits the role it was added to in the planned policy.
+func checkCycles(planned model.Model, changes []Change) error {
+	if !slices.ContainsFunc(changes, func(c Change) bool { return !c.remove && len(c.parents) > 0 }) {
+		return nil
+	}
+
+	enforcer, err := casbin.NewEnforcer(planned)
+	if err != nil {
+		return errors.Wrap(err, "casbin.NewEnforcer()")
+	}
+	if err := enforcer.BuildRoleLinks(); err != nil {
+		return errors.Wrap(err, "casbin.Enforcer.BuildRoleLinks()")
+	}
+
+	for _, c := range changes {
+		if c.remove {
+			continue
+		}
+
+		for _, parent := range c.parents {
+			if cycle, err := inherits(enforcer, c.domain, parent, c.role); err != nil {
+				return err
+			} else if cycle {
+				return httpio.NewBadRequestMessagef("role %q cannot inherit %q because %q inherits %q", string(c.role), string(parent), string(parent), string(c.role))
+			}
+		}
+	}
+
+	return nil
+}
+
+// pendingRules returns the rules that are written to go from the current to the planned policy: the rules missing
+// from the current policy that are in the planned one when adding, and the other way round when removing.
+func pendingRules(current, planned model.Model, ptype policyType, remove bool, rules [][]string) ([][]string, error) {
+	from, to := current, planned
+	if remove {
+		from, to = planned, current
+	}
+
+	sec := ptype.section()
+	pending := make([][]string, 0, len(rules))
+	for _, rule := range rules {
+		had, err := from.HasPolicy(sec, sec, rule)
+		if err != nil {
+			return nil, errors.Wrap(err, "model.Model.HasPolicy()")
+		}
+		has, err := to.HasPolicy(sec, sec, rule)
+		if err != nil {
+			return nil, errors.Wrap(err, "model.Model.HasPolicy()")
+		}
+		if !had && has {
+			pending = append(pending, rule)
+		}
+	}
+
+	return pending, nil
+}
+
+func applyChange(enforcer casbin.IEnforcer, a appliedChange, batch bool) error {
+	if batch {
+		return applyRules(enforcer, a)
+	}
+
+	// casbin's batch methods require a batch adapter, so write one policy at a time and undo the ones already written if a later one fails
+	for i, rule := range a.rules {
+		if err := applyRule(enforcer, a.ptype, a.remove, rule); err != nil {
+			if rollbackErr := applyChange(enforcer, appliedChange{ptype: a.ptype, remove: !a.remove, rules: a.rules[:i]}, false); rollbackErr != nil {
+				return errors.Wrapf(err, "rollback failed: %s", rollbackErr)
+			}
+
+			return err
+		}
+	}
+
+	return nil
+}
+
+func applyRules(enforcer casbin.IEnforcer, a appliedChange) error {
+	switch {
+	case a.ptype == groupingPolicy && a.remove:
+		if _, err := enforcer.RemoveGroupingPolicies(a.rules); err != nil {
+			return errors.Wrap(err, "enforcer.RemoveGroupingPolicies()")
+		}
+	case a.ptype == groupingPolicy:
+		if _, err := enforcer.AddGroupingPolicies(a.rules); err != nil {
+			return errors.Wrap(err, "enforcer.AddGroupingPolicies()")
+		}
+	case a.remove:
+		if _, err := enforcer.RemovePolicies(a.rules); err != nil {
+			return errors.Wrap(err, "enforcer.RemovePolicies()")
+		}
+	default:
+		if _, err := enforcer.AddPolicies(a.rules); err != nil {
+			return errors.Wrap(err, "enforcer.AddPolicies()")
+		}
+	}
+
+	return nil
+}
+
+func applyRule(enforcer casbin.IEnforcer, ptype policyType, remove bool, rule []string) error {
+	switch {
+	case ptype == groupingPolicy && remove:
+		if _, err := enforcer.RemoveGroupingPolicy(rule); err != nil {
+			return errors.Wrap(err, "enforcer.RemoveGroupingPolicy()")
+		}
+	case ptype == groupingPolicy:
+		if _, err := enforcer.AddGroupingPolicy(rule); err != nil {
+			return errors.Wrap(err, "enforcer.AddGroupingPolicy()")
+		}
+	case remove:
+		if _, err := enforcer.RemovePolicy(rule); err != nil {
+			return errors.Wrap(err, "enforcer.RemovePolicy()")
+		}
+	default:
+		if _, err := enforcer.AddPolicy(rule); err != nil {
+			return errors.Wrap(err, "enforcer.AddPolicy()")
+		}
+	}
+
+	return nil
+}
+
+// rollback undoes applied changes in reverse order.
+func rollback(enforcer casbin.IEnforcer, applied []appliedChange, batch bool) error {
+	for _, a := range slices.Backward(applied) {
+		if err := applyChange(enforcer, a.inverse(), batch); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
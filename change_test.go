@@ -0,0 +1,390 @@
+package access
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/casbin/casbin/v2"
+	"github.com/casbin/casbin/v2/model"
+	"github.com/casbin/casbin/v2/persist"
+	fileadapter "github.com/casbin/casbin/v2/persist/file-adapter"
+	"github.com/cccteam/ccc/accesstypes"
+	"github.com/go-playground/errors/v5"
+	"github.com/google/go-cmp/cmp"
+)
+
+const brokenResource accesstypes.Resource = "Broken"
+
+// singleAdapter saves one policy at a time and fails to save any policy on brokenResource.
+type singleAdapter struct{}
+
+func (singleAdapter) LoadPolicy(model.Model) error { return nil }
+func (singleAdapter) SavePolicy(model.Model) error { return nil }
+func (singleAdapter) AddPolicy(_, _ string, rule []string) error {
+	if slices.Contains(rule, brokenResource.Marshal()) {
+		return errors.New("failed to save policy")
+	}
+
+	return nil
+}
+func (singleAdapter) RemovePolicy(string, string, []string) error               { return nil }
+func (singleAdapter) RemoveFilteredPolicy(string, string, int, ...string) error { return nil }
+
+var _ persist.BatchAdapter = batchAdapter{}
+
+// batchAdapter saves policies in batches and fails to save any batch with a policy on brokenResource.
+type batchAdapter struct {
+	singleAdapter
+}
+
+func (b batchAdapter) AddPolicies(sec, ptype string, rules [][]string) error {
+	for _, rule := range rules {
+		if err := b.AddPolicy(sec, ptype, rule); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+func (batchAdapter) RemovePolicies(string, string, [][]string) error { return nil }
+
+func Test_userManager_Apply(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		adapter   persist.Adapter
+		changes   []Change
+		wantErr   bool
+		wantRoles []string
+		wantPerms [][]string
+	}{
+		{
+			name:    "applies all changes",
+			adapter: batchAdapter{},
+			changes: []Change{
+				AddRoleUsersChange("tenant1", "Administrator", "zach"),
+				AddRolePermissionResourcesChange("tenant1", "Administrator", "ViewUsers", "Users", "Roles"),
+				AddRolePermissionDenialsChange("tenant1", "Administrator", "DeleteUsers"),
+			},
+			wantRoles: []string{"role:Administrator"},
+			wantPerms: [][]string{
+				{"role:Administrator", "domain:tenant1", "resource:Users", "perm:ViewUsers", "allow"},
+				{"role:Administrator", "domain:tenant1", "resource:Roles", "perm:ViewUsers", "allow"},
+				{"role:Administrator", "domain:tenant1", "resource:global", "perm:DeleteUsers", "deny"},
+			},
+		},
+		{
+			name:    "applies changes without batch support",
+			adapter: singleAdapter{},
+			changes: []Change{
+				AddRoleUsersChange("tenant1", "Administrator", "zach"),
+				AddRolePermissionsChange("tenant1", "Administrator", "ViewUsers"),
+			},
+			wantRoles: []string{"role:Administrator"},
+			wantPerms: [][]string{
+				{"role:Administrator", "domain:tenant1", "resource:global", "perm:ViewUsers", "allow"},
+			},
+		},
+		{
+			name:    "later changes remove earlier ones",
+			adapter: batchAdapter{},
+			changes: []Change{
+				AddRolePermissionsChange("tenant1", "Administrator", "ViewUsers", "AddUsers"),
+				DeleteRolePermissionsChange("tenant1", "Administrator", "ViewUsers"),
+			},
+			wantPerms: [][]string{
+				{"role:Administrator", "domain:tenant1", "resource:global", "perm:AddUsers", "allow"},
+			},
+		},
+		{
+			name:    "rolls back batch changes when a change fails",
+			adapter: batchAdapter{},
+			changes: []Change{
+				AddRoleUsersChange("tenant1", "Administrator", "zach"),
+				AddRolePermissionResourcesChange("tenant1", "Administrator", "ViewUsers", "Users", brokenResource),
+			},
+			wantErr: true,
+		},
+		{
+			name:    "rolls back single changes when a policy fails",
+			adapter: singleAdapter{},
+			changes: []Change{
+				AddRoleUsersChange("tenant1", "Administrator", "zach"),
+				AddRolePermissionResourcesChange("tenant1", "Administrator", "ViewUsers", "Users", "Roles", brokenResource),
+			},
+			wantErr: true,
+		},
+		{
+			name:    "rolls back parent changes that create a cycle",
+			adapter: batchAdapter{},
+			changes: []Change{
+				AddRoleUsersChange("tenant1", "Administrator", "zach"),
+				AddRoleParentsChange("tenant2", "Editor", "Viewer"),
+				AddRoleParentsChange("tenant2", "Viewer", "Auditor"),
+				AddRoleParentsChange("tenant2", "Auditor", "Editor"),
+			},
+			wantErr: true,
+		},
+		{
+			name:    "applies nothing when a role doesn't exist",
+			adapter: batchAdapter{},
+			changes: []Change{
+				AddRoleUsersChange("tenant1", "Administrator", "zach"),
+				AddRolePermissionsChange("tenant1", "Editor", "ViewUsers"),
+			},
+			wantErr: true,
+		},
+		{
+			name:    "applies nothing when a change is invalid",
+			adapter: batchAdapter{},
+			changes: []Change{
+				AddRoleUsersChange("tenant1", "Administrator", "zach"),
+				AddRolePermissionResourcesChange("tenant1", "Administrator", "ViewUsers", ""),
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			m, err := model.NewModelFromString(rbacModel())
+			if err != nil {
+				t.Fatalf("model.NewModelFromString() error = %v", err)
+			}
+			enforcer, err := casbin.NewSyncedEnforcer(m, fileadapter.NewAdapter("testdata/policy.csv"))
+			if err != nil {
+				t.Fatalf("casbin.NewSyncedEnforcer() error = %v", err)
+			}
+			enforcer.SetAdapter(tt.adapter)
+
+			u := &userManager{
+				Enforcer: func() (casbin.IEnforcer, error) {
+					return enforcer, nil
+				},
+			}
+
+			if err := u.Apply(context.Background(), tt.changes...); (err != nil) != tt.wantErr {
+				t.Fatalf("userManager.Apply() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			roles, err := enforcer.GetRolesForUser(accesstypes.User("zach").Marshal(), accesstypes.Domain("tenant1").Marshal())
+			if err != nil {
+				t.Fatalf("enforcer.GetRolesForUser() error = %v", err)
+			}
+			if !slices.Equal(roles, tt.wantRoles) {
+				t.Errorf("enforcer.GetRolesForUser() = %v, want %v", roles, tt.wantRoles)
+			}
+
+			perms, err := enforcer.GetFilteredPolicy(0, accesstypes.Role("Administrator").Marshal(), accesstypes.Domain("tenant1").Marshal())
+			if err != nil {
+				t.Fatalf("enforcer.GetFilteredPolicy() error = %v", err)
+			}
+			if len(perms) != len(tt.wantPerms) {
+				t.Fatalf("enforcer.GetFilteredPolicy() = %v, want %v", perms, tt.wantPerms)
+			}
+			for _, want := range tt.wantPerms {
+				if !slices.ContainsFunc(perms, func(p []string) bool { return slices.Equal(p, want) }) {
+					t.Errorf("enforcer.GetFilteredPolicy() = %v, missing %v", perms, want)
+				}
+			}
+		})
+	}
+}
+
+// writeRecordingAdapter is a batch adapter that records each write.
+type writeRecordingAdapter struct {
+	batchAdapter
+
+	mu     sync.Mutex
+	writes []string
+}
+
+func (w *writeRecordingAdapter) record(op, ptype string, rules [][]string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.writes = append(w.writes, fmt.Sprintf("%s %s %d", op, ptype, len(rules)))
+}
+
+func (w *writeRecordingAdapter) AddPolicies(sec, ptype string, rules [][]string) error {
+	w.record("add", ptype, rules)
+
+	return w.batchAdapter.AddPolicies(sec, ptype, rules)
+}
+
+func (w *writeRecordingAdapter) RemovePolicies(_, ptype string, rules [][]string) error {
+	w.record("remove", ptype, rules)
+
+	return nil
+}
+
+func Test_userManager_Apply_writes(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		changes []Change
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "writes each policy type in one call",
+			changes: []Change{
+				AddRoleUsersChange("tenant1", "Administrator", "zach"),
+				AddRolePermissionsChange("tenant1", "Administrator", "ViewUsers"),
+				AddRoleUsersChange("tenant1", "Administrator", "yolanda"),
+				AddRolePermissionResourcesChange("tenant1", "Administrator", "ViewUsers", "Users", "Roles"),
+				DeleteRolePermissionsChange("tenant1", "Administrator", "ViewUsers"),
+			},
+			want: []string{"add g 2", "add p 2"},
+		},
+		{
+			name: "writes removals before additions",
+			changes: []Change{
+				AddRolePermissionsChange("tenant1", "Administrator", "ViewUsers"),
+				DeleteRoleUsersChange("tenant1", "Administrator", "charlie"),
+			},
+			want: []string{"remove g 1", "add p 1"},
+		},
+		{
+			name: "writes nothing when parents create a cycle",
+			changes: []Change{
+				AddRoleUsersChange("tenant1", "Administrator", "zach"),
+				AddRoleParentsChange("tenant2", "Editor", "Viewer"),
+				AddRoleParentsChange("tenant2", "Viewer", "Auditor"),
+				AddRoleParentsChange("tenant2", "Auditor", "Editor"),
+			},
+			wantErr: true,
+		},
+		{
+			name: "writes nothing when changes cancel out",
+			changes: []Change{
+				AddRoleUsersChange("tenant1", "Administrator", "zach"),
+				DeleteRoleUsersChange("tenant1", "Administrator", "zach"),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			m, err := model.NewModelFromString(rbacModel())
+			if err != nil {
+				t.Fatalf("model.NewModelFromString() error = %v", err)
+			}
+			enforcer, err := casbin.NewSyncedEnforcer(m, fileadapter.NewAdapter("testdata/policy.csv"))
+			if err != nil {
+				t.Fatalf("casbin.NewSyncedEnforcer() error = %v", err)
+			}
+			adapter := &writeRecordingAdapter{}
+			enforcer.SetAdapter(adapter)
+
+			u := &userManager{
+				Enforcer: func() (casbin.IEnforcer, error) {
+					return enforcer, nil
+				},
+			}
+
+			if err := u.Apply(context.Background(), tt.changes...); (err != nil) != tt.wantErr {
+				t.Fatalf("userManager.Apply() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if diff := cmp.Diff(tt.want, adapter.writes); diff != "" {
+				t.Errorf("adapter writes mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_userManager_Apply_timedAssignments(t *testing.T) {
+	t.Parallel()
+
+	const policy = `g, noop, role:Editor, domain:tenant1
+g, user:alice, role:Editor, domain:tenant1, 2999-01-01T00:00:00Z
+g, user:bob, role:Editor, domain:tenant1
+`
+
+	tests := []struct {
+		name      string
+		changes   []Change
+		want      string
+		wantRoles []string
+		wantErr   bool
+	}{
+		{
+			name:    "removes time-bound assignments",
+			changes: []Change{DeleteRoleUsersChange("tenant1", "Editor", "alice")},
+			want: `g, noop, role:Editor, domain:tenant1
+g, user:bob, role:Editor, domain:tenant1
+`,
+			wantRoles: []string{},
+		},
+		{
+			name:    "replaces time-bound assignments",
+			changes: []Change{AddRoleUsersChange("tenant1", "Editor", "alice", "bob")},
+			want: `g, noop, role:Editor, domain:tenant1
+g, user:bob, role:Editor, domain:tenant1
+g, user:alice, role:Editor, domain:tenant1
+`,
+			wantRoles: []string{"role:Editor"},
+		},
+		{
+			name:    "replaces time-bound assignments with a new expiry",
+			changes: []Change{AddRoleUsersUntilChange("tenant1", "Editor", time.Date(2999, 6, 1, 0, 0, 0, 0, time.UTC), "alice")},
+			want: `g, noop, role:Editor, domain:tenant1
+g, user:bob, role:Editor, domain:tenant1
+g, user:alice, role:Editor, domain:tenant1, 2999-06-01T00:00:00Z
+`,
+			wantRoles: []string{"role:Editor"},
+		},
+		{
+			name:      "fails to add a time-bound assignment to a permanent one",
+			changes:   []Change{AddRoleUsersUntilChange("tenant1", "Editor", time.Date(2999, 6, 1, 0, 0, 0, 0, time.UTC), "alice", "bob")},
+			want:      policy,
+			wantRoles: []string{"role:Editor"},
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			memory, err := NewMemoryAdapter(policy)
+			if err != nil {
+				t.Fatalf("NewMemoryAdapter() error = %v", err)
+			}
+			enforcer, err := casbin.NewSyncedEnforcer(newTestModel(t), memory)
+			if err != nil {
+				t.Fatalf("casbin.NewSyncedEnforcer() error = %v", err)
+			}
+
+			u := &userManager{
+				Enforcer: func() (casbin.IEnforcer, error) {
+					return enforcer, nil
+				},
+			}
+
+			if err := u.Apply(context.Background(), tt.changes...); (err != nil) != tt.wantErr {
+				t.Fatalf("userManager.Apply() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if diff := cmp.Diff(tt.want, memory.Policy()); diff != "" {
+				t.Errorf("MemoryAdapter.Policy() mismatch (-want +got):\n%s", diff)
+			}
+
+			roles, err := enforcer.GetRolesForUser(accesstypes.User("alice").Marshal(), accesstypes.Domain("tenant1").Marshal())
+			if err != nil {
+				t.Fatalf("enforcer.GetRolesForUser() error = %v", err)
+			}
+			if diff := cmp.Diff(tt.wantRoles, roles); diff != "" {
+				t.Errorf("enforcer.GetRolesForUser() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
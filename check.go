@@ -0,0 +1,87 @@
+package access
+
+import (
+	"context"
+
+	"github.com/cccteam/ccc/accesstypes"
+	"github.com/cccteam/ccc/tracer"
+	"github.com/cccteam/httpio"
+	"github.com/go-playground/errors/v5"
+)
+
+// CheckRequest is a single permission check made by CheckMany.
+type CheckRequest struct {
+	User       accesstypes.User
+	Domain     accesstypes.Domain
+	Permission accesstypes.Permission
+	// Resource the permission is checked on. Defaults to accesstypes.GlobalResource when empty.
+	Resource accesstypes.Resource
+}
+
+// CheckResult is the result of a CheckRequest.
+type CheckResult struct {
+	CheckRequest
+	Allowed bool
+	// Err is set when the request could not be checked, such as when its domain is invalid. Allowed is false.
+	Err error
+}
+
+// CheckMany makes many permission checks at once and returns a result for each request, in the same order.
+// Each domain is validated once, and a request with an invalid domain gets an error in its result instead of
+// failing the others. Errors if the domains or policy can't be read.
+func (c *Client) CheckMany(ctx context.Context, requests []CheckRequest) ([]CheckResult, error) {
+	ctx, span := tracer.Start(ctx)
+	defer span.End()
+
+	validDomains := make(map[accesstypes.Domain]bool)
+	for _, req := range requests {
+		if _, ok := validDomains[req.Domain]; ok {
+			continue
+		}
+
+		exists, err := c.userManager.DomainExists(ctx, req.Domain)
+		if err != nil {
+			return nil, err
+		}
+		validDomains[req.Domain] = exists
+	}
+
+	enforcer, err := c.userManager.Enforcer()
+	if err != nil {
+		return nil, errors.Wrap(err, "userManager.Enforcer()")
+	}
+
+	results := make([]CheckResult, len(requests))
+	enforceRequests := make([][]any, 0, len(requests))
+	enforceIndexes := make([]int, 0, len(requests))
+	for i, req := range requests {
+		if req.Resource == "" {
+			req.Resource = accesstypes.GlobalResource
+		}
+		results[i].CheckRequest = req
+
+		if !validDomains[req.Domain] {
+			results[i].Err = httpio.NewBadRequestMessage("Invalid Domain")
+
+			continue
+		}
+
+		enforceRequests = append(enforceRequests, []any{req.User.Marshal(), req.Domain.Marshal(), req.Resource.Marshal(), req.Permission.Marshal()})
+		enforceIndexes = append(enforceIndexes, i)
+	}
+
+	if len(enforceRequests) == 0 {
+		return results, nil
+	}
+
+	allowed, err := enforcer.BatchEnforce(enforceRequests)
+	if err != nil {
+		return nil, errors.Wrap(err, "casbin.IEnforcer.BatchEnforce()")
+	}
+
+	for i, ok := range allowed {
+		results[enforceIndexes[i]].Allowed = ok
+	}
+
+	return results, nil
+}
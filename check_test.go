@@ -0,0 +1,50 @@
+package access
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cccteam/ccc/accesstypes"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"go.uber.org/mock/gomock"
+)
+
+func TestClient_CheckMany(t *testing.T) {
+	t.Parallel()
+
+	domains := NewMockDomains(gomock.NewController(t))
+	domains.EXPECT().DomainExists(gomock.Any(), "tenant1").Return(true, nil).Times(1)
+	domains.EXPECT().DomainExists(gomock.Any(), "tenant2").Return(false, nil).Times(1)
+
+	client, err := New(domains, fileAdapter("testdata/policy_inheritance.csv"))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	requests := []CheckRequest{
+		{User: "alice", Domain: "tenant1", Permission: "ViewUsers"},
+		{User: "alice", Domain: "tenant1", Permission: "DeleteUsers"},
+		{User: "bob", Domain: "tenant1", Permission: "Update", Resource: "Users.name"},
+		{User: "alice", Domain: "tenant2", Permission: "ViewUsers"},
+		{User: "carol", Domain: "tenant1", Permission: "DeleteUsers"},
+		{User: "alice", Domain: "tenant1", Permission: "Update", Resource: "Users.name"},
+	}
+
+	got, err := client.CheckMany(context.Background(), requests)
+	if err != nil {
+		t.Fatalf("Client.CheckMany() error = %v", err)
+	}
+
+	want := []CheckResult{
+		{CheckRequest: CheckRequest{User: "alice", Domain: "tenant1", Permission: "ViewUsers", Resource: accesstypes.GlobalResource}, Allowed: true},
+		{CheckRequest: CheckRequest{User: "alice", Domain: "tenant1", Permission: "DeleteUsers", Resource: accesstypes.GlobalResource}, Allowed: true},
+		{CheckRequest: CheckRequest{User: "bob", Domain: "tenant1", Permission: "Update", Resource: "Users.name"}},
+		{CheckRequest: CheckRequest{User: "alice", Domain: "tenant2", Permission: "ViewUsers", Resource: accesstypes.GlobalResource}, Err: cmpopts.AnyError},
+		{CheckRequest: CheckRequest{User: "carol", Domain: "tenant1", Permission: "DeleteUsers", Resource: accesstypes.GlobalResource}},
+		{CheckRequest: CheckRequest{User: "alice", Domain: "tenant1", Permission: "Update", Resource: "Users.name"}, Allowed: true},
+	}
+	if diff := cmp.Diff(want, got, cmpopts.EquateErrors()); diff != "" {
+		t.Errorf("Client.CheckMany() mismatch (-want +got):\n%s", diff)
+	}
+}
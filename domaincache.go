@@ -0,0 +1,206 @@
+package access
+
+import (
+	"container/list"
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/cccteam/ccc/accesstypes"
+	"github.com/cccteam/ccc/tracer"
+	"github.com/go-playground/errors/v5"
+)
+
+var _ Domains = &DomainCache{}
+
+// DomainCache is a Domains that caches the results of another Domains.
+// DomainExists results are kept for a TTL, which can be shorter for domains that don't exist, and the least recently
+// used results are evicted when the cache is full. DomainIDs is cached for the same TTL. Errors are not cached.
+type DomainCache struct {
+	domains     Domains
+	ttl         time.Duration
+	negativeTTL time.Duration
+	maxSize     int
+	now         func() time.Time
+
+	mu         sync.Mutex
+	entries    map[string]*list.Element
+	lru        *list.List
+	ids        []string
+	idsExpires time.Time
+	// generation counts the invalidations, so results looked up before one are not cached after it
+	generation uint64
+}
+
+type domainCacheEntry struct {
+	domain  string
+	exists  bool
+	expires time.Time
+}
+
+// NewDomainCache creates a DomainCache in front of domains. Results are cached for ttl, domains that don't exist for
+// negativeTTL, and at most maxSize domains are cached. A negativeTTL of zero or less doesn't cache missing domains and
+// a maxSize of zero or less doesn't limit the size.
+func NewDomainCache(domains Domains, ttl, negativeTTL time.Duration, maxSize int) *DomainCache {
+	return &DomainCache{
+		domains:     domains,
+		ttl:         ttl,
+		negativeTTL: negativeTTL,
+		maxSize:     maxSize,
+		now:         time.Now,
+		entries:     make(map[string]*list.Element),
+		lru:         list.New(),
+	}
+}
+
+// DomainIDs returns all domain IDs, from the cache when they were fetched within the TTL.
+func (c *DomainCache) DomainIDs(ctx context.Context) ([]string, error) {
+	ctx, span := tracer.Start(ctx)
+	defer span.End()
+
+	c.mu.Lock()
+	if c.ids != nil && c.now().Before(c.idsExpires) {
+		ids := slices.Clone(c.ids)
+		c.mu.Unlock()
+
+		return ids, nil
+	}
+	generation := c.generation
+	c.mu.Unlock()
+
+	ids, err := c.domains.DomainIDs(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "Domains.DomainIDs()")
+	}
+
+	if c.ttl > 0 {
+		c.mu.Lock()
+		if c.generation == generation {
+			c.ids = slices.Clone(ids)
+			if c.ids == nil {
+				c.ids = []string{}
+			}
+			c.idsExpires = c.now().Add(c.ttl)
+		}
+		c.mu.Unlock()
+	}
+
+	return ids, nil
+}
+
+// DomainExists returns true if domain ID exists, from the cache when it was checked within the TTL.
+func (c *DomainCache) DomainExists(ctx context.Context, domain string) (bool, error) {
+	ctx, span := tracer.Start(ctx)
+	defer span.End()
+
+	exists, generation, ok := c.get(domain)
+	if ok {
+		return exists, nil
+	}
+
+	exists, err := c.domains.DomainExists(ctx, domain)
+	if err != nil {
+		return false, errors.Wrap(err, "Domains.DomainExists()")
+	}
+
+	c.put(domain, exists, generation)
+
+	return exists, nil
+}
+
+// Invalidate removes domains from the cache so they are checked again on next use. Call it when domains are created
+// or deleted. Without domains the whole cache is cleared, including DomainIDs.
+func (c *DomainCache) Invalidate(domains ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	if len(domains) == 0 {
+		clear(c.entries)
+		c.lru.Init()
+		c.ids = nil
+
+		return
+	}
+
+	for _, domain := range domains {
+		if elem, ok := c.entries[domain]; ok {
+			c.remove(elem)
+		}
+	}
+	// the domain list may have changed along with the domains
+	c.ids = nil
+}
+
+// get returns the cached result for domain, or the generation to pass to put when it isn't cached.
+func (c *DomainCache) get(domain string) (exists bool, generation uint64, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[domain]
+	if !ok {
+		return false, c.generation, false
+	}
+
+	entry, _ := elem.Value.(*domainCacheEntry)
+	if !c.now().Before(entry.expires) {
+		c.remove(elem)
+
+		return false, c.generation, false
+	}
+	c.lru.MoveToFront(elem)
+
+	return entry.exists, c.generation, true
+}
+
+// put caches the result for domain unless the cache was invalidated since generation.
+func (c *DomainCache) put(domain string, exists bool, generation uint64) {
+	ttl := c.ttl
+	if !exists {
+		ttl = c.negativeTTL
+	}
+	if ttl <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.generation != generation {
+		return
+	}
+
+	if elem, ok := c.entries[domain]; ok {
+		c.remove(elem)
+	}
+
+	if c.maxSize > 0 {
+		for c.lru.Len() >= c.maxSize {
+			c.remove(c.lru.Back())
+		}
+	}
+
+	c.entries[domain] = c.lru.PushFront(&domainCacheEntry{domain: domain, exists: exists, expires: c.now().Add(ttl)})
+}
+
+func (c *DomainCache) remove(elem *list.Element) {
+	entry, _ := c.lru.Remove(elem).(*domainCacheEntry)
+	delete(c.entries, entry.domain)
+}
+
+// InvalidateDomains removes domains from the domain cache set up with WithDomainCache so they are checked again on
+// next use. Without domains the whole cache is cleared. Does nothing without a domain cache.
+func (c *Client) InvalidateDomains(domains ...accesstypes.Domain) {
+	cache, ok := c.userManager.domains.(*DomainCache)
+	if !ok {
+		return
+	}
+
+	ids := make([]string, 0, len(domains))
+	for _, domain := range domains {
+		ids = append(ids, string(domain))
+	}
+
+	cache.Invalidate(ids...)
+}
@@ -0,0 +1,153 @@
+package access
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cccteam/ccc/accesstypes"
+	"github.com/go-playground/errors/v5"
+	"go.uber.org/mock/gomock"
+)
+
+func TestDomainCache_DomainExists(t *testing.T) {
+	t.Parallel()
+
+	domains := NewMockDomains(gomock.NewController(t))
+	cache := NewDomainCache(domains, time.Minute, time.Second, 2)
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	cache.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	exists := func(domain string, want bool) {
+		t.Helper()
+
+		got, err := cache.DomainExists(ctx, domain)
+		if err != nil {
+			t.Fatalf("DomainCache.DomainExists(%s) error = %v", domain, err)
+		}
+		if got != want {
+			t.Errorf("DomainCache.DomainExists(%s) = %v, want %v", domain, got, want)
+		}
+	}
+
+	// cached for the ttl
+	domains.EXPECT().DomainExists(gomock.Any(), "tenant1").Return(true, nil).Times(1)
+	exists("tenant1", true)
+	exists("tenant1", true)
+
+	// missing domains are cached for the negative ttl
+	domains.EXPECT().DomainExists(gomock.Any(), "missing").Return(false, nil).Times(2)
+	exists("missing", false)
+	exists("missing", false)
+	now = now.Add(2 * time.Second)
+	exists("missing", false)
+
+	// tenant1 was used least recently and is evicted to make room
+	domains.EXPECT().DomainExists(gomock.Any(), "tenant2").Return(true, nil).Times(1)
+	exists("tenant2", true)
+	domains.EXPECT().DomainExists(gomock.Any(), "tenant1").Return(true, nil).Times(1)
+	exists("tenant1", true)
+
+	// invalidated domains are looked up again
+	cache.Invalidate("tenant1")
+	domains.EXPECT().DomainExists(gomock.Any(), "tenant1").Return(false, nil).Times(1)
+	exists("tenant1", false)
+
+	// errors are not cached
+	domains.EXPECT().DomainExists(gomock.Any(), "tenant3").Return(false, errors.New("connection refused")).Times(1)
+	if _, err := cache.DomainExists(ctx, "tenant3"); err == nil {
+		t.Error("DomainCache.DomainExists() error = nil, want error")
+	}
+	domains.EXPECT().DomainExists(gomock.Any(), "tenant3").Return(true, nil).Times(1)
+	exists("tenant3", true)
+
+	// entries expire after the ttl
+	now = now.Add(time.Minute)
+	domains.EXPECT().DomainExists(gomock.Any(), "tenant3").Return(true, nil).Times(1)
+	exists("tenant3", true)
+}
+
+func TestDomainCache_DomainIDs(t *testing.T) {
+	t.Parallel()
+
+	domains := NewMockDomains(gomock.NewController(t))
+	cache := NewDomainCache(domains, time.Minute, 0, 0)
+	ctx := context.Background()
+
+	domains.EXPECT().DomainIDs(gomock.Any()).Return([]string{"tenant1"}, nil).Times(2)
+	for range 2 {
+		if _, err := cache.DomainIDs(ctx); err != nil {
+			t.Fatalf("DomainCache.DomainIDs() error = %v", err)
+		}
+	}
+
+	cache.Invalidate()
+	if _, err := cache.DomainIDs(ctx); err != nil {
+		t.Fatalf("DomainCache.DomainIDs() error = %v", err)
+	}
+}
+
+func TestDomainCache_invalidateDuringLookup(t *testing.T) {
+	t.Parallel()
+
+	domains := NewMockDomains(gomock.NewController(t))
+	cache := NewDomainCache(domains, time.Minute, time.Minute, 0)
+	ctx := context.Background()
+
+	// the domain is created while it is looked up, so the result is stale and must not be cached
+	domains.EXPECT().DomainExists(gomock.Any(), "tenant1").DoAndReturn(func(context.Context, string) (bool, error) {
+		cache.Invalidate("tenant1")
+
+		return false, nil
+	}).Times(1)
+	domains.EXPECT().DomainExists(gomock.Any(), "tenant1").Return(true, nil).Times(1)
+	for _, want := range []bool{false, true, true} {
+		got, err := cache.DomainExists(ctx, "tenant1")
+		if err != nil {
+			t.Fatalf("DomainCache.DomainExists() error = %v", err)
+		}
+		if got != want {
+			t.Errorf("DomainCache.DomainExists() = %v, want %v", got, want)
+		}
+	}
+
+	domains.EXPECT().DomainIDs(gomock.Any()).DoAndReturn(func(context.Context) ([]string, error) {
+		cache.Invalidate()
+
+		return []string{"tenant1"}, nil
+	}).Times(1)
+	domains.EXPECT().DomainIDs(gomock.Any()).Return([]string{"tenant1", "tenant2"}, nil).Times(1)
+	for _, want := range []int{1, 2, 2} {
+		ids, err := cache.DomainIDs(ctx)
+		if err != nil {
+			t.Fatalf("DomainCache.DomainIDs() error = %v", err)
+		}
+		if len(ids) != want {
+			t.Errorf("DomainCache.DomainIDs() = %v, want %d domains", ids, want)
+		}
+	}
+}
+
+func TestNew_WithDomainCache(t *testing.T) {
+	t.Parallel()
+
+	domains := NewMockDomains(gomock.NewController(t))
+	client, err := New(domains, fileAdapter("testdata/policy_inheritance.csv"), WithDomainCache(time.Minute, time.Minute, 100))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	ctx := context.Background()
+
+	domains.EXPECT().DomainExists(gomock.Any(), "tenant1").Return(true, nil).Times(2)
+	for range 3 {
+		if err := client.RequireAll(ctx, "alice", "tenant1", "ViewUsers"); err != nil {
+			t.Fatalf("Client.RequireAll() error = %v", err)
+		}
+	}
+
+	client.InvalidateDomains(accesstypes.Domain("tenant1"))
+	if err := client.RequireAll(ctx, "alice", "tenant1", "ViewUsers"); err != nil {
+		t.Fatalf("Client.RequireAll() error = %v", err)
+	}
+}
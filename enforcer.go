@@ -8,7 +8,10 @@ import (
 	"github.com/go-playground/errors/v5"
 )
 
-func createEnforcer(rbacModel string) (*casbin.SyncedEnforcer, error) {
+// lastGoodPolicyRetryInterval is how long the last good policy is served after a failed reload before trying again.
+const lastGoodPolicyRetryInterval = 10 * time.Second
+
+func createEnforcer(rbacModel string, autoSave bool) (*casbin.SyncedEnforcer, error) {
 	m, err := model.NewModelFromString(rbacModel)
 	if err != nil {
 		return nil, errors.Wrap(err, "model.NewModelFromString()")
@@ -19,73 +22,157 @@ func createEnforcer(rbacModel string) (*casbin.SyncedEnforcer, error) {
 		return nil, errors.Wrapf(err, "casbin.NewSyncedEnforcer()")
 	}
 
-	e.EnableAutoSave(true)
+	e.EnableAutoSave(autoSave)
 
 	return e, nil
 }
 
-func (u *userManager) refreshEnforcer() casbin.IEnforcer {
-	u.initEnforcer()
+func (u *userManager) refreshEnforcer() (casbin.IEnforcer, error) {
+	if err := u.initEnforcer(); err != nil {
+		return nil, err
+	}
 
 	return u.loadPolicy()
 }
 
-func (u *userManager) initEnforcer() {
+func (u *userManager) initEnforcer() error {
 	u.enforcerMu.RLock()
 	if u.enforcerInitialized {
 		u.enforcerMu.RUnlock()
 
-		return
+		return nil
 	}
 	u.enforcerMu.RUnlock()
 
+	if err := u.retry("Adapter.NewAdapter()", u.setAdapter); err != nil {
+		return errors.Wrap(err, "Adapter.NewAdapter(): failed to create casbin adapter")
+	}
+
+	return nil
+}
+
+// setAdapter creates the casbin adapter and sets it on the enforcer, unless another call already did.
+func (u *userManager) setAdapter() error {
 	u.enforcerMu.Lock()
 	defer u.enforcerMu.Unlock()
 
 	if u.enforcerInitialized {
 		// lost race for lock
-		return
+		return nil
 	}
 	// won race for lock
 
 	a, err := u.adapter.NewAdapter()
 	if err != nil {
-		panic(errors.Wrapf(err, "pgxadapter.NewAdapter(): failed to create casbin adapter with db"))
+		return err
 	}
 
-	u.enforcer.SetAdapter(a)
+	u.expiry = newExpiringAdapter(a, u.policyFilter)
+	u.enforcer.SetAdapter(u.expiry)
 
 	u.enforcerInitialized = true
+
+	return nil
 }
 
-func (u *userManager) loadPolicy() casbin.IEnforcer {
+func (u *userManager) loadPolicy() (casbin.IEnforcer, error) {
 	u.policyMu.RLock()
 	if u.policyLoaded {
 		defer u.policyMu.RUnlock()
 
-		return u.enforcer
+		return u.enforcer, nil
 	}
 	u.policyMu.RUnlock()
 
+	err := u.retry("casbin.SyncedEnforcer.LoadPolicy()", u.reloadPolicy)
+	if err == nil {
+		return u.enforcer, nil
+	}
+
+	u.policyMu.Lock()
+	defer u.policyMu.Unlock()
+
+	if u.policyLoaded {
+		// loaded by another call while this one was retrying
+		return u.enforcer, nil
+	}
+
+	if !u.serveLastGoodPolicy || !u.lastGoodPolicy {
+		return nil, errors.Wrap(err, "casbin.SyncedEnforcer.LoadPolicy()")
+	}
+
+	// the enforcer keeps its previous policy when loading fails, keep serving it and try again later
+	u.logger.Error("failed to reload casbin policy, serving last good policy", "error", err, "retryIn", lastGoodPolicyRetryInterval)
+	u.policyLoaded = true
+	u.invalidatePolicyAfter(lastGoodPolicyRetryInterval)
+
+	return u.enforcer, nil
+}
+
+// reloadPolicy loads the policy into the enforcer, unless another call already did since it was marked stale.
+func (u *userManager) reloadPolicy() error {
 	u.policyMu.Lock()
 	defer u.policyMu.Unlock()
 
 	if u.policyLoaded {
-		return u.enforcer
+		return nil
 	}
 
 	if err := u.enforcer.LoadPolicy(); err != nil {
-		panic(errors.Wrapf(err, "casbin.SyncedEnforcer.LoadPolicy()"))
+		return err
 	}
 
 	u.policyLoaded = true
+	u.lastGoodPolicy = true
+	u.logger.Debug("loaded casbin policy", "refreshInterval", u.policyRefreshInterval)
+
+	// reload when the next time-bound role assignment expires so it stops being enforced
+	if next := u.expiry.next(); !next.IsZero() {
+		u.scheduleExpiry(next)
+	}
 
-	go func() {
-		time.Sleep(time.Minute)
-		u.policyMu.Lock()
-		u.policyLoaded = false
-		u.policyMu.Unlock()
-	}()
+	// without a refresh interval the policy is only reloaded when a watcher reports a change
+	if u.policyRefreshInterval > 0 {
+		u.invalidatePolicyAfter(u.policyRefreshInterval)
+	}
 
-	return u.enforcer
+	return nil
+}
+
+// retry calls fn until it succeeds or the configured attempts are used up, doubling the backoff after each failure.
+// fn takes the locks it needs itself, so no lock is held while waiting to try again.
+func (u *userManager) retry(operation string, fn func() error) error {
+	backoff := u.loadRetryBackoff
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+		if attempt >= u.loadRetryAttempts {
+			return err
+		}
+
+		u.logger.Warn("casbin operation failed, retrying", "operation", operation, "attempt", attempt, "backoff", backoff, "error", err)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// invalidatePolicyAfter marks the policy as stale after d, replacing the time set by an earlier call.
+// The caller must hold policyMu.
+func (u *userManager) invalidatePolicyAfter(d time.Duration) {
+	if u.refreshTimer == nil {
+		u.refreshTimer = time.AfterFunc(d, func() { u.invalidatePolicy("") })
+
+		return
+	}
+
+	u.refreshTimer.Reset(d)
+}
+
+// invalidatePolicy marks the policy as stale so it is reloaded on the next call to Enforcer().
+func (u *userManager) invalidatePolicy(string) {
+	u.policyMu.Lock()
+	u.policyLoaded = false
+	u.policyMu.Unlock()
 }
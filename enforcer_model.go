@@ -1,5 +1,12 @@
 package access
 
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cccteam/ccc/accesstypes"
+)
+
 // rbacModel returns casbin RBAC model configuration for domain-based access control with allow/deny effects.
 func rbacModel() string {
 	return `
@@ -19,3 +26,13 @@ func rbacModel() string {
 		m = g(r.sub, p.sub, r.dom) && r.dom == p.dom && r.obj == p.obj && r.act == p.act && r.sub != "noop"
 	`
 }
+
+// globalDomainGrantsModel returns the RBAC model with roles assigned in the global domain also applying in every other
+// domain. A user's global role grants the permissions that role has in the domain being checked.
+func globalDomainGrantsModel() string {
+	return strings.Replace(rbacModel(),
+		"g(r.sub, p.sub, r.dom)",
+		fmt.Sprintf("(g(r.sub, p.sub, r.dom) || g(r.sub, p.sub, %q))", accesstypes.GlobalDomain.Marshal()),
+		1,
+	)
+}
@@ -0,0 +1,217 @@
+package access
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/casbin/casbin/v2/model"
+	"github.com/casbin/casbin/v2/persist"
+	fileadapter "github.com/casbin/casbin/v2/persist/file-adapter"
+	"github.com/go-playground/errors/v5"
+)
+
+// flakyAdapter is a file adapter that fails its first loadFailures policy loads, and every load once failing is set.
+type flakyAdapter struct {
+	*fileadapter.Adapter
+	newAdapterErr error
+	loadFailures  atomic.Int32
+	failing       atomic.Bool
+	loads         atomic.Int32
+}
+
+func (f *flakyAdapter) NewAdapter() (persist.Adapter, error) {
+	if f.newAdapterErr != nil {
+		return nil, f.newAdapterErr
+	}
+
+	return f, nil
+}
+
+func (f *flakyAdapter) LoadPolicy(m model.Model) error {
+	f.loads.Add(1)
+	if f.failing.Load() || f.loadFailures.Add(-1) >= 0 {
+		return errors.New("connection refused")
+	}
+
+	return f.Adapter.LoadPolicy(m)
+}
+
+func newFlakyAdapter(loadFailures int32) *flakyAdapter {
+	f := &flakyAdapter{Adapter: fileadapter.NewAdapter("testdata/policy.csv")}
+	f.loadFailures.Store(loadFailures)
+
+	return f
+}
+
+func Test_userManager_RoleExists_enforcerErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		adapter   *flakyAdapter
+		opts      []Option
+		wantLoads int32
+		wantErr   bool
+	}{
+		{
+			name:      "adapter creation fails",
+			adapter:   &flakyAdapter{newAdapterErr: errors.New("connection refused")},
+			opts:      []Option{WithLoadRetry(2, 0)},
+			wantLoads: 0,
+			wantErr:   true,
+		},
+		{
+			name:      "load fails on every attempt",
+			adapter:   newFlakyAdapter(3),
+			opts:      []Option{WithLoadRetry(3, time.Millisecond)},
+			wantLoads: 3,
+			wantErr:   true,
+		},
+		{
+			name:      "load succeeds after retry",
+			adapter:   newFlakyAdapter(2),
+			opts:      []Option{WithLoadRetry(3, time.Millisecond)},
+			wantLoads: 3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			client, err := New(&MockDomains{}, tt.adapter, tt.opts...)
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+
+			exists, err := client.UserManager().RoleExists(context.Background(), "tenant1", "Administrator")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("userManager.RoleExists() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if exists == tt.wantErr {
+				t.Errorf("userManager.RoleExists() = %v, want %v", exists, !tt.wantErr)
+			}
+			if got := tt.adapter.loads.Load(); got != tt.wantLoads {
+				t.Errorf("Adapter.LoadPolicy() calls = %d, want %d", got, tt.wantLoads)
+			}
+		})
+	}
+}
+
+func Test_userManager_loadPolicy_serveLastGoodPolicy(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		opts    []Option
+		wantErr bool
+	}{
+		{
+			name:    "reload failure returns error",
+			opts:    []Option{WithLoadRetry(1, 0)},
+			wantErr: true,
+		},
+		{
+			name: "reload failure serves last good policy",
+			opts: []Option{WithLoadRetry(1, 0), WithServeLastGoodPolicy()},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			adapter := newFlakyAdapter(0)
+			client, err := New(&MockDomains{}, adapter, tt.opts...)
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+
+			if _, err := client.userManager.Enforcer(); err != nil {
+				t.Fatalf("userManager.Enforcer() error = %v", err)
+			}
+
+			adapter.failing.Store(true)
+			client.userManager.invalidatePolicy("")
+
+			exists, err := client.UserManager().RoleExists(context.Background(), "tenant1", "Administrator")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("userManager.RoleExists() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !exists {
+				t.Error("userManager.RoleExists() = false, want true from last good policy")
+			}
+		})
+	}
+}
+
+func Test_userManager_loadPolicy_retryUnlocked(t *testing.T) {
+	t.Parallel()
+
+	adapter := newFlakyAdapter(1)
+	client, err := New(&MockDomains{}, adapter, WithLoadRetry(2, time.Minute))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	go func() { _, _ = client.userManager.Enforcer() }()
+	for adapter.loads.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// the policy lock is free while the load waits to try again
+	done := make(chan struct{})
+	go func() {
+		client.userManager.invalidatePolicy("")
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("userManager.invalidatePolicy() blocked while the policy load was waiting to retry")
+	}
+}
+
+func Test_userManager_reloadPolicy_refreshTimer(t *testing.T) {
+	t.Parallel()
+
+	client, err := New(&MockDomains{}, newFlakyAdapter(0), WithPolicyRefreshInterval(50*time.Millisecond))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	u := client.userManager
+
+	if _, err := u.Enforcer(); err != nil {
+		t.Fatalf("userManager.Enforcer() error = %v", err)
+	}
+	u.policyMu.RLock()
+	timer := u.refreshTimer
+	u.policyMu.RUnlock()
+
+	// each reload resets the same timer instead of starting another one
+	for range 3 {
+		u.invalidatePolicy("")
+		if _, err := u.Enforcer(); err != nil {
+			t.Fatalf("userManager.Enforcer() error = %v", err)
+		}
+	}
+	u.policyMu.RLock()
+	if u.refreshTimer != timer {
+		t.Error("userManager.refreshTimer was replaced on reload, want it reset")
+	}
+	u.policyMu.RUnlock()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		u.policyMu.RLock()
+		loaded := u.policyLoaded
+		u.policyMu.RUnlock()
+		if !loaded {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("policy was not marked stale after the refresh interval")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,220 @@
+package access
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/casbin/casbin/v2"
+	"github.com/cccteam/ccc/accesstypes"
+	"github.com/cccteam/ccc/tracer"
+	"github.com/cccteam/httpio"
+	"github.com/go-playground/errors/v5"
+)
+
+// expiryLayout is the format of the expiry stored as the fourth field of a time-bound role assignment.
+const expiryLayout = time.RFC3339
+
+// ruleExpiry returns the expiry of a grouping policy and whether it has one. An expiry that can't be parsed is
+// returned as the zero time so the assignment is treated as expired.
+func ruleExpiry(rule []string) (time.Time, bool) {
+	if len(rule) < 4 || rule[3] == "" {
+		return time.Time{}, false
+	}
+
+	expiresAt, err := time.Parse(expiryLayout, rule[3])
+	if err != nil {
+		return time.Time{}, true
+	}
+
+	return expiresAt, true
+}
+
+// AddRoleUsersUntil assigns role to users in domain until expiresAt, replacing the time-bound assignments the users
+// already have. Returns an error if the role doesn't exist, expiresAt is not in the future or a user already has the
+// role permanently, which a time-bound assignment would take away when it expires.
+func (u *userManager) AddRoleUsersUntil(
+	ctx context.Context, domain accesstypes.Domain, role accesstypes.Role, expiresAt time.Time, users ...accesstypes.User,
+) error {
+	ctx, span := tracer.Start(ctx)
+	defer span.End()
+
+	if roleFound, err := u.RoleExists(ctx, domain, role); err != nil {
+		return err
+	} else if !roleFound {
+		return httpio.NewNotFoundMessagef("role %q is not a valid role. Please check that the role exists.", string(role))
+	}
+
+	if !expiresAt.After(time.Now()) {
+		return httpio.NewBadRequestMessage("expiry must be in the future")
+	}
+
+	enforcer, err := u.Enforcer()
+	if err != nil {
+		return errors.Wrap(err, "userManager.Enforcer()")
+	}
+
+	// check every user first so nothing is assigned when one of them can't be
+	for _, user := range users {
+		if user == "" {
+			return httpio.NewBadRequestMessage("user cannot be empty string")
+		}
+
+		rules, err := enforcer.GetFilteredGroupingPolicy(0, user.Marshal(), role.Marshal(), domain.Marshal())
+		if err != nil {
+			return errors.Wrap(err, "enforcer.GetFilteredGroupingPolicy()")
+		}
+		for _, rule := range rules {
+			if _, ok := ruleExpiry(rule); !ok {
+				return httpio.NewConflictMessagef("user %q already has role %q permanently", string(user), string(role))
+			}
+		}
+	}
+
+	for _, user := range users {
+		if err := removeTimedAssignments(enforcer, domain, role, user); err != nil {
+			return err
+		}
+
+		if _, err := enforcer.AddGroupingPolicy(user.Marshal(), role.Marshal(), domain.Marshal(), expiresAt.UTC().Format(expiryLayout)); err != nil {
+			return errors.Wrapf(err, "enforcer.AddGroupingPolicy(): role %q to %q", role, user)
+		}
+	}
+
+	u.scheduleExpiry(expiresAt)
+
+	return nil
+}
+
+// DeleteExpiredRoleUsers deletes expired role assignments from storage and returns the ones deleted.
+// Expired assignments are already ignored by enforcement, this keeps them from accumulating. Assignments that fail
+// to delete are tried again on the next call.
+func (u *userManager) DeleteExpiredRoleUsers(ctx context.Context) ([]RoleAssignment, error) {
+	_, span := tracer.Start(ctx)
+	defer span.End()
+
+	// getting the enforcer reloads the policy if an assignment expired since the last load
+	if _, err := u.Enforcer(); err != nil {
+		return nil, errors.Wrap(err, "userManager.Enforcer()")
+	}
+
+	if u.expiry == nil {
+		return nil, nil
+	}
+
+	rules, err := u.expiry.deleteExpired()
+
+	deleted := make([]RoleAssignment, 0, len(rules))
+	for _, rule := range rules {
+		expiresAt, _ := ruleExpiry(rule)
+		deleted = append(deleted, RoleAssignment{
+			Domain:    accesstypes.UnmarshalDomain(rule[2]),
+			Role:      accesstypes.UnmarshalRole(rule[1]),
+			User:      accesstypes.UnmarshalUser(rule[0]),
+			ExpiresAt: expiresAt,
+		})
+	}
+	if err != nil {
+		return deleted, errors.Wrap(err, "expiringAdapter.deleteExpired()")
+	}
+
+	return deleted, nil
+}
+
+// removeTimedAssignments removes time-bound assignments of role to user in domain, leaving a permanent assignment in place.
+func removeTimedAssignments(enforcer casbin.IEnforcer, domain accesstypes.Domain, role accesstypes.Role, user accesstypes.User) error {
+	rules, err := enforcer.GetFilteredGroupingPolicy(0, user.Marshal(), role.Marshal(), domain.Marshal())
+	if err != nil {
+		return errors.Wrap(err, "enforcer.GetFilteredGroupingPolicy()")
+	}
+
+	for _, rule := range rules {
+		if _, ok := ruleExpiry(rule); !ok {
+			continue
+		}
+
+		if _, err := enforcer.RemoveGroupingPolicy(rule); err != nil {
+			return errors.Wrapf(err, "enforcer.RemoveGroupingPolicy(): role %q from %q", role, user)
+		}
+	}
+
+	return nil
+}
+
+// userRoleExpirations returns when the user's time-bound role assignments in domains expire.
+func (u *userManager) userRoleExpirations(
+	user accesstypes.User, domains []accesstypes.Domain,
+) (map[accesstypes.Domain]map[accesstypes.Role]time.Time, error) {
+	enforcer, err := u.Enforcer()
+	if err != nil {
+		return nil, errors.Wrap(err, "userManager.Enforcer()")
+	}
+
+	rules, err := enforcer.GetFilteredGroupingPolicy(0, user.Marshal())
+	if err != nil {
+		return nil, errors.Wrap(err, "enforcer.GetFilteredGroupingPolicy()")
+	}
+
+	var expirations map[accesstypes.Domain]map[accesstypes.Role]time.Time
+	for _, rule := range rules {
+		expiresAt, ok := ruleExpiry(rule)
+		if !ok {
+			continue
+		}
+
+		domain := accesstypes.UnmarshalDomain(rule[2])
+		if !slices.Contains(domains, domain) {
+			continue
+		}
+
+		if expirations == nil {
+			expirations = make(map[accesstypes.Domain]map[accesstypes.Role]time.Time)
+		}
+		if expirations[domain] == nil {
+			expirations[domain] = make(map[accesstypes.Role]time.Time)
+		}
+		expirations[domain][accesstypes.UnmarshalRole(rule[1])] = expiresAt
+	}
+
+	return expirations, nil
+}
+
+// scheduleExpiry reloads the policy when expiresAt passes, unless a reload is already scheduled before then.
+func (u *userManager) scheduleExpiry(expiresAt time.Time) {
+	u.expiryMu.Lock()
+	defer u.expiryMu.Unlock()
+
+	if u.expiryTimer != nil && u.expiryAt.After(time.Now()) && !expiresAt.Before(u.expiryAt) {
+		return
+	}
+
+	if u.expiryTimer != nil {
+		u.expiryTimer.Stop()
+	}
+	u.expiryAt = expiresAt
+	u.expiryTimer = time.AfterFunc(time.Until(expiresAt), func() { u.invalidatePolicy("") })
+}
+
+// RunExpirySweeper calls DeleteExpiredRoleUsers every interval until ctx is done. Failures are logged and retried on
+// the next interval. Run it in its own goroutine.
+func (c *Client) RunExpirySweeper(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			deleted, err := c.UserManager().DeleteExpiredRoleUsers(ctx)
+			if err != nil {
+				c.userManager.logger.ErrorContext(ctx, "failed to delete expired role assignments", "error", err)
+
+				continue
+			}
+			if len(deleted) > 0 {
+				c.userManager.logger.InfoContext(ctx, "deleted expired role assignments", "count", len(deleted))
+			}
+		}
+	}
+}
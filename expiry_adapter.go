@@ -0,0 +1,350 @@
+package access
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/casbin/casbin/v2/model"
+	"github.com/casbin/casbin/v2/persist"
+	"github.com/go-playground/errors/v5"
+)
+
+// notImplemented is the message of the error casbin adapters return for operations they don't support, like the
+// writes of casbin's file adapter.
+const notImplemented = "not implemented"
+
+// errNotImplemented is returned by adapterError for operations the adapter doesn't support.
+var errNotImplemented = errors.New(notImplemented)
+
+var (
+	_ persist.BatchAdapter            = &expiringAdapter{}
+	_ persist.FilteredAdapter         = &expiringAdapter{}
+	_ persist.UpdatableAdapter        = &expiringAdapter{}
+	_ persist.ContextBatchAdapter     = &expiringAdapter{}
+	_ persist.ContextFilteredAdapter  = &expiringAdapter{}
+	_ persist.ContextUpdatableAdapter = &expiringAdapter{}
+)
+
+// expiringAdapter leaves expired role assignments out of the policy it loads, so they are ignored by enforcement
+// until they are deleted from storage. The expired assignments found by the last load are kept for DeleteExpiredRoleUsers.
+//
+// casbin type asserts its adapter for optional interfaces, so expiringAdapter implements all of them. They are
+// forwarded to the wrapped adapter when it implements them, and otherwise built on the methods it has: batches are
+// written one rule at a time and undone if a rule fails, updates remove the old rules and add the new ones, and
+// context methods drop the context.
+// Filtered loading fails for adapters without it, as it does in casbin.
+type expiringAdapter struct {
+	persist.Adapter
+	// filter set with WithPolicyFilter is used by LoadPolicy
+	filter any
+
+	mu         sync.Mutex
+	expired    [][]string
+	nextExpiry time.Time
+}
+
+// newExpiringAdapter wraps a. When filter is not nil, LoadPolicy only loads the rules matching it.
+func newExpiringAdapter(a persist.Adapter, filter any) *expiringAdapter {
+	return &expiringAdapter{Adapter: a, filter: filter}
+}
+
+// LoadPolicy loads the policy and removes assignments that have expired from m. casbin loads into a copy of the
+// model, so a failed load keeps the last policy, which is why the filter is applied here rather than with
+// casbin's LoadFilteredPolicy.
+func (e *expiringAdapter) LoadPolicy(m model.Model) error {
+	if e.filter != nil {
+		return e.LoadFilteredPolicy(m, e.filter)
+	}
+
+	// casbin checks the message of some adapter errors, so they are returned unwrapped
+	if err := e.Adapter.LoadPolicy(m); err != nil {
+		return err
+	}
+
+	return e.removeExpired(m)
+}
+
+// LoadPolicyCtx loads the policy and removes assignments that have expired from m.
+func (e *expiringAdapter) LoadPolicyCtx(ctx context.Context, m model.Model) error {
+	if e.filter != nil {
+		return e.LoadFilteredPolicyCtx(ctx, m, e.filter)
+	}
+
+	a, ok := e.Adapter.(persist.ContextAdapter)
+	if !ok {
+		return e.LoadPolicy(m)
+	}
+
+	if err := a.LoadPolicyCtx(ctx, m); err != nil {
+		return err
+	}
+
+	return e.removeExpired(m)
+}
+
+// LoadFilteredPolicy loads the policy matching filter and removes assignments that have expired from m.
+func (e *expiringAdapter) LoadFilteredPolicy(m model.Model, filter any) error {
+	a, ok := e.Adapter.(persist.FilteredAdapter)
+	if !ok {
+		return errors.New("filtered policies are not supported by this adapter")
+	}
+
+	if err := a.LoadFilteredPolicy(m, filter); err != nil {
+		return err
+	}
+
+	return e.removeExpired(m)
+}
+
+// LoadFilteredPolicyCtx loads the policy matching filter and removes assignments that have expired from m.
+func (e *expiringAdapter) LoadFilteredPolicyCtx(ctx context.Context, m model.Model, filter any) error {
+	a, ok := e.Adapter.(persist.ContextFilteredAdapter)
+	if !ok {
+		return e.LoadFilteredPolicy(m, filter)
+	}
+
+	if err := a.LoadFilteredPolicyCtx(ctx, m, filter); err != nil {
+		return err
+	}
+
+	return e.removeExpired(m)
+}
+
+// IsFiltered returns true if the wrapped adapter loaded a filtered policy.
+func (e *expiringAdapter) IsFiltered() bool {
+	a, ok := e.Adapter.(persist.FilteredAdapter)
+
+	return ok && a.IsFiltered()
+}
+
+// IsFilteredCtx returns true if the wrapped adapter loaded a filtered policy.
+func (e *expiringAdapter) IsFilteredCtx(ctx context.Context) bool {
+	if a, ok := e.Adapter.(persist.ContextFilteredAdapter); ok {
+		return a.IsFilteredCtx(ctx)
+	}
+
+	return e.IsFiltered()
+}
+
+func (e *expiringAdapter) SavePolicyCtx(ctx context.Context, m model.Model) error {
+	if a, ok := e.Adapter.(persist.ContextAdapter); ok {
+		return a.SavePolicyCtx(ctx, m)
+	}
+
+	return e.SavePolicy(m)
+}
+
+func (e *expiringAdapter) AddPolicyCtx(ctx context.Context, sec, ptype string, rule []string) error {
+	if a, ok := e.Adapter.(persist.ContextAdapter); ok {
+		return a.AddPolicyCtx(ctx, sec, ptype, rule)
+	}
+
+	return e.AddPolicy(sec, ptype, rule)
+}
+
+func (e *expiringAdapter) RemovePolicyCtx(ctx context.Context, sec, ptype string, rule []string) error {
+	if a, ok := e.Adapter.(persist.ContextAdapter); ok {
+		return a.RemovePolicyCtx(ctx, sec, ptype, rule)
+	}
+
+	return e.RemovePolicy(sec, ptype, rule)
+}
+
+func (e *expiringAdapter) RemoveFilteredPolicyCtx(ctx context.Context, sec, ptype string, fieldIndex int, fieldValues ...string) error {
+	if a, ok := e.Adapter.(persist.ContextAdapter); ok {
+		return a.RemoveFilteredPolicyCtx(ctx, sec, ptype, fieldIndex, fieldValues...)
+	}
+
+	return e.RemoveFilteredPolicy(sec, ptype, fieldIndex, fieldValues...)
+}
+
+func (e *expiringAdapter) AddPolicies(sec, ptype string, rules [][]string) error {
+	if a, ok := e.Adapter.(persist.BatchAdapter); ok {
+		return a.AddPolicies(sec, ptype, rules)
+	}
+
+	return writeEach(rules,
+		func(rule []string) error { return e.AddPolicy(sec, ptype, rule) },
+		func(rule []string) error { return e.RemovePolicy(sec, ptype, rule) },
+	)
+}
+
+func (e *expiringAdapter) AddPoliciesCtx(ctx context.Context, sec, ptype string, rules [][]string) error {
+	if a, ok := e.Adapter.(persist.ContextBatchAdapter); ok {
+		return a.AddPoliciesCtx(ctx, sec, ptype, rules)
+	}
+
+	return e.AddPolicies(sec, ptype, rules)
+}
+
+func (e *expiringAdapter) RemovePolicies(sec, ptype string, rules [][]string) error {
+	if a, ok := e.Adapter.(persist.BatchAdapter); ok {
+		return a.RemovePolicies(sec, ptype, rules)
+	}
+
+	return writeEach(rules,
+		func(rule []string) error { return e.RemovePolicy(sec, ptype, rule) },
+		func(rule []string) error { return e.AddPolicy(sec, ptype, rule) },
+	)
+}
+
+func (e *expiringAdapter) RemovePoliciesCtx(ctx context.Context, sec, ptype string, rules [][]string) error {
+	if a, ok := e.Adapter.(persist.ContextBatchAdapter); ok {
+		return a.RemovePoliciesCtx(ctx, sec, ptype, rules)
+	}
+
+	return e.RemovePolicies(sec, ptype, rules)
+}
+
+func (e *expiringAdapter) UpdatePolicy(sec, ptype string, oldRule, newRule []string) error {
+	if a, ok := e.Adapter.(persist.UpdatableAdapter); ok {
+		return a.UpdatePolicy(sec, ptype, oldRule, newRule)
+	}
+
+	return e.UpdatePolicies(sec, ptype, [][]string{oldRule}, [][]string{newRule})
+}
+
+func (e *expiringAdapter) UpdatePolicyCtx(ctx context.Context, sec, ptype string, oldRule, newRule []string) error {
+	if a, ok := e.Adapter.(persist.ContextUpdatableAdapter); ok {
+		return a.UpdatePolicyCtx(ctx, sec, ptype, oldRule, newRule)
+	}
+
+	return e.UpdatePolicy(sec, ptype, oldRule, newRule)
+}
+
+func (e *expiringAdapter) UpdatePolicies(sec, ptype string, oldRules, newRules [][]string) error {
+	if a, ok := e.Adapter.(persist.UpdatableAdapter); ok {
+		return a.UpdatePolicies(sec, ptype, oldRules, newRules)
+	}
+
+	if err := e.RemovePolicies(sec, ptype, oldRules); err != nil {
+		return err
+	}
+
+	return e.AddPolicies(sec, ptype, newRules)
+}
+
+func (e *expiringAdapter) UpdatePoliciesCtx(ctx context.Context, sec, ptype string, oldRules, newRules [][]string) error {
+	if a, ok := e.Adapter.(persist.ContextUpdatableAdapter); ok {
+		return a.UpdatePoliciesCtx(ctx, sec, ptype, oldRules, newRules)
+	}
+
+	return e.UpdatePolicies(sec, ptype, oldRules, newRules)
+}
+
+func (e *expiringAdapter) UpdateFilteredPolicies(sec, ptype string, newRules [][]string, fieldIndex int, fieldValues ...string) ([][]string, error) {
+	if a, ok := e.Adapter.(persist.UpdatableAdapter); ok {
+		return a.UpdateFilteredPolicies(sec, ptype, newRules, fieldIndex, fieldValues...)
+	}
+
+	// the rules replaced are only known to the adapter
+	return nil, errors.New("filtered updates are not supported by this adapter")
+}
+
+func (e *expiringAdapter) UpdateFilteredPoliciesCtx(
+	ctx context.Context, sec, ptype string, newRules [][]string, fieldIndex int, fieldValues ...string,
+) ([][]string, error) {
+	if a, ok := e.Adapter.(persist.ContextUpdatableAdapter); ok {
+		return a.UpdateFilteredPoliciesCtx(ctx, sec, ptype, newRules, fieldIndex, fieldValues...)
+	}
+
+	return e.UpdateFilteredPolicies(sec, ptype, newRules, fieldIndex, fieldValues...)
+}
+
+// writeEach writes rules one at a time and, if one fails, undoes the ones already written so a batch is written in
+// full or not at all.
+func writeEach(rules [][]string, write, undo func(rule []string) error) error {
+	for i, rule := range rules {
+		if err := write(rule); err != nil {
+			for _, written := range slices.Backward(rules[:i]) {
+				if undoErr := undo(written); undoErr != nil {
+					return errors.Wrapf(err, "undo failed: %s", undoErr)
+				}
+			}
+
+			// casbin checks the message of some adapter errors, so they are returned unwrapped
+			return err
+		}
+	}
+
+	return nil
+}
+
+// removeExpired removes assignments that have expired from m after a load, and records them and the next expiry.
+func (e *expiringAdapter) removeExpired(m model.Model) error {
+	rules, err := m.GetPolicy("g", "g")
+	if err != nil {
+		return errors.Wrap(err, "model.Model.GetPolicy()")
+	}
+
+	now := time.Now()
+	var expired [][]string
+	var next time.Time
+	for _, rule := range rules {
+		expiresAt, ok := ruleExpiry(rule)
+		if !ok {
+			continue
+		}
+
+		if !expiresAt.After(now) {
+			expired = append(expired, rule)
+		} else if next.IsZero() || expiresAt.Before(next) {
+			next = expiresAt
+		}
+	}
+
+	if len(expired) > 0 {
+		if _, err := m.RemovePolicies("g", "g", expired); err != nil {
+			return errors.Wrap(err, "model.Model.RemovePolicies()")
+		}
+	}
+
+	e.mu.Lock()
+	e.expired = expired
+	e.nextExpiry = next
+	e.mu.Unlock()
+
+	return nil
+}
+
+// next returns when the first assignment in the loaded policy expires, or the zero time if none do.
+func (e *expiringAdapter) next() time.Time {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.nextExpiry
+}
+
+// deleteExpired deletes the expired assignments found by the last load from storage and returns the ones deleted.
+// Assignments that fail to delete are kept and tried again on the next call.
+func (e *expiringAdapter) deleteExpired() ([][]string, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var deleted, failed [][]string
+	var errs []error
+	for _, rule := range e.expired {
+		if err := adapterError(e.Adapter.RemovePolicy("g", "g", rule)); err != nil && !errors.Is(err, errNotImplemented) {
+			failed = append(failed, rule)
+			errs = append(errs, errors.Wrapf(err, "persist.Adapter.RemovePolicy(): %v", rule))
+
+			continue
+		}
+		deleted = append(deleted, rule)
+	}
+	e.expired = failed
+
+	return deleted, errors.Join(errs...)
+}
+
+// adapterError returns errNotImplemented for the errors adapters return for operations they don't support. casbin
+// has no sentinel for them and only checks their message, so adapters create their own.
+func adapterError(err error) error {
+	if err != nil && err.Error() == notImplemented {
+		return errNotImplemented
+	}
+
+	return err
+}
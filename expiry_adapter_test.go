@@ -0,0 +1,119 @@
+package access
+
+import (
+	"testing"
+
+	"github.com/casbin/casbin/v2/persist"
+	fileadapter "github.com/casbin/casbin/v2/persist/file-adapter"
+	"github.com/go-playground/errors/v5"
+	"github.com/google/go-cmp/cmp"
+)
+
+// failingRemoveAdapter fails to remove the rules of user, and returns casbin's unsupported error for the rest.
+type failingRemoveAdapter struct {
+	persist.Adapter
+	user string
+}
+
+func (f *failingRemoveAdapter) RemovePolicy(_, _ string, rule []string) error {
+	if rule[0] == f.user {
+		return errors.New("connection reset")
+	}
+
+	return (&fileadapter.Adapter{}).RemovePolicy("g", "g", rule)
+}
+
+// failingAddAdapter fails to add the rules of user.
+type failingAddAdapter struct {
+	persist.Adapter
+	user string
+}
+
+func (f *failingAddAdapter) AddPolicy(sec, ptype string, rule []string) error {
+	if rule[0] == f.user {
+		return errors.New("connection reset")
+	}
+
+	return f.Adapter.AddPolicy(sec, ptype, rule)
+}
+
+func Test_expiringAdapter_fallbacks(t *testing.T) {
+	t.Parallel()
+
+	memory, err := NewMemoryAdapter("g, user:alice, role:Viewer, domain:tenant1\n")
+	if err != nil {
+		t.Fatalf("NewMemoryAdapter() error = %v", err)
+	}
+	// only the methods of persist.Adapter are visible through the struct
+	e := newExpiringAdapter(struct{ persist.Adapter }{memory}, nil)
+
+	if err := e.AddPolicies("g", "g", [][]string{{"user:bob", "role:Viewer", "domain:tenant1"}, {"user:carol", "role:Viewer", "domain:tenant1"}}); err != nil {
+		t.Fatalf("expiringAdapter.AddPolicies() error = %v", err)
+	}
+	if err := e.UpdatePolicy("g", "g", []string{"user:bob", "role:Viewer", "domain:tenant1"}, []string{"user:bob", "role:Editor", "domain:tenant1"}); err != nil {
+		t.Fatalf("expiringAdapter.UpdatePolicy() error = %v", err)
+	}
+	if err := e.RemovePolicies("g", "g", [][]string{{"user:alice", "role:Viewer", "domain:tenant1"}}); err != nil {
+		t.Fatalf("expiringAdapter.RemovePolicies() error = %v", err)
+	}
+
+	want := "g, user:carol, role:Viewer, domain:tenant1\ng, user:bob, role:Editor, domain:tenant1\n"
+	if diff := cmp.Diff(want, memory.Policy()); diff != "" {
+		t.Errorf("MemoryAdapter.Policy() mismatch (-want +got):\n%s", diff)
+	}
+
+	// a batch that fails part way is undone
+	failing := newExpiringAdapter(&failingAddAdapter{Adapter: memory, user: "user:erin"}, nil)
+	if err := failing.AddPolicies("g", "g", [][]string{{"user:dave", "role:Viewer", "domain:tenant1"}, {"user:erin", "role:Viewer", "domain:tenant1"}}); err == nil {
+		t.Error("expiringAdapter.AddPolicies() error = nil, want error")
+	}
+	if diff := cmp.Diff(want, memory.Policy()); diff != "" {
+		t.Errorf("MemoryAdapter.Policy() after failed batch mismatch (-want +got):\n%s", diff)
+	}
+
+	if err := e.LoadFilteredPolicy(newTestModel(t), SQLFilter{}); err == nil {
+		t.Error("expiringAdapter.LoadFilteredPolicy() error = nil for an adapter without filtering, want error")
+	}
+	if e.IsFiltered() {
+		t.Error("expiringAdapter.IsFiltered() = true, want false")
+	}
+}
+
+func Test_expiringAdapter_deleteExpired(t *testing.T) {
+	t.Parallel()
+
+	memory, err := NewMemoryAdapter(`g, user:alice, role:OnCall, domain:tenant1, 2000-01-01T00:00:00Z
+g, user:bob, role:OnCall, domain:tenant1, 2000-01-01T00:00:00Z
+g, user:carol, role:OnCall, domain:tenant1, 2999-01-01T00:00:00Z
+`)
+	if err != nil {
+		t.Fatalf("NewMemoryAdapter() error = %v", err)
+	}
+	adapter := &failingRemoveAdapter{Adapter: memory, user: "user:bob"}
+	e := newExpiringAdapter(adapter, nil)
+	if err := e.LoadPolicy(newTestModel(t)); err != nil {
+		t.Fatalf("expiringAdapter.LoadPolicy() error = %v", err)
+	}
+
+	deleted, err := e.deleteExpired()
+	if err == nil {
+		t.Error("expiringAdapter.deleteExpired() error = nil, want error for the assignment that failed to delete")
+	}
+	if diff := cmp.Diff([][]string{{"user:alice", "role:OnCall", "domain:tenant1", "2000-01-01T00:00:00Z"}}, deleted); diff != "" {
+		t.Errorf("expiringAdapter.deleteExpired() mismatch (-want +got):\n%s", diff)
+	}
+
+	// the assignment that failed is tried again
+	adapter.user = ""
+	deleted, err = e.deleteExpired()
+	if err != nil {
+		t.Fatalf("expiringAdapter.deleteExpired() error = %v", err)
+	}
+	if diff := cmp.Diff([][]string{{"user:bob", "role:OnCall", "domain:tenant1", "2000-01-01T00:00:00Z"}}, deleted); diff != "" {
+		t.Errorf("expiringAdapter.deleteExpired() mismatch (-want +got):\n%s", diff)
+	}
+
+	if deleted, err := e.deleteExpired(); err != nil || len(deleted) != 0 {
+		t.Errorf("expiringAdapter.deleteExpired() = %v, %v, want none", deleted, err)
+	}
+}
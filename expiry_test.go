@@ -0,0 +1,220 @@
+package access
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/casbin/casbin/v2/persist"
+	fileadapter "github.com/casbin/casbin/v2/persist/file-adapter"
+	"github.com/cccteam/ccc/accesstypes"
+	"github.com/cccteam/httpio"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"go.uber.org/mock/gomock"
+)
+
+func tenant1Domains(t *testing.T) *MockDomains {
+	t.Helper()
+
+	domains := NewMockDomains(gomock.NewController(t))
+	domains.EXPECT().DomainExists(gomock.Any(), "tenant1").Return(true, nil).AnyTimes()
+
+	return domains
+}
+
+// removeRecordingAdapter is a file adapter that records the rules removed from it.
+type removeRecordingAdapter struct {
+	*fileadapter.Adapter
+
+	mu      sync.Mutex
+	removed [][]string
+}
+
+func (r *removeRecordingAdapter) NewAdapter() (persist.Adapter, error) {
+	return r, nil
+}
+
+func (r *removeRecordingAdapter) RemovePolicy(_, _ string, rule []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.removed = append(r.removed, rule)
+
+	return nil
+}
+
+func TestClient_expiredRoleUsers(t *testing.T) {
+	t.Parallel()
+
+	adapter := &removeRecordingAdapter{Adapter: fileadapter.NewAdapter("testdata/policy_expiry.csv")}
+	client, err := New(tenant1Domains(t), adapter)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	tests := []struct {
+		user accesstypes.User
+		want bool
+	}{
+		{user: "alice", want: false},
+		{user: "bob", want: true},
+		{user: "carol", want: false},
+		{user: "dave", want: true},
+	}
+	for _, tt := range tests {
+		err := client.RequireAll(context.Background(), tt.user, "tenant1", "Delete")
+		if got := err == nil; got != tt.want {
+			t.Errorf("Client.RequireAll(%s) allowed = %v, want %v (error = %v)", tt.user, got, tt.want, err)
+		}
+	}
+
+	user, err := client.userManager.User(context.Background(), "bob", "tenant1")
+	if err != nil {
+		t.Fatalf("userManager.User() error = %v", err)
+	}
+	wantExpirations := map[accesstypes.Domain]map[accesstypes.Role]time.Time{
+		"tenant1": {"OnCall": time.Date(2999, 1, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	if diff := cmp.Diff(wantExpirations, user.RoleExpirations); diff != "" {
+		t.Errorf("userManager.User() RoleExpirations mismatch (-want +got):\n%s", diff)
+	}
+
+	deleted, err := client.userManager.DeleteExpiredRoleUsers(context.Background())
+	if err != nil {
+		t.Fatalf("userManager.DeleteExpiredRoleUsers() error = %v", err)
+	}
+	wantRemoved := [][]string{
+		{"user:alice", "role:OnCall", "domain:tenant1", "2000-01-01T00:00:00Z"},
+		{"user:carol", "role:OnCall", "domain:tenant1", "not-a-time"},
+	}
+	wantDeleted := []RoleAssignment{
+		{Domain: "tenant1", Role: "OnCall", User: "alice", ExpiresAt: time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{Domain: "tenant1", Role: "OnCall", User: "carol"},
+	}
+	if diff := cmp.Diff(wantDeleted, deleted); diff != "" {
+		t.Errorf("userManager.DeleteExpiredRoleUsers() mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(wantRemoved, adapter.removed); diff != "" {
+		t.Errorf("removed rules mismatch (-want +got):\n%s", diff)
+	}
+
+	// expired assignments are only deleted once
+	if deleted, err := client.userManager.DeleteExpiredRoleUsers(context.Background()); err != nil || len(deleted) != 0 {
+		t.Errorf("userManager.DeleteExpiredRoleUsers() = %v, %v, want none", deleted, err)
+	}
+}
+
+func Test_userManager_AddRoleUsersUntil(t *testing.T) {
+	t.Parallel()
+
+	client, err := New(tenant1Domains(t), fileAdapter("testdata/policy_expiry.csv"))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	u := client.userManager
+	ctx := context.Background()
+
+	if err := u.AddRoleUsersUntil(ctx, "tenant1", "OnCall", time.Now().Add(-time.Minute), "erin"); err == nil {
+		t.Error("userManager.AddRoleUsersUntil() error = nil for past expiry, want error")
+	}
+	if err := u.AddRoleUsersUntil(ctx, "tenant1", "Missing", time.Now().Add(time.Hour), "erin"); err == nil {
+		t.Error("userManager.AddRoleUsersUntil() error = nil for missing role, want error")
+	}
+
+	// dave has the role permanently, so no one is assigned
+	if err := u.AddRoleUsersUntil(ctx, "tenant1", "OnCall", time.Now().Add(time.Hour), "erin", "dave"); !httpio.HasConflict(err) {
+		t.Errorf("userManager.AddRoleUsersUntil() error = %v for a permanent assignment, want conflict", err)
+	}
+	if err := client.RequireAll(ctx, "erin", "tenant1", "Delete"); err == nil {
+		t.Error("Client.RequireAll(erin) error = nil after failed AddRoleUsersUntil(), want error")
+	}
+	if err := client.RequireAll(ctx, "dave", "tenant1", "Delete"); err != nil {
+		t.Errorf("Client.RequireAll(dave) error = %v, want permanent assignment kept", err)
+	}
+
+	// a later expiry replaces the earlier one
+	if err := u.AddRoleUsersUntil(ctx, "tenant1", "OnCall", time.Now().Add(time.Minute), "erin"); err != nil {
+		t.Fatalf("userManager.AddRoleUsersUntil() error = %v", err)
+	}
+	expiresAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	if err := u.AddRoleUsersUntil(ctx, "tenant1", "OnCall", expiresAt, "erin", "frank"); err != nil {
+		t.Fatalf("userManager.AddRoleUsersUntil() error = %v", err)
+	}
+	for _, user := range []accesstypes.User{"erin", "frank"} {
+		if err := client.RequireAll(ctx, user, "tenant1", "Delete"); err != nil {
+			t.Errorf("Client.RequireAll(%s) error = %v", user, err)
+		}
+
+		access, err := u.User(ctx, user, "tenant1")
+		if err != nil {
+			t.Fatalf("userManager.User() error = %v", err)
+		}
+		want := map[accesstypes.Domain]map[accesstypes.Role]time.Time{"tenant1": {"OnCall": expiresAt}}
+		if diff := cmp.Diff(want, access.RoleExpirations); diff != "" {
+			t.Errorf("userManager.User(%s) RoleExpirations mismatch (-want +got):\n%s", user, diff)
+		}
+	}
+
+	// assigning the role without an expiry makes it permanent
+	if err := u.AddRoleUsers(ctx, "tenant1", "OnCall", "erin"); err != nil {
+		t.Fatalf("userManager.AddRoleUsers() error = %v", err)
+	}
+	access, err := u.User(ctx, "erin", "tenant1")
+	if err != nil {
+		t.Fatalf("userManager.User() error = %v", err)
+	}
+	if access.RoleExpirations != nil {
+		t.Errorf("userManager.User() RoleExpirations = %v, want nil", access.RoleExpirations)
+	}
+	if diff := cmp.Diff(accesstypes.RoleCollection{"tenant1": {"OnCall"}}, access.Roles); diff != "" {
+		t.Errorf("userManager.User() Roles mismatch (-want +got):\n%s", diff)
+	}
+
+	// deleting the role removes time-bound assignments too
+	if err := u.DeleteRoleUsers(ctx, "tenant1", "OnCall", "frank"); err != nil {
+		t.Fatalf("userManager.DeleteRoleUsers() error = %v", err)
+	}
+	if err := client.RequireAll(ctx, "frank", "tenant1", "Delete"); err == nil {
+		t.Error("Client.RequireAll(frank) error = nil after DeleteRoleUsers(), want error")
+	}
+}
+
+func TestClient_RunExpirySweeper(t *testing.T) {
+	t.Parallel()
+
+	sink := NewMemoryAuditSink()
+	adapter := &removeRecordingAdapter{Adapter: fileadapter.NewAdapter("testdata/policy_expiry.csv")}
+	client, err := New(tenant1Domains(t), adapter, WithAuditSink(sink))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		client.RunExpirySweeper(ctx, time.Millisecond)
+	}()
+
+	// deletions made by the sweeper are audited
+	deadline := time.Now().Add(5 * time.Second)
+	for len(sink.Events()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	events := sink.Events()
+	if len(events) == 0 {
+		t.Fatal("MemoryAuditSink.Events() = none, want the deletion by the sweeper")
+	}
+	expiresAt := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	want := AuditEvent{
+		Action: "DeleteExpiredRoleUsers", Domain: "tenant1", Role: "OnCall", Users: []accesstypes.User{"alice"}, ExpiresAt: &expiresAt,
+	}
+	if diff := cmp.Diff(want, events[0], cmpopts.IgnoreFields(AuditEvent{}, "Time", "After")); diff != "" {
+		t.Errorf("MemoryAuditSink.Events()[0] mismatch (-want +got):\n%s", diff)
+	}
+}
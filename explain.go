@@ -0,0 +1,131 @@
+package access
+
+import (
+	"cmp"
+	"context"
+	"slices"
+
+	"github.com/cccteam/ccc/accesstypes"
+	"github.com/cccteam/ccc/tracer"
+	"github.com/cccteam/httpio"
+	"github.com/go-playground/errors/v5"
+)
+
+// Explanation describes why a user does or does not have a permission on a resource.
+type Explanation struct {
+	// Allowed is the result of the permission check.
+	Allowed bool `json:"allowed"`
+	// Roles are the user's roles, including inherited roles, with a policy for the permission on the resource.
+	Roles []accesstypes.Role `json:"roles"`
+	// Policies are every policy that matched the check. A matching deny policy overrides all allow policies.
+	Policies []PolicyRule `json:"policies"`
+	// Decision is the policy that decided the check, or nil when no policy matched and the permission was not granted.
+	Decision *PolicyRule `json:"decision,omitempty"`
+}
+
+// PolicyRule is a policy granting or denying a role a permission on a resource in a domain.
+type PolicyRule struct {
+	Role       accesstypes.Role       `json:"role"`
+	Domain     accesstypes.Domain     `json:"domain"`
+	Resource   accesstypes.Resource   `json:"resource"`
+	Permission accesstypes.Permission `json:"permission"`
+	// Effect is "allow" or "deny".
+	Effect string `json:"effect"`
+}
+
+// Explain checks if user has perm on resource in domain and reports the roles and policies that led to the result.
+// Use accesstypes.GlobalResource for permissions that are not scoped to a resource. Errors if domain invalid.
+func (c *Client) Explain(
+	ctx context.Context, user accesstypes.User, domain accesstypes.Domain, perm accesstypes.Permission, resource accesstypes.Resource,
+) (*Explanation, error) {
+	ctx, span := tracer.Start(ctx)
+	defer span.End()
+
+	if exists, err := c.userManager.DomainExists(ctx, domain); err != nil {
+		return nil, err
+	} else if !exists {
+		return nil, httpio.NewBadRequestMessage("Invalid Domain")
+	}
+
+	enforcer, err := c.userManager.Enforcer()
+	if err != nil {
+		return nil, errors.Wrap(err, "userManager.Enforcer()")
+	}
+
+	allowed, decision, err := enforcer.EnforceEx(user.Marshal(), domain.Marshal(), resource.Marshal(), perm.Marshal())
+	if err != nil {
+		return nil, errors.Wrap(err, "casbin.IEnforcer.EnforceEx()")
+	}
+
+	// EnforceEx only reports the deciding policy, so the other matching policies are found through the user's roles
+	roles, err := enforcer.GetImplicitRolesForUser(user.Marshal(), domain.Marshal())
+	if err != nil {
+		return nil, errors.Wrap(err, "enforcer.GetImplicitRolesForUser()")
+	}
+	if c.userManager.globalDomainGrants && domain != accesstypes.GlobalDomain {
+		globalRoles, err := enforcer.GetImplicitRolesForUser(user.Marshal(), accesstypes.GlobalDomain.Marshal())
+		if err != nil {
+			return nil, errors.Wrap(err, "enforcer.GetImplicitRolesForUser()")
+		}
+		roles = append(roles, globalRoles...)
+	}
+
+	explanation := &Explanation{
+		Allowed:  allowed,
+		Roles:    []accesstypes.Role{},
+		Policies: []PolicyRule{},
+	}
+	for _, role := range roles {
+		if !isRoleSubject(role) {
+			continue
+		}
+
+		policies, err := enforcer.GetFilteredPolicy(0, role, domain.Marshal(), resource.Marshal(), perm.Marshal())
+		if err != nil {
+			return nil, errors.Wrap(err, "enforcer.GetFilteredPolicy()")
+		}
+
+		for _, policy := range policies {
+			rule := newPolicyRule(policy)
+			if slices.Contains(explanation.Policies, rule) {
+				continue
+			}
+			explanation.Policies = append(explanation.Policies, rule)
+			if !slices.Contains(explanation.Roles, rule.Role) {
+				explanation.Roles = append(explanation.Roles, rule.Role)
+			}
+		}
+	}
+
+	slices.Sort(explanation.Roles)
+	slices.SortFunc(explanation.Policies, func(a, b PolicyRule) int {
+		// deny policies first since they decide the check
+		if a.Effect != b.Effect {
+			if a.Effect == effectDeny {
+				return -1
+			}
+
+			return 1
+		}
+
+		return cmp.Compare(a.Role, b.Role)
+	})
+
+	if len(decision) == 5 && isRoleSubject(decision[0]) {
+		rule := newPolicyRule(decision)
+		explanation.Decision = &rule
+	}
+
+	return explanation, nil
+}
+
+// newPolicyRule converts a casbin policy to a PolicyRule.
+func newPolicyRule(policy []string) PolicyRule {
+	return PolicyRule{
+		Role:       accesstypes.UnmarshalRole(policy[0]),
+		Domain:     accesstypes.UnmarshalDomain(policy[1]),
+		Resource:   accesstypes.UnmarshalResource(policy[2]),
+		Permission: accesstypes.UnmarshalPermission(policy[3]),
+		Effect:     policy[4],
+	}
+}
@@ -0,0 +1,86 @@
+package access
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cccteam/ccc/accesstypes"
+	"github.com/google/go-cmp/cmp"
+	"go.uber.org/mock/gomock"
+)
+
+func TestClient_Explain(t *testing.T) {
+	t.Parallel()
+
+	type args struct {
+		user     accesstypes.User
+		domain   accesstypes.Domain
+		perm     accesstypes.Permission
+		resource accesstypes.Resource
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    *Explanation
+		wantErr bool
+	}{
+		{
+			name: "allowed by an inherited role",
+			args: args{user: "alice", domain: "tenant1", perm: "ViewUsers", resource: accesstypes.GlobalResource},
+			want: &Explanation{
+				Allowed:  true,
+				Roles:    []accesstypes.Role{"Viewer"},
+				Policies: []PolicyRule{{Role: "Viewer", Domain: "tenant1", Resource: accesstypes.GlobalResource, Permission: "ViewUsers", Effect: "allow"}},
+				Decision: &PolicyRule{Role: "Viewer", Domain: "tenant1", Resource: accesstypes.GlobalResource, Permission: "ViewUsers", Effect: "allow"},
+			},
+		},
+		{
+			name: "denied by a deny policy that overrides an allow",
+			args: args{user: "bob", domain: "tenant1", perm: "Update", resource: "Users.name"},
+			want: &Explanation{
+				Allowed: false,
+				Roles:   []accesstypes.Role{"Editor", "Intern"},
+				Policies: []PolicyRule{
+					{Role: "Intern", Domain: "tenant1", Resource: "Users.name", Permission: "Update", Effect: "deny"},
+					{Role: "Editor", Domain: "tenant1", Resource: "Users.name", Permission: "Update", Effect: "allow"},
+				},
+				Decision: &PolicyRule{Role: "Intern", Domain: "tenant1", Resource: "Users.name", Permission: "Update", Effect: "deny"},
+			},
+		},
+		{
+			name: "denied without a matching policy",
+			args: args{user: "carol", domain: "tenant1", perm: "Update", resource: "Users.name"},
+			want: &Explanation{
+				Allowed:  false,
+				Roles:    []accesstypes.Role{},
+				Policies: []PolicyRule{},
+			},
+		},
+		{
+			name:    "invalid domain",
+			args:    args{user: "alice", domain: "tenant2", perm: "ViewUsers", resource: accesstypes.GlobalResource},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			domains := tenant1Domains(t)
+			domains.EXPECT().DomainExists(gomock.Any(), "tenant2").Return(false, nil).AnyTimes()
+
+			client, err := New(domains, fileAdapter("testdata/policy_inheritance.csv"))
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+
+			got, err := client.Explain(context.Background(), tt.args.user, tt.args.domain, tt.args.perm, tt.args.resource)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Client.Explain() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("Client.Explain() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package access
+
+import (
+	"context"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/cccteam/ccc/accesstypes"
+	"github.com/cccteam/ccc/tracer"
+	"github.com/go-playground/errors/v5"
+)
+
+// FilterResources returns the items user has perm on in domain, keeping their order. resource returns the resource
+// an item is checked against, such as a field resource for a column or a record's resource. Errors if domain invalid.
+func FilterResources[T any](
+	ctx context.Context, controller Controller, user accesstypes.User, domain accesstypes.Domain, perm accesstypes.Permission,
+	items []T, resource func(T) accesstypes.Resource,
+) ([]T, error) {
+	ctx, span := tracer.Start(ctx)
+	defer span.End()
+
+	resources := make([]accesstypes.Resource, 0, len(items))
+	for _, item := range items {
+		resources = append(resources, resource(item))
+	}
+
+	ok, missing, err := controller.RequireResources(ctx, user, domain, perm, resources...)
+	if err != nil {
+		return nil, errors.Wrap(err, "Controller.RequireResources()")
+	}
+	if ok {
+		return items, nil
+	}
+
+	allowed := make([]T, 0, len(items)-len(missing))
+	for i, item := range items {
+		if !slices.Contains(missing, resources[i]) {
+			allowed = append(allowed, item)
+		}
+	}
+
+	return allowed, nil
+}
+
+// MaskFields sets the fields of the struct v points to to their zero value when user lacks Read on them in domain.
+// Each exported field is checked as the field resource of res named by its json tag, or by the field name when it has
+// no tag, the same names middleware.JSONFieldResources uses. The fields of embedded structs are checked by the names
+// encoding/json promotes them to. Fields tagged `json:"-"` are left as is.
+// Errors if v is not a pointer to a struct, a field's name contains a ".", so it can't be a field resource, or domain
+// invalid.
+func MaskFields(ctx context.Context, controller Controller, user accesstypes.User, domain accesstypes.Domain, res accesstypes.Resource, v any) error {
+	ctx, span := tracer.Start(ctx)
+	defer span.End()
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return errors.Newf("v must be a non-nil pointer to a struct, got %T", v)
+	}
+
+	fields, err := maskableFields(res, rv.Elem())
+	if err != nil {
+		return err
+	}
+
+	resources := make([]accesstypes.Resource, 0, len(fields))
+	for _, field := range fields {
+		resources = append(resources, field.resource)
+	}
+
+	ok, missing, err := controller.RequireResources(ctx, user, domain, accesstypes.Read, resources...)
+	if err != nil {
+		return errors.Wrap(err, "Controller.RequireResources()")
+	}
+	if ok {
+		return nil
+	}
+
+	for _, field := range fields {
+		if slices.Contains(missing, field.resource) {
+			if !field.value.CanSet() {
+				return errors.Newf("field %s can't be masked", field.resource)
+			}
+			field.value.SetZero()
+		}
+	}
+
+	return nil
+}
+
+// maskableField is a struct field checked by MaskFields.
+type maskableField struct {
+	resource accesstypes.Resource
+	value    reflect.Value
+}
+
+// maskableFields returns the fields of the struct rv that encoding/json encodes, with the field resources of res they
+// are checked as. The fields of embedded structs without a json name are returned as fields of rv, like encoding/json
+// promotes them, and nil embedded pointers have no fields.
+func maskableFields(res accesstypes.Resource, rv reflect.Value) ([]maskableField, error) {
+	var fields []maskableField
+	for i := range rv.NumField() {
+		field := rv.Type().Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+
+		if field.Anonymous && name == "" {
+			value := rv.Field(i)
+			if value.Kind() == reflect.Pointer {
+				if value.IsNil() {
+					continue
+				}
+				value = value.Elem()
+			}
+			if value.Kind() == reflect.Struct {
+				embedded, err := maskableFields(res, value)
+				if err != nil {
+					return nil, err
+				}
+				fields = append(fields, embedded...)
+
+				continue
+			}
+		}
+
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		if strings.Contains(name, ".") {
+			return nil, errors.Newf("field %s: name %q contains a '.' and can't be a field resource", field.Name, name)
+		}
+
+		fields = append(fields, maskableField{resource: res.ResourceWithTag(accesstypes.Tag(name)), value: rv.Field(i)})
+	}
+
+	return fields, nil
+}
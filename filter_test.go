@@ -0,0 +1,154 @@
+package access
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cccteam/ccc/accesstypes"
+	"github.com/cccteam/httpio"
+	"github.com/google/go-cmp/cmp"
+	"go.uber.org/mock/gomock"
+)
+
+func TestFilterResources(t *testing.T) {
+	t.Parallel()
+
+	type document struct {
+		ID       string
+		Resource accesstypes.Resource
+	}
+	documents := []document{{ID: "1", Resource: "Documents.public"}, {ID: "2", Resource: "Documents.secret"}, {ID: "3", Resource: "Documents.public"}}
+
+	tests := []struct {
+		name    string
+		prepare func(controller *MockController)
+		want    []document
+		wantErr bool
+	}{
+		{
+			name: "keeps every item",
+			prepare: func(controller *MockController) {
+				controller.EXPECT().RequireResources(gomock.Any(), accesstypes.User("zach"), accesstypes.Domain("tenant1"), accesstypes.Read, gomock.Any()).
+					Return(true, nil, nil)
+			},
+			want: documents,
+		},
+		{
+			name: "drops items without permission",
+			prepare: func(controller *MockController) {
+				controller.EXPECT().RequireResources(gomock.Any(), accesstypes.User("zach"), accesstypes.Domain("tenant1"), accesstypes.Read, gomock.Any()).
+					Return(false, []accesstypes.Resource{"Documents.secret"}, nil)
+			},
+			want: []document{{ID: "1", Resource: "Documents.public"}, {ID: "3", Resource: "Documents.public"}},
+		},
+		{
+			name: "invalid domain",
+			prepare: func(controller *MockController) {
+				controller.EXPECT().RequireResources(gomock.Any(), accesstypes.User("zach"), accesstypes.Domain("tenant1"), accesstypes.Read, gomock.Any()).
+					Return(false, nil, httpio.NewBadRequestMessage("Invalid Domain"))
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			controller := NewMockController(gomock.NewController(t))
+			tt.prepare(controller)
+
+			got, err := FilterResources(context.Background(), controller, "zach", "tenant1", accesstypes.Read, documents,
+				func(d document) accesstypes.Resource { return d.Resource })
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("FilterResources() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("FilterResources() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestMaskFields(t *testing.T) {
+	t.Parallel()
+
+	type user struct {
+		Name     string `json:"name"`
+		Email    string `json:"email,omitempty"`
+		SSN      string
+		Password string `json:"-"`
+		internal string
+	}
+
+	controller := NewMockController(gomock.NewController(t))
+	controller.EXPECT().RequireResources(
+		gomock.Any(), accesstypes.User("zach"), accesstypes.Domain("tenant1"), accesstypes.Read,
+		accesstypes.Resource("Users.name"), accesstypes.Resource("Users.email"), accesstypes.Resource("Users.SSN"),
+	).Return(false, []accesstypes.Resource{"Users.email", "Users.SSN"}, nil)
+
+	got := &user{Name: "Zach", Email: "zach@example.com", SSN: "123-45-6789", Password: "secret", internal: "kept"}
+	if err := MaskFields(context.Background(), controller, "zach", "tenant1", "Users", got); err != nil {
+		t.Fatalf("MaskFields() error = %v", err)
+	}
+
+	want := &user{Name: "Zach", Password: "secret", internal: "kept"}
+	if diff := cmp.Diff(want, got, cmp.AllowUnexported(user{})); diff != "" {
+		t.Errorf("MaskFields() mismatch (-want +got):\n%s", diff)
+	}
+
+	if err := MaskFields(context.Background(), controller, "zach", "tenant1", "Users", user{}); err == nil {
+		t.Error("MaskFields() error = nil for a struct value, want error")
+	}
+}
+
+func TestMaskFields_embedded(t *testing.T) {
+	t.Parallel()
+
+	type Audit struct {
+		CreatedBy string `json:"createdBy"`
+	}
+	type contact struct {
+		Phone string `json:"phone"`
+	}
+	type Address struct {
+		City string `json:"city"`
+	}
+	type user struct {
+		Name string `json:"name"`
+		Audit
+		*contact
+		Address `json:"address"`
+	}
+
+	controller := NewMockController(gomock.NewController(t))
+	controller.EXPECT().RequireResources(
+		gomock.Any(), accesstypes.User("zach"), accesstypes.Domain("tenant1"), accesstypes.Read,
+		accesstypes.Resource("Users.name"), accesstypes.Resource("Users.createdBy"), accesstypes.Resource("Users.phone"),
+		accesstypes.Resource("Users.address"),
+	).Return(false, []accesstypes.Resource{"Users.createdBy", "Users.phone", "Users.address"}, nil)
+
+	got := &user{Name: "Zach", Audit: Audit{CreatedBy: "admin"}, contact: &contact{Phone: "555-0100"}, Address: Address{City: "Provo"}}
+	if err := MaskFields(context.Background(), controller, "zach", "tenant1", "Users", got); err != nil {
+		t.Fatalf("MaskFields() error = %v", err)
+	}
+
+	want := &user{Name: "Zach", contact: &contact{}}
+	if diff := cmp.Diff(want, got, cmp.AllowUnexported(user{})); diff != "" {
+		t.Errorf("MaskFields() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestMaskFields_dottedName(t *testing.T) {
+	t.Parallel()
+
+	type user struct {
+		Name  string `json:"name"`
+		Email string `json:"contact.email"`
+	}
+
+	// the field can't be checked, so nothing is returned unmasked
+	controller := NewMockController(gomock.NewController(t))
+	if err := MaskFields(context.Background(), controller, "zach", "tenant1", "Users", &user{Name: "Zach", Email: "zach@example.com"}); err == nil {
+		t.Error("MaskFields() error = nil for a name with a '.', want error")
+	}
+}
@@ -9,11 +9,15 @@ import (
 // Handlers provides HTTP handlers for managing user roles.
 type Handlers interface {
 	AddRole() http.HandlerFunc
+	AddRolePermissionDenials() http.HandlerFunc
 	AddRolePermissions() http.HandlerFunc
 	AddRoleUsers() http.HandlerFunc
 	DeleteRole() http.HandlerFunc
+	DeleteRolePermissionDenials() http.HandlerFunc
 	DeleteRolePermissions() http.HandlerFunc
 	DeleteRoleUsers() http.HandlerFunc
+	Explain() http.HandlerFunc
+	RolePermissionDenials() http.HandlerFunc
 	RolePermissions() http.HandlerFunc
 	Roles() http.HandlerFunc
 	RoleUsers() http.HandlerFunc
@@ -26,16 +30,18 @@ type LogHandler func(handler func(w http.ResponseWriter, r *http.Request) error)
 
 // HandlerClient implements Handlers for access management.
 type HandlerClient struct {
-	manager UserManager
-	handler LogHandler
+	controller Controller
+	manager    UserManager
+	handler    LogHandler
 }
 
 var _ Handlers = &HandlerClient{}
 
 func newHandler(client *Client, logHandler LogHandler) *HandlerClient {
 	return &HandlerClient{
-		manager: client.UserManager(),
-		handler: logHandler,
+		controller: client,
+		manager:    client.UserManager(),
+		handler:    actorHandler(logHandler, client.actor),
 	}
 }
 
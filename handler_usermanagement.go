@@ -2,16 +2,21 @@ package access
 
 import (
 	"net/http"
+	"time"
 
 	"github.com/cccteam/ccc/accesstypes"
 	"github.com/cccteam/ccc/tracer"
 	"github.com/cccteam/httpio"
+	"github.com/go-chi/chi/v5"
 )
 
 const (
 	paramUser   httpio.ParamType = "user"
 	paramDomain httpio.ParamType = "domain"
 	paramRole   httpio.ParamType = "role"
+
+	paramPermission httpio.ParamType = "permission"
+	paramResource   httpio.ParamType = "resource"
 )
 
 // Users is the handler to get the list of users in the system
@@ -19,9 +24,10 @@ const (
 // Permissions Required: ViewUsers
 func (a *HandlerClient) Users() http.HandlerFunc {
 	type user struct {
-		Name        string                               `json:"name"`
-		Roles       accesstypes.RoleCollection           `json:"roles"`
-		Permissions accesstypes.UserPermissionCollection `json:"permissions"`
+		Name            string                                                `json:"name"`
+		Roles           accesstypes.RoleCollection                            `json:"roles"`
+		Permissions     accesstypes.UserPermissionCollection                  `json:"permissions"`
+		RoleExpirations map[accesstypes.Domain]map[accesstypes.Role]time.Time `json:"roleExpirations,omitempty"`
 	}
 
 	type response []*user
@@ -49,9 +55,10 @@ func (a *HandlerClient) Users() http.HandlerFunc {
 // Permissions Required: ViewUsers
 func (a *HandlerClient) User() http.HandlerFunc {
 	type response struct {
-		Name        string                               `json:"name"`
-		Roles       accesstypes.RoleCollection           `json:"roles"`
-		Permissions accesstypes.UserPermissionCollection `json:"permissions"`
+		Name            string                                                `json:"name"`
+		Roles           accesstypes.RoleCollection                            `json:"roles"`
+		Permissions     accesstypes.UserPermissionCollection                  `json:"permissions"`
+		RoleExpirations map[accesstypes.Domain]map[accesstypes.Role]time.Time `json:"roleExpirations,omitempty"`
 	}
 
 	return a.handler(func(w http.ResponseWriter, r *http.Request) error {
@@ -135,6 +142,36 @@ func (a *HandlerClient) AddRolePermissions() http.HandlerFunc {
 	})
 }
 
+// AddRolePermissionDenials is the handler to deny permissions to a given role
+//
+// Permissions Required: AddRolePermissions
+func (a *HandlerClient) AddRolePermissionDenials() http.HandlerFunc {
+	type request struct {
+		Permissions []accesstypes.Permission `json:"permissions"`
+	}
+
+	decoder := newDecoder[request]()
+
+	return a.handler(func(w http.ResponseWriter, r *http.Request) error {
+		ctx, span := tracer.Start(r.Context())
+		defer span.End()
+
+		req, err := decoder.Decode(r)
+		if err != nil {
+			return httpio.NewEncoder(w).BadRequestWithError(ctx, err)
+		}
+
+		domain := httpio.Param[accesstypes.Domain](r, paramDomain)
+		role := httpio.Param[accesstypes.Role](r, paramRole)
+
+		if err := a.manager.AddRolePermissionDenials(ctx, domain, role, req.Permissions...); err != nil {
+			return httpio.NewEncoder(w).ClientMessage(ctx, err)
+		}
+
+		return nil
+	})
+}
+
 // AddRoleUsers is the handler to assign a role to a list of users
 //
 // Permissions Required: AddRoleUsers
@@ -222,6 +259,35 @@ func (a *HandlerClient) DeleteRolePermissions() http.HandlerFunc {
 	})
 }
 
+// DeleteRolePermissionDenials is the handler to remove permission denials from a role
+//
+// Permissions Required: DeleteRolePermissions
+func (a *HandlerClient) DeleteRolePermissionDenials() http.HandlerFunc {
+	type request struct {
+		Permissions []accesstypes.Permission `json:"permissions"`
+	}
+
+	decoder := newDecoder[request]()
+
+	return a.handler(func(w http.ResponseWriter, r *http.Request) error {
+		ctx, span := tracer.Start(r.Context())
+		defer span.End()
+
+		req, err := decoder.Decode(r)
+		if err != nil {
+			return httpio.NewEncoder(w).BadRequestWithError(ctx, err)
+		}
+		domain := httpio.Param[accesstypes.Domain](r, paramDomain)
+		role := httpio.Param[accesstypes.Role](r, paramRole)
+
+		if err := a.manager.DeleteRolePermissionDenials(ctx, domain, role, req.Permissions...); err != nil {
+			return httpio.NewEncoder(w).ClientMessage(ctx, err)
+		}
+
+		return nil
+	})
+}
+
 // Roles is the handler to get the list of roles in the system for a given domain
 //
 // Permissions Required: ListRoles
@@ -294,6 +360,30 @@ func (a *HandlerClient) RolePermissions() http.HandlerFunc {
 	})
 }
 
+// RolePermissionDenials is the handler to the list of denied permissions for a given role
+//
+// Permissions Required: ListRolePermissions
+func (a *HandlerClient) RolePermissionDenials() http.HandlerFunc {
+	type response accesstypes.RolePermissionCollection
+
+	return a.handler(func(w http.ResponseWriter, r *http.Request) error {
+		ctx, span := tracer.Start(r.Context())
+		defer span.End()
+
+		domain := httpio.Param[accesstypes.Domain](r, paramDomain)
+		role := httpio.Param[accesstypes.Role](r, paramRole)
+
+		rolePermissionDenials, err := a.manager.RolePermissionDenials(ctx, domain, role)
+		if err != nil {
+			return httpio.NewEncoder(w).ClientMessage(ctx, err)
+		}
+
+		resp := response(rolePermissionDenials)
+
+		return httpio.NewEncoder(w).Ok(resp)
+	})
+}
+
 // DeleteRole is the handler to delete a role
 //
 // Permissions Required: DeleteRole
@@ -313,3 +403,30 @@ func (a *HandlerClient) DeleteRole() http.HandlerFunc {
 		return nil
 	})
 }
+
+// Explain is the handler to show why a user does or does not have a permission on a resource in a domain. The
+// resource defaults to accesstypes.GlobalResource when empty.
+//
+// Permissions Required: ViewUsers
+func (a *HandlerClient) Explain() http.HandlerFunc {
+	return a.handler(func(w http.ResponseWriter, r *http.Request) error {
+		ctx, span := tracer.Start(r.Context())
+		defer span.End()
+
+		user := httpio.Param[accesstypes.User](r, paramUser)
+		domain := httpio.Param[accesstypes.Domain](r, paramDomain)
+		perm := httpio.Param[accesstypes.Permission](r, paramPermission)
+		// the resource is optional, so it isn't read with httpio.Param which requires it
+		resource := accesstypes.Resource(chi.URLParam(r, string(paramResource)))
+		if resource == "" {
+			resource = accesstypes.GlobalResource
+		}
+
+		explanation, err := a.controller.Explain(ctx, user, domain, perm, resource)
+		if err != nil {
+			return httpio.NewEncoder(w).ClientMessage(ctx, err)
+		}
+
+		return httpio.NewEncoder(w).Ok(explanation)
+	})
+}
@@ -1217,6 +1217,445 @@ func TestHandlerClient_RolePermissions(t *testing.T) {
 	}
 }
 
+func TestHandlerClient_AddRolePermissionDenials(t *testing.T) {
+	t.Parallel()
+
+	type args struct {
+		domain string
+		role   string
+		body   string
+	}
+	tests := []struct {
+		name    string
+		wantErr bool
+		args    args
+		prepare func(user *MockUserManager)
+	}{
+		{
+			name:    "successfully denies permissions",
+			wantErr: false,
+			args: args{
+				domain: "tenant1",
+				role:   "Admin",
+				body:   `{"permissions" : ["AddUser", "RemoveUser"]}`,
+			},
+			prepare: func(user *MockUserManager) {
+				user.EXPECT().AddRolePermissionDenials(gomock.Any(), accesstypes.Domain("tenant1"), accesstypes.Role("Admin"), accesstypes.Permission("AddUser"), accesstypes.Permission("RemoveUser")).Return(nil).Times(1)
+			},
+		},
+		{
+			name:    "successfully denies permissions empty",
+			wantErr: false,
+			args: args{
+				domain: "tenant1",
+				role:   "Admin",
+				body:   `{ "permissions" : [] }`,
+			},
+			prepare: func(user *MockUserManager) {
+				user.EXPECT().AddRolePermissionDenials(gomock.Any(), accesstypes.Domain("tenant1"), accesstypes.Role("Admin")).Return(nil).Times(1)
+			},
+		},
+		{
+			name:    "fails to parse the request body",
+			wantErr: true,
+			args: args{
+				domain: "",
+				role:   "Admin",
+				body:   `{"permissions": {abc}`,
+			},
+		},
+		{
+			name:    "fails on domain",
+			wantErr: true,
+			args: args{
+				domain: "",
+				role:   "Admin",
+				body:   `{"permissions": []}`,
+			},
+		},
+		{
+			name:    "fails on role",
+			wantErr: true,
+			args: args{
+				domain: "tenant1",
+				role:   "",
+				body:   `{"permissions": []}`,
+			},
+		},
+		{
+			name:    "fails to deny the permissions",
+			wantErr: true,
+			args: args{
+				domain: "tenant1",
+				role:   "Admin",
+				body:   `{"permissions": ["AddUser"]}`,
+			},
+			prepare: func(user *MockUserManager) {
+				user.EXPECT().AddRolePermissionDenials(gomock.Any(), accesstypes.Domain("tenant1"), accesstypes.Role("Admin"), accesstypes.Permission("AddUser")).Return(errors.New("failed to deny the permissions")).Times(1)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			ctrl := gomock.NewController(t)
+			accessManager := NewMockUserManager(ctrl)
+
+			h := &HandlerClient{
+				manager: accessManager,
+				handler: func(handler func(w http.ResponseWriter, r *http.Request) error) http.HandlerFunc {
+					return func(w http.ResponseWriter, r *http.Request) {
+						if err := handler(w, r); err != nil {
+							_ = httpio.NewEncoder(w).ClientMessage(r.Context(), err)
+						}
+					}
+				},
+			}
+
+			if tt.prepare != nil {
+				tt.prepare(accessManager)
+			}
+
+			req, err := createHTTPRequest(http.MethodPost,
+				strings.NewReader(tt.args.body),
+				map[httpio.ParamType]string{paramDomain: tt.args.domain, paramRole: tt.args.role},
+			)
+			if err != nil {
+				t.Error(err)
+			}
+
+			rr := httptest.NewRecorder()
+			httpio.WithParams(h.AddRolePermissionDenials()).ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusOK {
+				if tt.wantErr {
+					return
+				}
+				var got httpio.MessageResponse
+				if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+					t.Errorf("json.Unmarshal() error=%v", err)
+				}
+				t.Errorf("App.AddRolePermissionDenials() error = %v, wantErr = %v", got, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestHandlerClient_DeleteRolePermissionDenials(t *testing.T) {
+	t.Parallel()
+
+	type args struct {
+		domain string
+		role   string
+		body   string
+	}
+	tests := []struct {
+		name    string
+		wantErr bool
+		args    args
+		prepare func(user *MockUserManager)
+	}{
+		{
+			name:    "successfully deletes permission denials",
+			wantErr: false,
+			args: args{
+				domain: "tenant1",
+				role:   "Admin",
+				body:   `{"permissions" : ["AddUser", "RemoveUser"]}`,
+			},
+			prepare: func(user *MockUserManager) {
+				user.EXPECT().DeleteRolePermissionDenials(gomock.Any(), accesstypes.Domain("tenant1"), accesstypes.Role("Admin"), accesstypes.Permission("AddUser"), accesstypes.Permission("RemoveUser")).Return(nil).Times(1)
+			},
+		},
+		{
+			name:    "fails to parse the request body",
+			wantErr: true,
+			args: args{
+				domain: "",
+				role:   "Admin",
+				body:   `{"permissions": {abc}`,
+			},
+		},
+		{
+			name:    "fails on domain",
+			wantErr: true,
+			args: args{
+				domain: "",
+				role:   "Admin",
+				body:   `{"permissions": ["KillUser"]}`,
+			},
+		},
+		{
+			name:    "fails on role",
+			wantErr: true,
+			args: args{
+				domain: "tenant1",
+				role:   "",
+				body:   `{"permissions": ["KillUser"]}`,
+			},
+		},
+		{
+			name:    "fails to delete the permission denials",
+			wantErr: true,
+			args: args{
+				domain: "tenant1",
+				role:   "Admin",
+				body:   `{"permissions": ["AddUser"]}`,
+			},
+			prepare: func(user *MockUserManager) {
+				user.EXPECT().DeleteRolePermissionDenials(gomock.Any(), accesstypes.Domain("tenant1"), accesstypes.Role("Admin"), accesstypes.Permission("AddUser")).Return(errors.New("failed to delete role permissions")).Times(1)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			ctrl := gomock.NewController(t)
+			accessManager := NewMockUserManager(ctrl)
+
+			h := &HandlerClient{
+				manager: accessManager,
+				handler: func(handler func(w http.ResponseWriter, r *http.Request) error) http.HandlerFunc {
+					return func(w http.ResponseWriter, r *http.Request) {
+						if err := handler(w, r); err != nil {
+							_ = httpio.NewEncoder(w).ClientMessage(r.Context(), err)
+						}
+					}
+				},
+			}
+
+			if tt.prepare != nil {
+				tt.prepare(accessManager)
+			}
+
+			req, err := createHTTPRequest(http.MethodPost,
+				strings.NewReader(tt.args.body),
+				map[httpio.ParamType]string{paramDomain: tt.args.domain, paramRole: tt.args.role},
+			)
+			if err != nil {
+				t.Error(err)
+			}
+
+			rr := httptest.NewRecorder()
+			httpio.WithParams(h.DeleteRolePermissionDenials()).ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusOK {
+				if tt.wantErr {
+					return
+				}
+				var got httpio.MessageResponse
+				if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+					t.Errorf("json.Unmarshal() error=%v", err)
+				}
+				t.Errorf("App.DeleteRolePermissionDenials() error = %v, wantErr = %v", got, tt.wantErr)
+			} else if tt.wantErr {
+				t.Errorf("App.DeleteRolePermissionDenials() code = %v, wantErr = %v", rr.Code, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestHandlerClient_RolePermissionDenials(t *testing.T) {
+	t.Parallel()
+
+	type args struct {
+		domain string
+		role   string
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    accesstypes.RolePermissionCollection
+		prepare func(accessManager *MockUserManager)
+		wantErr bool
+	}{
+		{
+			name: "gets a list of denied permissions for role",
+			want: accesstypes.RolePermissionCollection{"daddy": {"resource:global"}},
+			args: args{
+				domain: "tenant1",
+				role:   "Admin",
+			},
+			prepare: func(accessManager *MockUserManager) {
+				accessManager.EXPECT().RolePermissionDenials(gomock.Any(), accesstypes.Domain("tenant1"), gomock.Any()).Return(accesstypes.RolePermissionCollection{"daddy": {"resource:global"}}, nil)
+			},
+		},
+		{
+			name: "fails to get permissions and returns a 500",
+			args: args{
+				domain: "tenant1",
+				role:   "Admin",
+			},
+			prepare: func(accessManager *MockUserManager) {
+				accessManager.EXPECT().RolePermissionDenials(gomock.Any(), accesstypes.Domain("tenant1"), accesstypes.Role("Admin")).Return(nil, errors.New("Failed to get a list of denied permissions")).Times(1)
+			},
+			wantErr: true,
+		},
+		{
+			name: "fails on domain",
+			args: args{
+				domain: "",
+				role:   "Admin",
+			},
+			wantErr: true,
+		},
+		{
+			name: "fails on role",
+			args: args{
+				domain: "tenant1",
+				role:   "",
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			ctrl := gomock.NewController(t)
+			accessManager := NewMockUserManager(ctrl)
+
+			h := &HandlerClient{
+				manager: accessManager,
+				handler: func(handler func(w http.ResponseWriter, r *http.Request) error) http.HandlerFunc {
+					return func(w http.ResponseWriter, r *http.Request) {
+						if err := handler(w, r); err != nil {
+							_ = httpio.NewEncoder(w).ClientMessage(r.Context(), err)
+						}
+					}
+				},
+			}
+
+			req, err := createHTTPRequest(http.MethodGet, http.NoBody, map[httpio.ParamType]string{paramDomain: tt.args.domain, paramRole: tt.args.role})
+			if err != nil {
+				t.Error(err)
+			}
+
+			if tt.prepare != nil {
+				tt.prepare(accessManager)
+			}
+
+			rr := httptest.NewRecorder()
+
+			httpio.WithParams(h.RolePermissionDenials()).ServeHTTP(rr, req)
+
+			// Check what the response code is. For 500 errors, execute this block
+			if rr.Code != http.StatusOK {
+				if tt.wantErr {
+					return
+				}
+				var got httpio.MessageResponse
+				if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+					t.Errorf("json.Unmarshal() error=%v", err)
+				}
+				t.Errorf("App.RolePermissionDenials() error = %v, wantErr = %v", got, tt.wantErr)
+			}
+
+			var got accesstypes.RolePermissionCollection
+			if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+				t.Errorf("json.Unmarshal() error=%v", err)
+			}
+
+			// check if the response is what we expected by comparing the two
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("App.RolePermissionDenials() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandlerClient_Explain(t *testing.T) {
+	t.Parallel()
+
+	explanation := &Explanation{
+		Allowed:  false,
+		Roles:    []accesstypes.Role{"Intern"},
+		Policies: []PolicyRule{{Role: "Intern", Domain: "tenant1", Resource: "Users.name", Permission: "Update", Effect: "deny"}},
+		Decision: &PolicyRule{Role: "Intern", Domain: "tenant1", Resource: "Users.name", Permission: "Update", Effect: "deny"},
+	}
+
+	tests := []struct {
+		name     string
+		resource string
+		want     *Explanation
+		wantCode int
+		prepare  func(controller *MockController)
+	}{
+		{
+			name:     "explains the decision",
+			resource: "Users.name",
+			want:     explanation,
+			wantCode: http.StatusOK,
+			prepare: func(controller *MockController) {
+				controller.EXPECT().Explain(gomock.Any(), accesstypes.User("bob"), accesstypes.Domain("tenant1"), accesstypes.Permission("Update"), accesstypes.Resource("Users.name")).
+					Return(explanation, nil).Times(1)
+			},
+		},
+		{
+			name:     "explains the decision without a resource",
+			want:     explanation,
+			wantCode: http.StatusOK,
+			prepare: func(controller *MockController) {
+				controller.EXPECT().Explain(gomock.Any(), accesstypes.User("bob"), accesstypes.Domain("tenant1"), accesstypes.Permission("Update"), accesstypes.GlobalResource).
+					Return(explanation, nil).Times(1)
+			},
+		},
+		{
+			name:     "invalid domain",
+			resource: "Users.name",
+			wantCode: http.StatusBadRequest,
+			prepare: func(controller *MockController) {
+				controller.EXPECT().Explain(gomock.Any(), accesstypes.User("bob"), accesstypes.Domain("tenant1"), accesstypes.Permission("Update"), accesstypes.Resource("Users.name")).
+					Return(nil, httpio.NewBadRequestMessage("Invalid Domain")).Times(1)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			ctrl := gomock.NewController(t)
+			controller := NewMockController(ctrl)
+
+			h := &HandlerClient{
+				controller: controller,
+				handler: func(handler func(w http.ResponseWriter, r *http.Request) error) http.HandlerFunc {
+					return func(w http.ResponseWriter, r *http.Request) {
+						if err := handler(w, r); err != nil {
+							_ = httpio.NewEncoder(w).ClientMessage(r.Context(), err)
+						}
+					}
+				},
+			}
+
+			tt.prepare(controller)
+
+			req, err := createHTTPRequest(http.MethodGet, http.NoBody, map[httpio.ParamType]string{
+				paramUser: "bob", paramDomain: "tenant1", paramPermission: "Update", paramResource: tt.resource,
+			})
+			if err != nil {
+				t.Error(err)
+			}
+
+			rr := httptest.NewRecorder()
+			httpio.WithParams(h.Explain()).ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantCode {
+				t.Fatalf("HandlerClient.Explain() code = %d, want %d: %s", rr.Code, tt.wantCode, rr.Body.String())
+			}
+			if tt.want == nil {
+				return
+			}
+
+			var got *Explanation
+			if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+				t.Errorf("json.Unmarshal() error=%v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("HandlerClient.Explain() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func createHTTPRequest(method string, body io.Reader, urlParams map[httpio.ParamType]string) (*http.Request, error) {
 	ctx := context.Background()
 	req, err := http.NewRequestWithContext(ctx, method, "", body)
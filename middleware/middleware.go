@@ -0,0 +1,200 @@
+// Package middleware provides HTTP middleware that enforces access control using an access.Controller.
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/cccteam/access"
+	"github.com/cccteam/ccc/accesstypes"
+	"github.com/cccteam/ccc/tracer"
+	"github.com/cccteam/httpio"
+	"github.com/go-playground/errors/v5"
+)
+
+// UserFunc returns the user making the request. Errors are written to the client using httpio.
+type UserFunc func(r *http.Request) (accesstypes.User, error)
+
+// DomainFunc returns the domain the request operates in. Errors are written to the client using httpio.
+type DomainFunc func(r *http.Request) (accesstypes.Domain, error)
+
+// ResourcesFunc returns the resources a request touches. Errors are written to the client using httpio.
+type ResourcesFunc func(r *http.Request) ([]accesstypes.Resource, error)
+
+// ForbiddenResponse is the body written when the user lacks a permission for some of the resources a request touches.
+type ForbiddenResponse struct {
+	Message    string                 `json:"message"`
+	Permission accesstypes.Permission `json:"permission"`
+	Missing    []accesstypes.Resource `json:"missing"`
+}
+
+// Authorizer builds middleware that enforces permissions for the user making the request.
+type Authorizer struct {
+	controller access.Controller
+	handler    access.LogHandler
+	user       UserFunc
+	domain     DomainFunc
+}
+
+// New creates an Authorizer that checks permissions with controller for the user and domain extracted from each request.
+// logHandler logs the errors and denials of the middleware, like the handlers returned by access.Controller.Handlers.
+func New(controller access.Controller, logHandler access.LogHandler, user UserFunc, domain DomainFunc) *Authorizer {
+	return &Authorizer{
+		controller: controller,
+		handler:    logHandler,
+		user:       user,
+		domain:     domain,
+	}
+}
+
+// RequireAll returns middleware that only calls the next handler if the user has all permissions in the domain.
+// Responds with 400 Bad Request if the domain is invalid and 403 Forbidden if a permission is missing.
+func (a *Authorizer) RequireAll(perms ...accesstypes.Permission) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return a.handler(func(w http.ResponseWriter, r *http.Request) error {
+			ctx, span := tracer.Start(r.Context())
+			defer span.End()
+
+			user, domain, err := a.subject(r)
+			if err != nil {
+				return httpio.NewEncoder(w).ClientMessage(ctx, err)
+			}
+
+			if err := a.controller.RequireAll(ctx, user, domain, perms...); err != nil {
+				return httpio.NewEncoder(w).ClientMessage(ctx, err)
+			}
+
+			next.ServeHTTP(w, r)
+
+			return nil
+		})
+	}
+}
+
+// RequireResources returns middleware that only calls the next handler if the user has perm for every resource
+// returned by resources. Responds with 400 Bad Request if the domain is invalid, 403 Forbidden if resources returns
+// none, so a request that touches nothing checkable is never let through, and 403 Forbidden with a ForbiddenResponse
+// body listing the missing resources otherwise.
+func (a *Authorizer) RequireResources(perm accesstypes.Permission, resources ResourcesFunc) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return a.handler(func(w http.ResponseWriter, r *http.Request) error {
+			ctx, span := tracer.Start(r.Context())
+			defer span.End()
+
+			user, domain, err := a.subject(r)
+			if err != nil {
+				return httpio.NewEncoder(w).ClientMessage(ctx, err)
+			}
+
+			res, err := resources(r)
+			if err != nil {
+				return httpio.NewEncoder(w).ClientMessage(ctx, err)
+			}
+			if len(res) == 0 {
+				return httpio.NewEncoder(w).ClientMessage(ctx, httpio.NewForbiddenMessagef("request has no resources to check %s on", perm))
+			}
+
+			ok, missing, err := a.controller.RequireResources(ctx, user, domain, perm, res...)
+			if err != nil {
+				return httpio.NewEncoder(w).ClientMessage(ctx, err)
+			}
+			if !ok {
+				resp := &ForbiddenResponse{
+					Message:    fmt.Sprintf("user %s does not have %s on all resources", user, perm),
+					Permission: perm,
+					Missing:    missing,
+				}
+				if err := httpio.NewEncoder(w).StatusCodeWithBody(http.StatusForbidden, resp); err != nil {
+					return errors.Wrap(err, "httpio.Encoder.StatusCodeWithBody()")
+				}
+
+				return httpio.NewForbiddenMessagef("user %s is missing %s on %v", user, perm, missing)
+			}
+
+			next.ServeHTTP(w, r)
+
+			return nil
+		})
+	}
+}
+
+// subject extracts the user and domain from the request.
+func (a *Authorizer) subject(r *http.Request) (accesstypes.User, accesstypes.Domain, error) {
+	user, err := a.user(r)
+	if err != nil {
+		return "", "", err
+	}
+
+	domain, err := a.domain(r)
+	if err != nil {
+		return "", "", err
+	}
+
+	return user, domain, nil
+}
+
+// DomainParam returns a DomainFunc that reads the domain from the named route parameter.
+// The route must be wrapped with httpio.WithParams to report a missing parameter as 400 Bad Request.
+func DomainParam(param httpio.ParamType) DomainFunc {
+	return func(r *http.Request) (accesstypes.Domain, error) {
+		return httpio.Param[accesstypes.Domain](r, param), nil
+	}
+}
+
+// GlobalDomain is a DomainFunc for routes that are not scoped to a domain.
+func GlobalDomain(*http.Request) (accesstypes.Domain, error) {
+	return accesstypes.GlobalDomain, nil
+}
+
+// Resources returns a ResourcesFunc that always returns the given resources.
+func Resources(resources ...accesstypes.Resource) ResourcesFunc {
+	return func(*http.Request) ([]accesstypes.Resource, error) {
+		return resources, nil
+	}
+}
+
+// maxJSONBodyBytes is the largest body JSONFieldResources reads.
+const maxJSONBodyBytes = 1 << 20
+
+// JSONFieldResources returns a ResourcesFunc for a JSON object body, such as a PATCH request. Each top level field
+// is mapped to the field resource of res using the field name as the tag. The body is restored for the next handler.
+// Bodies over 1 MiB are rejected with 413 Request Entity Too Large, and bodies that are not a JSON object, including
+// null, with 400 Bad Request.
+func JSONFieldResources(res accesstypes.Resource) ResourcesFunc {
+	return func(r *http.Request) ([]accesstypes.Resource, error) {
+		body, err := io.ReadAll(http.MaxBytesReader(nil, r.Body, maxJSONBodyBytes))
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				return nil, httpio.NewRequestEntityTooLargeMessageWithErrorf(err, "request body must not be larger than %d bytes", maxBytesErr.Limit)
+			}
+
+			return nil, errors.Wrap(err, "io.ReadAll()")
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(body, &fields); err != nil {
+			return nil, httpio.NewBadRequestMessageWithError(err, "request body must be a JSON object")
+		}
+		if fields == nil {
+			return nil, httpio.NewBadRequestMessage("request body must be a JSON object")
+		}
+
+		resources := make([]accesstypes.Resource, 0, len(fields))
+		for field := range fields {
+			if field == "" || strings.Contains(field, ".") {
+				return nil, httpio.NewBadRequestMessagef("invalid field name %q", field)
+			}
+			resources = append(resources, res.ResourceWithTag(accesstypes.Tag(field)))
+		}
+		slices.Sort(resources)
+
+		return resources, nil
+	}
+}
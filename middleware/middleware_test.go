@@ -0,0 +1,295 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cccteam/access"
+	"github.com/cccteam/access/mock/mock_access"
+	"github.com/cccteam/ccc/accesstypes"
+	"github.com/cccteam/httpio"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/errors/v5"
+	"github.com/google/go-cmp/cmp"
+	"go.uber.org/mock/gomock"
+)
+
+const (
+	paramDomain httpio.ParamType = "domain"
+
+	ViewUsers  accesstypes.Permission = "ViewUsers"
+	AddRole    accesstypes.Permission = "AddRole"
+	DeleteRole accesstypes.Permission = "DeleteRole"
+
+	Users accesstypes.Resource = "Users"
+)
+
+func TestAuthorizer_RequireAll(t *testing.T) {
+	t.Parallel()
+
+	type args struct {
+		domain string
+		perms  []accesstypes.Permission
+	}
+	tests := []struct {
+		name       string
+		args       args
+		user       UserFunc
+		prepare    func(controller *mock_access.MockController)
+		wantStatus int
+		wantNext   bool
+		wantLogged bool
+	}{
+		{
+			name: "user has all permissions",
+			args: args{domain: "tenant1", perms: []accesstypes.Permission{ViewUsers, AddRole}},
+			prepare: func(controller *mock_access.MockController) {
+				controller.EXPECT().RequireAll(gomock.Any(), accesstypes.User("zach"), accesstypes.Domain("tenant1"), ViewUsers, AddRole).Return(nil).Times(1)
+			},
+			wantStatus: http.StatusOK,
+			wantNext:   true,
+		},
+		{
+			name: "user is missing a permission",
+			args: args{domain: "tenant1", perms: []accesstypes.Permission{DeleteRole}},
+			prepare: func(controller *mock_access.MockController) {
+				controller.EXPECT().RequireAll(gomock.Any(), accesstypes.User("zach"), accesstypes.Domain("tenant1"), DeleteRole).
+					Return(httpio.NewForbiddenMessagef("user %s does not have %s", "zach", DeleteRole)).Times(1)
+			},
+			wantStatus: http.StatusForbidden,
+			wantLogged: true,
+		},
+		{
+			name: "domain is invalid",
+			args: args{domain: "tenant3", perms: []accesstypes.Permission{ViewUsers}},
+			prepare: func(controller *mock_access.MockController) {
+				controller.EXPECT().RequireAll(gomock.Any(), accesstypes.User("zach"), accesstypes.Domain("tenant3"), ViewUsers).
+					Return(httpio.NewBadRequestMessage("Invalid Domain")).Times(1)
+			},
+			wantStatus: http.StatusBadRequest,
+			wantLogged: true,
+		},
+		{
+			name: "domain param is missing",
+			args: args{domain: "", perms: []accesstypes.Permission{ViewUsers}},
+			prepare: func(controller *mock_access.MockController) {
+				controller.EXPECT().RequireAll(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "user is not authenticated",
+			args: args{domain: "tenant1", perms: []accesstypes.Permission{ViewUsers}},
+			user: func(*http.Request) (accesstypes.User, error) {
+				return "", httpio.NewUnauthorized()
+			},
+			prepare: func(controller *mock_access.MockController) {
+				controller.EXPECT().RequireAll(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name: "enforcer fails",
+			args: args{domain: "tenant1", perms: []accesstypes.Permission{ViewUsers}},
+			prepare: func(controller *mock_access.MockController) {
+				controller.EXPECT().RequireAll(gomock.Any(), accesstypes.User("zach"), accesstypes.Domain("tenant1"), ViewUsers).
+					Return(errors.New("casbin.IEnforcer Enforce()")).Times(1)
+			},
+			wantStatus: http.StatusInternalServerError,
+			wantLogged: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			ctrl := gomock.NewController(t)
+			controller := mock_access.NewMockController(ctrl)
+			tt.prepare(controller)
+
+			user := tt.user
+			if user == nil {
+				user = func(*http.Request) (accesstypes.User, error) {
+					return "zach", nil
+				}
+			}
+
+			var calledNext bool
+			next := http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+				calledNext = true
+			})
+
+			logHandler, logged := recordingLogHandler()
+			a := New(controller, logHandler, user, DomainParam(paramDomain))
+			rr := httptest.NewRecorder()
+			httpio.WithParams(a.RequireAll(tt.args.perms...)(next)).ServeHTTP(rr, newRequest(t, http.NoBody, map[httpio.ParamType]string{paramDomain: tt.args.domain}))
+
+			// denials and errors reach the log handler
+			if got := *logged != nil; got != tt.wantLogged {
+				t.Errorf("Authorizer.RequireAll() logged error = %v, want error %v", *logged, tt.wantLogged)
+			}
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("Authorizer.RequireAll() status = %d, want %d", rr.Code, tt.wantStatus)
+			}
+			if calledNext != tt.wantNext {
+				t.Errorf("Authorizer.RequireAll() called next = %v, want %v", calledNext, tt.wantNext)
+			}
+		})
+	}
+}
+
+// recordingLogHandler returns a LogHandler that logs with httpio.Log and records the last error returned by a handler.
+func recordingLogHandler() (access.LogHandler, *error) {
+	var logged error
+
+	return func(handler func(w http.ResponseWriter, r *http.Request) error) http.HandlerFunc {
+		return httpio.Log(func(w http.ResponseWriter, r *http.Request) error {
+			logged = handler(w, r)
+
+			return logged
+		})
+	}, &logged
+}
+
+func TestAuthorizer_RequireResources(t *testing.T) {
+	t.Parallel()
+
+	type args struct {
+		domain string
+		body   string
+	}
+	tests := []struct {
+		name       string
+		args       args
+		prepare    func(controller *mock_access.MockController)
+		wantStatus int
+		wantBody   *ForbiddenResponse
+		wantNext   bool
+	}{
+		{
+			name: "user has permission for all fields",
+			args: args{domain: "tenant1", body: `{"name": "zach", "email": "zach@example.com"}`},
+			prepare: func(controller *mock_access.MockController) {
+				controller.EXPECT().RequireResources(gomock.Any(), accesstypes.User("zach"), accesstypes.Domain("tenant1"), accesstypes.Update, Users.ResourceWithTag("email"), Users.ResourceWithTag("name")).
+					Return(true, nil, nil).Times(1)
+			},
+			wantStatus: http.StatusOK,
+			wantNext:   true,
+		},
+		{
+			name: "user is missing permission for a field",
+			args: args{domain: "tenant1", body: `{"name": "zach", "email": "zach@example.com"}`},
+			prepare: func(controller *mock_access.MockController) {
+				controller.EXPECT().RequireResources(gomock.Any(), accesstypes.User("zach"), accesstypes.Domain("tenant1"), accesstypes.Update, Users.ResourceWithTag("email"), Users.ResourceWithTag("name")).
+					Return(false, []accesstypes.Resource{Users.ResourceWithTag("email")}, nil).Times(1)
+			},
+			wantStatus: http.StatusForbidden,
+			wantBody: &ForbiddenResponse{
+				Message:    "user zach does not have Update on all resources",
+				Permission: accesstypes.Update,
+				Missing:    []accesstypes.Resource{Users.ResourceWithTag("email")},
+			},
+		},
+		{
+			name: "domain is invalid",
+			args: args{domain: "tenant3", body: `{"name": "zach"}`},
+			prepare: func(controller *mock_access.MockController) {
+				controller.EXPECT().RequireResources(gomock.Any(), accesstypes.User("zach"), accesstypes.Domain("tenant3"), accesstypes.Update, Users.ResourceWithTag("name")).
+					Return(false, nil, httpio.NewBadRequestMessage("Invalid Domain")).Times(1)
+			},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "body is not a JSON object",
+			args: args{domain: "tenant1", body: `["name"]`},
+			prepare: func(controller *mock_access.MockController) {
+				controller.EXPECT().RequireResources(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "body is null",
+			args: args{domain: "tenant1", body: `null`},
+			prepare: func(controller *mock_access.MockController) {
+				controller.EXPECT().RequireResources(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "body is too large",
+			args: args{domain: "tenant1", body: `{"name": "` + strings.Repeat("z", maxJSONBodyBytes) + `"}`},
+			prepare: func(controller *mock_access.MockController) {
+				controller.EXPECT().RequireResources(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			wantStatus: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name: "body has no fields",
+			args: args{domain: "tenant1", body: `{}`},
+			prepare: func(controller *mock_access.MockController) {
+				controller.EXPECT().RequireResources(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			wantStatus: http.StatusForbidden,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			ctrl := gomock.NewController(t)
+			controller := mock_access.NewMockController(ctrl)
+			tt.prepare(controller)
+
+			user := func(*http.Request) (accesstypes.User, error) {
+				return "zach", nil
+			}
+
+			var gotNextBody string
+			next := http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+				b, _ := io.ReadAll(r.Body)
+				gotNextBody = string(b)
+			})
+
+			a := New(controller, httpio.Log, user, DomainParam(paramDomain))
+			rr := httptest.NewRecorder()
+			req := newRequest(t, strings.NewReader(tt.args.body), map[httpio.ParamType]string{paramDomain: tt.args.domain})
+			httpio.WithParams(a.RequireResources(accesstypes.Update, JSONFieldResources(Users))(next)).ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("Authorizer.RequireResources() status = %d, want %d", rr.Code, tt.wantStatus)
+			}
+			if tt.wantNext && gotNextBody != tt.args.body {
+				t.Errorf("Authorizer.RequireResources() next body = %q, want %q", gotNextBody, tt.args.body)
+			}
+			if tt.wantBody != nil {
+				got := &ForbiddenResponse{}
+				if err := json.Unmarshal(rr.Body.Bytes(), got); err != nil {
+					t.Fatalf("json.Unmarshal() error = %v", err)
+				}
+				if diff := cmp.Diff(tt.wantBody, got); diff != "" {
+					t.Errorf("Authorizer.RequireResources() body mismatch (-want +got):\n%s", diff)
+				}
+			}
+		})
+	}
+}
+
+func newRequest(t *testing.T, body io.Reader, urlParams map[httpio.ParamType]string) *http.Request {
+	t.Helper()
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPatch, "", body)
+	if err != nil {
+		t.Fatalf("http.NewRequestWithContext() error = %v", err)
+	}
+
+	rctx := chi.NewRouteContext()
+	for key, val := range urlParams {
+		rctx.URLParams.Add(string(key), val)
+	}
+
+	return req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+}
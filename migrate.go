@@ -3,7 +3,10 @@ package access
 import (
 	"context"
 	"fmt"
+	"log/slog"
 	"slices"
+	"strings"
+	"time"
 
 	"github.com/cccteam/ccc/accesstypes"
 	"github.com/cccteam/ccc/tracer"
@@ -18,139 +21,378 @@ type PermissionCollection interface {
 	IsResourceImmutable(scope accesstypes.PermissionScope, res accesstypes.Resource) bool
 }
 
-// RoleConfig contains roles for migration.
-type RoleConfig struct {
-	Roles []*Role `json:"roles"`
+// MigrateRoles applies role configuration across all domains. Adds missing roles and permissions,
+// removes extras, and includes Administrator role with all permissions. roleConfig is checked with RoleConfig.Validate
+// before any change is made. Returns a summary of the changes made, which covers the changes made before the failure
+// if an error is returned.
+func MigrateRoles(ctx context.Context, client UserManager, store PermissionCollection, roleConfig *RoleConfig, opts ...MigrateOption) (*MigrationResult, error) {
+	ctx, span := tracer.Start(ctx)
+	defer span.End()
+
+	plan, err := planRoles(ctx, client, store, roleConfig, newMigrateOptions(opts...))
+	if err != nil {
+		return &MigrationResult{}, errors.Wrap(err, "planRoles()")
+	}
+
+	result, err := ApplyMigrationPlan(ctx, client, plan, opts...)
+	if err != nil {
+		return result, errors.Wrap(err, "ApplyMigrationPlan()")
+	}
+
+	return result, nil
 }
 
-// Role defines role name and permissions mapped to resources.
-type Role struct {
-	Name        accesstypes.Role
-	Permissions map[accesstypes.Permission][]accesstypes.Resource
+// PlanMigration returns the changes MigrateRoles would make for roleConfig without changing anything.
+// The plan includes the Administrator role with all permissions. roleConfig is not modified.
+func PlanMigration(ctx context.Context, client UserManager, store PermissionCollection, roleConfig *RoleConfig, opts ...MigrateOption) (*MigrationPlan, error) {
+	ctx, span := tracer.Start(ctx)
+	defer span.End()
+
+	plan, err := planRoles(ctx, client, store, roleConfig, newMigrateOptions(opts...))
+	if err != nil {
+		return nil, errors.Wrap(err, "planRoles()")
+	}
+
+	return plan, nil
 }
 
-// MigrateRoles applies role configuration across all domains. Adds missing roles and permissions,
-// removes extras, and includes Administrator role with all permissions.
-func MigrateRoles(ctx context.Context, client UserManager, store PermissionCollection, roleConfig *RoleConfig) error {
+// ApplyMigrationPlan makes the changes in plan. Each domain's permission changes are applied with UserManager.Apply.
+// Returns a summary of the changes made, which covers the domains completed before the failure if an error is returned.
+func ApplyMigrationPlan(ctx context.Context, client UserManager, plan *MigrationPlan, opts ...MigrateOption) (*MigrationResult, error) {
 	ctx, span := tracer.Start(ctx)
 	defer span.End()
 
-	// Default Administrator role has all permissions
-	roleConfig.Roles = append(roleConfig.Roles, &Role{
-		Name:        "Administrator",
-		Permissions: adminPermissions(store),
-	})
+	o := newMigrateOptions(opts...)
+	result := &MigrationResult{}
+
+	for _, d := range plan.Domains {
+		applied := &DomainPlan{Domain: d.Domain, SkipRoles: d.SkipRoles}
+
+		for _, role := range d.SkipRoles {
+			o.logger.WarnContext(ctx, "kept role missing from config because it has users", "domain", d.Domain, "role", role)
+		}
+
+		for _, role := range d.AddRoles {
+			if err := client.AddRole(ctx, d.Domain, role); err != nil {
+				result.add(applied)
+
+				return result, errors.Wrapf(err, "role %q to domain %s", role, d.Domain)
+			}
+			applied.AddRoles = append(applied.AddRoles, role)
+			o.logger.InfoContext(ctx, "added role", "domain", d.Domain, "role", role)
+		}
 
-	if err := bootstrapRoles(ctx, client, store, roleConfig.Roles); err != nil {
-		return errors.Wrap(err, "bootstrapRoles()")
+		// users are moved off retired roles in the same unit as the permission changes, and before the roles are removed
+		var changes []Change
+		for _, r := range d.ReassignUsers {
+			changes = append(changes, r.changes(d.Domain, time.Now())...)
+		}
+		for _, r := range d.Roles {
+			changes = append(changes, r.changes(d.Domain)...)
+		}
+		if err := client.Apply(ctx, changes...); err != nil {
+			result.add(applied)
+
+			return result, errors.Wrapf(err, "client.Apply(): domain %s", d.Domain)
+		}
+		applied.ReassignUsers = d.ReassignUsers
+		applied.Roles = d.Roles
+
+		for _, r := range d.ReassignUsers {
+			o.logger.InfoContext(ctx, "moved users to replacement role", "domain", d.Domain, "from", r.From, "to", r.To, "users", r.Users)
+		}
+
+		for _, r := range d.Roles {
+			if len(r.AddParents) > 0 {
+				o.logger.InfoContext(ctx, "added parent roles", "domain", d.Domain, "role", r.Role, "parents", r.AddParents)
+			}
+			if len(r.RemoveParents) > 0 {
+				o.logger.InfoContext(ctx, "removed parent roles", "domain", d.Domain, "role", r.Role, "parents", r.RemoveParents)
+			}
+			logPermissions(ctx, o.logger, "added permissions", d.Domain, r.Role, r.AddPermissions)
+			logPermissions(ctx, o.logger, "removed permissions", d.Domain, r.Role, r.RemovePermissions)
+			logPermissions(ctx, o.logger, "added denials", d.Domain, r.Role, r.AddDenials)
+			logPermissions(ctx, o.logger, "removed denials", d.Domain, r.Role, r.RemoveDenials)
+		}
+
+		for _, role := range d.RemoveRoles {
+			deleted, err := client.DeleteRole(ctx, d.Domain, role)
+			if err != nil {
+				result.add(applied)
+
+				return result, errors.Wrap(err, "client.DeleteRole()")
+			}
+			if !deleted {
+				o.logger.WarnContext(ctx, "role was already removed", "domain", d.Domain, "role", role)
+
+				continue
+			}
+			applied.RemoveRoles = append(applied.RemoveRoles, role)
+			o.logger.InfoContext(ctx, "removed role", "domain", d.Domain, "role", role)
+		}
+
+		result.add(applied)
 	}
 
-	return nil
+	o.logger.InfoContext(ctx, "role migration complete",
+		"rolesAdded", result.RolesAdded, "rolesRemoved", result.RolesRemoved,
+		"permissionsAdded", result.PermissionsAdded, "permissionsRemoved", result.PermissionsRemoved,
+		"denialsAdded", result.DenialsAdded, "denialsRemoved", result.DenialsRemoved,
+		"parentsAdded", result.ParentsAdded, "parentsRemoved", result.ParentsRemoved,
+		"usersReassigned", result.UsersReassigned, "rolesSkipped", result.RolesSkipped,
+	)
+
+	return result, nil
+}
+
+func logPermissions(
+	ctx context.Context, logger *slog.Logger, msg string, domain accesstypes.Domain, role accesstypes.Role, permissions map[accesstypes.Permission][]accesstypes.Resource,
+) {
+	if len(permissions) > 0 {
+		logger.InfoContext(ctx, msg, "domain", domain, "role", role, "permissions", permissions)
+	}
 }
 
-func bootstrapRoles(ctx context.Context, client UserManager, store PermissionCollection, roles []*Role) error {
+func planRoles(ctx context.Context, client UserManager, store PermissionCollection, roleConfig *RoleConfig, o *migrateOptions) (*MigrationPlan, error) {
 	ctx, span := tracer.Start(ctx)
 	defer span.End()
 
+	if err := roleConfig.Validate(store); err != nil {
+		return nil, err
+	}
+
+	// Default Administrator role has all permissions
+	roles, replacements := splitRetiredRoles(append(slices.Clone(roleConfig.Roles), administratorRole(store)))
+
 	domains, err := client.Domains(ctx)
 	if err != nil {
-		return errors.Wrap(err, "UserManager.Domains()")
+		return nil, errors.Wrap(err, "UserManager.Domains()")
 	}
 
-	if err := removeUnusedRoles(ctx, domains, client, roles); err != nil {
-		return err
+	scopedRoles := make([]scopedRole, 0, len(roles))
+	for _, r := range roles {
+		scopedRoles = append(scopedRoles, newScopedRole(store, r))
 	}
 
-	storePermissions := store.List()
+	plan := &MigrationPlan{}
+	var orphaned []string
+	for _, domain := range domains {
+		d := &DomainPlan{Domain: domain}
 
-	for _, r := range roles {
-		globalPermResources := make(map[accesstypes.Permission][]accesstypes.Resource)
-		domainPermResources := make(map[accesstypes.Permission][]accesstypes.Resource)
-		for perm, resources := range r.Permissions {
-			for _, resource := range resources {
-				if r := store.Scope(resource); r == "" {
-					return errors.Newf("resource %s does not require a permission or does not exist", resource)
-				} else if r == accesstypes.GlobalPermissionScope {
-					globalPermResources[perm] = append(globalPermResources[perm], resource)
-				} else {
-					domainPermResources[perm] = append(domainPermResources[perm], resource)
-				}
-
-				if !slices.Contains(storePermissions[perm], resource) {
-					return errors.Newf("resource %s does not require permission %s", resource, perm)
-				}
-
-				if perm == accesstypes.Update && store.IsResourceImmutable(store.Scope(resource), resource) {
-					return errors.Newf("role %s cannot have update permission on immutable resource %s", r.Name, resource)
-				}
-			}
+		existingRoles, err := client.Roles(ctx, domain)
+		if err != nil {
+			return nil, errors.Wrap(err, "client.Roles()")
 		}
 
-		for _, domain := range domains {
-			if !client.RoleExists(ctx, domain, r.Name) {
-				if err := client.AddRole(ctx, domain, r.Name); err != nil {
-					return errors.Wrapf(err, "role %q to domain %s", r.Name, domain)
-				}
-				fmt.Printf("Added role %q to domain %s\n", r.Name, domain)
-			}
+		domainOrphaned, err := planRetiredRoles(ctx, client, d, existingRoles, roles, replacements, o)
+		if err != nil {
+			return nil, err
+		}
+		orphaned = append(orphaned, domainOrphaned...)
 
-			perms := globalPermResources
-			if domain != accesstypes.GlobalDomain {
-				perms = domainPermResources
+		for _, r := range scopedRoles {
+			exists := slices.Contains(existingRoles, r.Name)
+			if !exists {
+				d.AddRoles = append(d.AddRoles, r.Name)
 			}
 
-			existingPermissions, err := client.RolePermissions(ctx, domain, r.Name)
+			rp, err := planRole(ctx, client, domain, r, exists)
 			if err != nil {
-				return errors.Wrapf(err, "role %q to domain %s", r.Name, domain)
-			}
-
-			newPermissions := exclude(perms, existingPermissions)
-			for permission, resources := range newPermissions {
-				if err := client.AddRolePermissionResources(ctx, domain, r.Name, permission, resources...); err != nil {
-					return errors.Wrapf(err, "permissions %v, role %s", perms, r.Name)
-				}
+				return nil, err
 			}
-			if len(newPermissions) > 0 {
-				fmt.Printf("Added Permissions %v to role %s and domain %s\n", newPermissions, r.Name, domain)
+			if rp.hasChanges() {
+				d.Roles = append(d.Roles, rp)
 			}
+		}
 
-			removePermissions := exclude(existingPermissions, perms)
-			for permission, resources := range removePermissions {
-				if err := client.DeleteRolePermissionResources(ctx, domain, r.Name, permission, resources...); err != nil {
-					return errors.Wrapf(err, "permissions %v, role %s", perms, r.Name)
-				}
-			}
-			if len(removePermissions) > 0 {
-				fmt.Printf("Removed Permissions %v from role %s and domain %s\n", removePermissions, r.Name, domain)
+		if d.hasChanges() {
+			plan.Domains = append(plan.Domains, d)
+		}
+	}
+
+	if len(orphaned) > 0 {
+		return nil, errors.Newf("roles missing from config still have users, set ReplacedBy or use OrphanedRolesSkip: %s", strings.Join(orphaned, "; "))
+	}
+
+	return plan, nil
+}
+
+// scopedRole is a role from the config with its permissions and denials split into global and domain scoped resources.
+type scopedRole struct {
+	*Role
+	globalPerms, domainPerms     map[accesstypes.Permission][]accesstypes.Resource
+	globalDenials, domainDenials map[accesstypes.Permission][]accesstypes.Resource
+}
+
+func newScopedRole(store PermissionCollection, r *Role) scopedRole {
+	s := scopedRole{Role: r}
+	s.globalPerms, s.domainPerms = scopePermissions(store, r.Permissions)
+	s.globalDenials, s.domainDenials = scopePermissions(store, r.Denials)
+
+	return s
+}
+
+// scoped returns the permissions and denials the role has in domain.
+func (s scopedRole) scoped(domain accesstypes.Domain) (perms, denials map[accesstypes.Permission][]accesstypes.Resource) {
+	if domain == accesstypes.GlobalDomain {
+		return s.globalPerms, s.globalDenials
+	}
+
+	return s.domainPerms, s.domainDenials
+}
+
+// planRetiredRoles adds the existing roles of d that are missing from the config to the plan: their users are moved
+// to the replacement, or the role is skipped when it has users and orphaned roles are skipped, and the role is removed
+// otherwise. Returns a description of each role that can't be removed because it still has users.
+func planRetiredRoles(
+	ctx context.Context, client UserManager, d *DomainPlan, existingRoles []accesstypes.Role, roles []*Role,
+	replacements map[accesstypes.Role]accesstypes.Role, o *migrateOptions,
+) ([]string, error) {
+	var orphaned []string
+	for _, er := range existingRoles {
+		if slices.ContainsFunc(roles, func(r *Role) bool { return r.Name == er }) {
+			continue
+		}
+
+		users, err := client.RoleUsers(ctx, d.Domain, er)
+		if err != nil {
+			return nil, errors.Wrap(err, "client.RoleUsers()")
+		}
+
+		switch replacement, ok := replacements[er]; {
+		case ok && len(users) > 0:
+			r, err := reassignment(ctx, client, d.Domain, er, replacement, users)
+			if err != nil {
+				return nil, err
 			}
+			d.ReassignUsers = append(d.ReassignUsers, r)
+		case len(users) > 0 && o.orphanedRoles == OrphanedRolesSkip:
+			d.SkipRoles = append(d.SkipRoles, er)
+
+			continue
+		case len(users) > 0:
+			orphaned = append(orphaned, fmt.Sprintf("role %s in domain %s has %d users", er, d.Domain, len(users)))
+
+			continue
 		}
+
+		d.RemoveRoles = append(d.RemoveRoles, er)
 	}
 
-	return nil
+	return orphaned, nil
 }
 
-func removeUnusedRoles(ctx context.Context, domains []accesstypes.Domain, client UserManager, newRoles []*Role) error {
-	for _, domain := range domains {
-		existingRoles, err := client.Roles(ctx, domain)
+// planRole returns the permissions, denials and parents to add to and remove from role r in domain. A role that
+// doesn't exist yet has none to remove.
+func planRole(ctx context.Context, client UserManager, domain accesstypes.Domain, r scopedRole, exists bool) (*RolePlan, error) {
+	perms, denials := r.scoped(domain)
+
+	existingPermissions := make(accesstypes.RolePermissionCollection)
+	existingDenials := make(accesstypes.RolePermissionCollection)
+	var existingParents []accesstypes.Role
+	if exists {
+		var err error
+		existingPermissions, err = client.RolePermissions(ctx, domain, r.Name)
+		if err != nil {
+			return nil, errors.Wrapf(err, "role %q to domain %s", r.Name, domain)
+		}
+
+		existingDenials, err = client.RolePermissionDenials(ctx, domain, r.Name)
+		if err != nil {
+			return nil, errors.Wrapf(err, "role %q to domain %s", r.Name, domain)
+		}
+
+		existingParents, err = client.RoleParents(ctx, domain, r.Name)
+		if err != nil {
+			return nil, errors.Wrapf(err, "role %q to domain %s", r.Name, domain)
+		}
+	}
+
+	return &RolePlan{
+		Role:              r.Name,
+		AddParents:        excludeRoles(r.Parents, existingParents),
+		RemoveParents:     excludeRoles(existingParents, r.Parents),
+		AddPermissions:    exclude(perms, existingPermissions),
+		RemovePermissions: exclude(existingPermissions, perms),
+		AddDenials:        exclude(denials, existingDenials),
+		RemoveDenials:     exclude(existingDenials, denials),
+	}, nil
+}
+
+// reassignment returns the reassignment of users from a retired role to its replacement with the expiry of their
+// time-bound assignments. A user with time-bound assignments to both roles keeps the later expiry, and a user who
+// already has the replacement permanently keeps it permanently.
+func reassignment(
+	ctx context.Context, client UserManager, domain accesstypes.Domain, from, to accesstypes.Role, users []accesstypes.User,
+) (*RoleReassignment, error) {
+	r := &RoleReassignment{From: from, To: to, Users: users}
+
+	replacementUsers, err := client.RoleUsers(ctx, domain, to)
+	if err != nil {
+		return nil, errors.Wrap(err, "client.RoleUsers()")
+	}
+
+	for _, user := range users {
+		access, err := client.User(ctx, user, domain)
 		if err != nil {
-			return errors.Wrap(err, "client.Roles()")
+			return nil, errors.Wrap(err, "client.User()")
 		}
 
-	EXISTING:
-		for _, er := range existingRoles {
-			for _, nr := range newRoles {
-				if nr.Name == er {
-					continue EXISTING
-				}
+		expiresAt, ok := access.RoleExpirations[domain][from]
+		if !ok {
+			continue
+		}
+		if slices.Contains(replacementUsers, user) {
+			replacementExpiresAt, ok := access.RoleExpirations[domain][to]
+			if !ok {
+				continue
+			}
+			if replacementExpiresAt.After(expiresAt) {
+				expiresAt = replacementExpiresAt
 			}
-			if _, err := client.DeleteRole(ctx, domain, er); err != nil {
-				return errors.Wrap(err, "client.DeleteRole()")
+		}
+
+		if r.ExpiresAt == nil {
+			r.ExpiresAt = make(map[accesstypes.User]time.Time)
+		}
+		r.ExpiresAt[user] = expiresAt
+	}
+
+	return r, nil
+}
+
+// splitRetiredRoles separates roles with ReplacedBy set from the roles to migrate, and returns the replacement for each retired role.
+func splitRetiredRoles(configRoles []*Role) ([]*Role, map[accesstypes.Role]accesstypes.Role) {
+	roles := make([]*Role, 0, len(configRoles))
+	replacements := make(map[accesstypes.Role]accesstypes.Role)
+	for _, r := range configRoles {
+		if r.ReplacedBy == "" {
+			roles = append(roles, r)
+		} else {
+			replacements[r.Name] = r.ReplacedBy
+		}
+	}
+
+	return roles, replacements
+}
+
+// scopePermissions splits validated permissions into global and domain scoped resources
+func scopePermissions(
+	store PermissionCollection, permissions map[accesstypes.Permission][]accesstypes.Resource,
+) (global, domain map[accesstypes.Permission][]accesstypes.Resource) {
+	global = make(map[accesstypes.Permission][]accesstypes.Resource)
+	domain = make(map[accesstypes.Permission][]accesstypes.Resource)
+	for perm, resources := range permissions {
+		for _, resource := range resources {
+			if store.Scope(resource) == accesstypes.GlobalPermissionScope {
+				global[perm] = append(global[perm], resource)
+			} else {
+				domain[perm] = append(domain[perm], resource)
 			}
-			fmt.Printf("Removed old Role %s\n", er)
 		}
 	}
 
-	return nil
+	return global, domain
 }
 
 // exclude returns all elements that exist in source but not exclude
@@ -170,6 +412,28 @@ func exclude(source, exclude map[accesstypes.Permission][]accesstypes.Resource)
 	return list
 }
 
+// excludeRoles returns the roles in source that are not in exclude
+func excludeRoles(source, exclude []accesstypes.Role) []accesstypes.Role {
+	var list []accesstypes.Role
+	for _, role := range source {
+		if !slices.Contains(exclude, role) {
+			list = append(list, role)
+		}
+	}
+
+	return list
+}
+
+// administratorRoleName is the role migrations add with all permissions. It cannot be declared in a RoleConfig.
+const administratorRoleName accesstypes.Role = "Administrator"
+
+func administratorRole(store PermissionCollection) *Role {
+	return &Role{
+		Name:        administratorRoleName,
+		Permissions: adminPermissions(store),
+	}
+}
+
 // The Administrator should have all legal permissions, this function will prevent any updates to immutable resources
 func adminPermissions(store PermissionCollection) map[accesstypes.Permission][]accesstypes.Resource {
 	list := store.List()
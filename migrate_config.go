@@ -0,0 +1,471 @@
+package access
+
+import (
+	"fmt"
+	"io"
+	"iter"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/cccteam/ccc/accesstypes"
+	"github.com/go-playground/errors/v5"
+	"gopkg.in/yaml.v3"
+)
+
+// RoleConfig contains roles for migration.
+type RoleConfig struct {
+	Roles []*Role `json:"roles" yaml:"roles"`
+
+	// source names the file the config was loaded from, used in RoleConfigError
+	source string
+}
+
+// Role defines role name and permissions mapped to resources. Denials take precedence over permissions granted
+// by any role. A role inherits the permissions and denials of its Parents, which must be other roles in the config.
+//
+// A role with ReplacedBy set is retired: it is removed from every domain and its users are moved to the
+// ReplacedBy role, which must be another role in the config. Its permissions and denials are ignored.
+type Role struct {
+	Name        accesstypes.Role                                  `json:"name" yaml:"name"`
+	Parents     []accesstypes.Role                                `json:"parents,omitempty" yaml:"parents,omitempty"`
+	Permissions map[accesstypes.Permission][]accesstypes.Resource `json:"permissions,omitempty" yaml:"permissions,omitempty"`
+	Denials     map[accesstypes.Permission][]accesstypes.Resource `json:"denials,omitempty" yaml:"denials,omitempty"`
+	ReplacedBy  accesstypes.Role                                  `json:"replacedBy,omitempty" yaml:"replacedBy,omitempty"`
+
+	// lines records where the role was declared when it is loaded with LoadRoleConfig
+	lines *roleLines
+}
+
+type roleLines struct {
+	role       int
+	replacedBy int
+	parents    map[accesstypes.Role]int
+	resources  map[resourceLine]int
+}
+
+type resourceLine struct {
+	denial     bool
+	permission accesstypes.Permission
+	resource   accesstypes.Resource
+}
+
+func (r *Role) line() int {
+	if r.lines == nil {
+		return 0
+	}
+
+	return r.lines.role
+}
+
+func (r *Role) replacedByLine() int {
+	if r.lines == nil {
+		return 0
+	}
+
+	return r.lines.replacedBy
+}
+
+func (r *Role) parentLine(parent accesstypes.Role) int {
+	if r.lines == nil {
+		return 0
+	}
+
+	if line, ok := r.lines.parents[parent]; ok {
+		return line
+	}
+
+	return r.lines.role
+}
+
+func (r *Role) resourceLine(denial bool, permission accesstypes.Permission, resource accesstypes.Resource) int {
+	if r.lines == nil {
+		return 0
+	}
+
+	if line, ok := r.lines.resources[resourceLine{denial: denial, permission: permission, resource: resource}]; ok {
+		return line
+	}
+
+	return r.lines.role
+}
+
+// RoleConfigError is a problem with a role config. Line is zero when the config was not loaded with LoadRoleConfig.
+type RoleConfigError struct {
+	Source  string
+	Line    int
+	Role    accesstypes.Role
+	Message string
+}
+
+func (e *RoleConfigError) Error() string {
+	var b strings.Builder
+	if e.Source != "" {
+		b.WriteString(e.Source)
+		if e.Line > 0 {
+			fmt.Fprintf(&b, ":%d", e.Line)
+		}
+		b.WriteString(": ")
+	}
+	if e.Role != "" {
+		fmt.Fprintf(&b, "role %s: ", e.Role)
+	}
+	b.WriteString(e.Message)
+
+	return b.String()
+}
+
+// RoleConfigErrors lists every problem found in a role config, ordered by line.
+type RoleConfigErrors []*RoleConfigError
+
+func (e RoleConfigErrors) Error() string {
+	problems := make([]string, 0, len(e))
+	for _, err := range e {
+		problems = append(problems, err.Error())
+	}
+
+	return fmt.Sprintf("role config has %d problems:\n%s", len(e), strings.Join(problems, "\n"))
+}
+
+// LoadRoleConfig reads a role config in JSON or YAML. Field names are matched case-insensitively.
+// If r has a Name method, like *os.File, the name is used in errors. Every problem in the config
+// is returned together as RoleConfigErrors. Use RoleConfig.Validate to check the roles against the
+// permissions of a PermissionCollection.
+func LoadRoleConfig(r io.Reader) (*RoleConfig, error) {
+	source := "role config"
+	if n, ok := r.(interface{ Name() string }); ok {
+		source = n.Name()
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "io.ReadAll()")
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, errors.Wrapf(err, "yaml.Unmarshal(): %s", source)
+	}
+
+	l := &configLoader{source: source}
+	config := l.config(&doc)
+	if len(l.errs) > 0 {
+		return nil, l.errs
+	}
+
+	return config, nil
+}
+
+// configLoader walks a parsed config, collecting every problem instead of stopping at the first one.
+type configLoader struct {
+	source string
+	errs   RoleConfigErrors
+}
+
+func (l *configLoader) errorf(line int, role accesstypes.Role, format string, args ...any) {
+	l.errs = append(l.errs, &RoleConfigError{Source: l.source, Line: line, Role: role, Message: fmt.Sprintf(format, args...)})
+}
+
+func (l *configLoader) config(doc *yaml.Node) *RoleConfig {
+	config := &RoleConfig{source: l.source}
+
+	if len(doc.Content) == 0 {
+		l.errorf(0, "", "config is empty")
+
+		return config
+	}
+
+	root := doc.Content[0]
+	if !l.expect(root, yaml.MappingNode, "") {
+		return config
+	}
+
+	for key, value := range mappingPairs(root) {
+		switch {
+		case strings.EqualFold(key.Value, "roles"):
+			config.Roles = l.roles(value)
+		default:
+			l.errorf(key.Line, "", "unknown field %q", key.Value)
+		}
+	}
+
+	return config
+}
+
+func (l *configLoader) roles(node *yaml.Node) []*Role {
+	if !l.expect(node, yaml.SequenceNode, "") {
+		return nil
+	}
+
+	roles := make([]*Role, 0, len(node.Content))
+	for _, item := range node.Content {
+		if role := l.role(item); role != nil {
+			roles = append(roles, role)
+		}
+	}
+
+	return roles
+}
+
+func (l *configLoader) role(node *yaml.Node) *Role {
+	if !l.expect(node, yaml.MappingNode, "") {
+		return nil
+	}
+
+	role := &Role{lines: &roleLines{role: node.Line, parents: make(map[accesstypes.Role]int), resources: make(map[resourceLine]int)}}
+
+	// read the name first so problems with the other fields can name the role
+	for key, value := range mappingPairs(node) {
+		if strings.EqualFold(key.Value, "name") {
+			if name, ok := l.scalar(value, ""); ok {
+				role.Name = accesstypes.Role(name)
+			}
+		}
+	}
+
+	for key, value := range mappingPairs(node) {
+		switch {
+		case strings.EqualFold(key.Value, "name"):
+		case strings.EqualFold(key.Value, "parents"):
+			role.Parents = l.parents(value, role)
+		case strings.EqualFold(key.Value, "permissions"):
+			role.Permissions = l.permissions(value, role, false)
+		case strings.EqualFold(key.Value, "denials"):
+			role.Denials = l.permissions(value, role, true)
+		case strings.EqualFold(key.Value, "replacedBy"):
+			if replacedBy, ok := l.scalar(value, role.Name); ok {
+				role.ReplacedBy = accesstypes.Role(replacedBy)
+				role.lines.replacedBy = value.Line
+			}
+		default:
+			l.errorf(key.Line, role.Name, "unknown field %q", key.Value)
+		}
+	}
+
+	return role
+}
+
+func (l *configLoader) parents(node *yaml.Node, role *Role) []accesstypes.Role {
+	if !l.expect(node, yaml.SequenceNode, role.Name) {
+		return nil
+	}
+
+	parents := make([]accesstypes.Role, 0, len(node.Content))
+	for _, item := range node.Content {
+		if parent, ok := l.scalar(item, role.Name); ok {
+			parents = append(parents, accesstypes.Role(parent))
+			role.lines.parents[accesstypes.Role(parent)] = item.Line
+		}
+	}
+
+	return parents
+}
+
+func (l *configLoader) permissions(node *yaml.Node, role *Role, denial bool) map[accesstypes.Permission][]accesstypes.Resource {
+	if !l.expect(node, yaml.MappingNode, role.Name) {
+		return nil
+	}
+
+	permissions := make(map[accesstypes.Permission][]accesstypes.Resource)
+	for key, value := range mappingPairs(node) {
+		perm := accesstypes.Permission(key.Value)
+		if !l.expect(value, yaml.SequenceNode, role.Name) {
+			continue
+		}
+
+		for _, item := range value.Content {
+			resource, ok := l.scalar(item, role.Name)
+			if !ok {
+				continue
+			}
+
+			permissions[perm] = append(permissions[perm], accesstypes.Resource(resource))
+			role.lines.resources[resourceLine{denial: denial, permission: perm, resource: accesstypes.Resource(resource)}] = item.Line
+		}
+	}
+
+	return permissions
+}
+
+func (l *configLoader) scalar(node *yaml.Node, role accesstypes.Role) (string, bool) {
+	if !l.expect(node, yaml.ScalarNode, role) {
+		return "", false
+	}
+
+	if node.Tag == "!!null" {
+		l.errorf(node.Line, role, "expected a value, found null")
+
+		return "", false
+	}
+
+	return node.Value, true
+}
+
+func (l *configLoader) expect(node *yaml.Node, kind yaml.Kind, role accesstypes.Role) bool {
+	if node.Kind != kind {
+		l.errorf(node.Line, role, "expected %s, found %s", kindName(kind), kindName(node.Kind))
+
+		return false
+	}
+
+	return true
+}
+
+// mappingPairs iterates over the keys and values of a mapping node.
+func mappingPairs(node *yaml.Node) iter.Seq2[*yaml.Node, *yaml.Node] {
+	return func(yield func(key, value *yaml.Node) bool) {
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if !yield(node.Content[i], node.Content[i+1]) {
+				return
+			}
+		}
+	}
+}
+
+func kindName(kind yaml.Kind) string {
+	switch kind {
+	case yaml.DocumentNode:
+		return "a document"
+	case yaml.SequenceNode:
+		return "a list"
+	case yaml.MappingNode:
+		return "an object"
+	case yaml.ScalarNode:
+		return "a value"
+	case yaml.AliasNode:
+		return "an alias"
+	default:
+		return "nothing"
+	}
+}
+
+// Validate checks the roles against the permissions in store: names must be unique and not empty,
+// ReplacedBy and Parents must name other roles in the config, Parents must not form a cycle, and every
+// resource must exist and require the permission it is listed under. Every problem is returned together
+// as RoleConfigErrors, with lines when the config was loaded with LoadRoleConfig. MigrateRoles and
+// PlanMigration call Validate before planning any change.
+func (c *RoleConfig) Validate(store PermissionCollection) error {
+	var errs RoleConfigErrors
+	errorf := func(line int, role accesstypes.Role, format string, args ...any) {
+		errs = append(errs, &RoleConfigError{Source: c.source, Line: line, Role: role, Message: fmt.Sprintf(format, args...)})
+	}
+
+	declared := make(map[accesstypes.Role]bool)
+	active := map[accesstypes.Role]bool{administratorRoleName: true}
+	for _, r := range c.Roles {
+		switch {
+		case r.Name == "":
+			errorf(r.line(), "", "role name cannot be empty")
+		case r.Name == administratorRoleName:
+			errorf(r.line(), r.Name, "role is added with all permissions and cannot be declared")
+		case declared[r.Name]:
+			errorf(r.line(), r.Name, "role is declared more than once")
+		}
+		declared[r.Name] = true
+		if r.ReplacedBy == "" {
+			active[r.Name] = true
+		}
+	}
+
+	storePermissions := store.List()
+	for _, r := range c.Roles {
+		if r.ReplacedBy != "" {
+			if !active[r.ReplacedBy] {
+				errorf(r.replacedByLine(), r.Name, "replaced by %s, which is not a role in the config", r.ReplacedBy)
+			}
+
+			continue
+		}
+
+		for _, parent := range r.Parents {
+			switch {
+			case parent == r.Name:
+				errorf(r.parentLine(parent), r.Name, "role cannot inherit itself")
+			case !active[parent]:
+				errorf(r.parentLine(parent), r.Name, "parent %s is not a role in the config", parent)
+			}
+		}
+
+		validatePermissions(store, storePermissions, r, false, r.Permissions, errorf)
+		validatePermissions(store, storePermissions, r, true, r.Denials, errorf)
+	}
+
+	validateParentCycles(c.Roles, errorf)
+
+	if len(errs) == 0 {
+		return nil
+	}
+
+	slices.SortStableFunc(errs, func(a, b *RoleConfigError) int { return a.Line - b.Line })
+
+	return errs
+}
+
+// validateParentCycles reports every role whose parents lead back to it through other roles.
+func validateParentCycles(roles []*Role, errorf func(line int, role accesstypes.Role, format string, args ...any)) {
+	byName := make(map[accesstypes.Role]*Role, len(roles))
+	for _, r := range roles {
+		if r.ReplacedBy == "" {
+			byName[r.Name] = r
+		}
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[accesstypes.Role]int, len(byName))
+	var path []accesstypes.Role
+	var visit func(r *Role)
+	visit = func(r *Role) {
+		state[r.Name] = visiting
+		path = append(path, r.Name)
+		for _, parent := range r.Parents {
+			p, ok := byName[parent]
+			if !ok || parent == r.Name {
+				continue
+			}
+
+			switch state[parent] {
+			case visiting:
+				cycle := append(slices.Clone(path[slices.Index(path, parent):]), parent)
+				names := make([]string, 0, len(cycle))
+				for _, name := range cycle {
+					names = append(names, string(name))
+				}
+				errorf(r.parentLine(parent), r.Name, "parents form a cycle: %s", strings.Join(names, " -> "))
+			case unvisited:
+				visit(p)
+			}
+		}
+		path = path[:len(path)-1]
+		state[r.Name] = visited
+	}
+
+	for _, r := range roles {
+		if state[r.Name] == unvisited && byName[r.Name] == r {
+			visit(r)
+		}
+	}
+}
+
+func validatePermissions(
+	store PermissionCollection, storePermissions map[accesstypes.Permission][]accesstypes.Resource, r *Role, denial bool,
+	permissions map[accesstypes.Permission][]accesstypes.Resource, errorf func(line int, role accesstypes.Role, format string, args ...any),
+) {
+	for _, perm := range slices.Sorted(maps.Keys(permissions)) {
+		for _, resource := range permissions[perm] {
+			line := r.resourceLine(denial, perm, resource)
+			scope := store.Scope(resource)
+			switch {
+			case scope == "":
+				errorf(line, r.Name, "resource %s does not require a permission or does not exist", resource)
+			case !slices.Contains(storePermissions[perm], resource):
+				errorf(line, r.Name, "resource %s does not require permission %s", resource, perm)
+			case !denial && perm == accesstypes.Update && store.IsResourceImmutable(scope, resource):
+				errorf(line, r.Name, "cannot have update permission on immutable resource %s", resource)
+			}
+		}
+	}
+}
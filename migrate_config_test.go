@@ -0,0 +1,220 @@
+package access
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/cccteam/ccc/accesstypes"
+	"github.com/go-playground/errors/v5"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestLoadRoleConfig(t *testing.T) {
+	t.Parallel()
+
+	want := &RoleConfig{
+		Roles: []*Role{
+			{
+				Name:        "Viewer",
+				Permissions: map[accesstypes.Permission][]accesstypes.Resource{"Read": {"Documents", "Settings"}},
+				Denials:     map[accesstypes.Permission][]accesstypes.Resource{"Delete": {"Documents"}},
+			},
+			{Name: "Editor", Parents: []accesstypes.Role{"Viewer"}},
+			{Name: "Reader", ReplacedBy: "Viewer"},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		config  string
+		want    *RoleConfig
+		wantErr []string
+	}{
+		{
+			name: "json",
+			config: `{
+	"roles": [
+		{
+			"name": "Viewer",
+			"permissions": {"Read": ["Documents", "Settings"]},
+			"denials": {"Delete": ["Documents"]}
+		},
+		{"name": "Editor", "parents": ["Viewer"]},
+		{"name": "Reader", "replacedBy": "Viewer"}
+	]
+}`,
+			want: want,
+		},
+		{
+			name: "json with field names matching the struct",
+			config: `{"Roles": [
+	{"Name": "Viewer", "Permissions": {"Read": ["Documents", "Settings"]}, "Denials": {"Delete": ["Documents"]}},
+	{"Name": "Editor", "Parents": ["Viewer"]},
+	{"Name": "Reader", "ReplacedBy": "Viewer"}
+]}`,
+			want: want,
+		},
+		{
+			name: "yaml",
+			config: `roles:
+  - name: Viewer
+    permissions:
+      Read: [Documents, Settings]
+    denials:
+      Delete:
+        - Documents
+  - name: Editor
+    parents:
+      - Viewer
+  - name: Reader
+    replacedBy: Viewer
+`,
+			want: want,
+		},
+		{
+			name: "reports every problem with its line",
+			config: `roles:
+  - name: Viewer
+    permision:
+      Read: [Documents]
+  - name: Editor
+    permissions:
+      Read: Documents
+  - name:
+    replacedBy: [Viewer]
+extra: true
+`,
+			wantErr: []string{
+				`role config:3: role Viewer: unknown field "permision"`,
+				"role config:7: role Editor: expected a list, found a value",
+				"role config:8: expected a value, found null",
+				"role config:9: expected a value, found a list",
+				`role config:10: unknown field "extra"`,
+			},
+		},
+		{
+			name:    "empty",
+			config:  "",
+			wantErr: []string{"role config: config is empty"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := LoadRoleConfig(strings.NewReader(tt.config))
+			if tt.wantErr != nil {
+				var errs RoleConfigErrors
+				if !errors.As(err, &errs) {
+					t.Fatalf("LoadRoleConfig() error = %v, want RoleConfigErrors", err)
+				}
+				gotErr := make([]string, 0, len(errs))
+				for _, e := range errs {
+					gotErr = append(gotErr, e.Error())
+				}
+				if diff := cmp.Diff(tt.wantErr, gotErr); diff != "" {
+					t.Errorf("LoadRoleConfig() errors mismatch (-want +got):\n%s", diff)
+				}
+
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadRoleConfig() error = %v", err)
+			}
+			if diff := cmp.Diff(tt.want, got, cmpopts.IgnoreUnexported(RoleConfig{}, Role{}), cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("LoadRoleConfig() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestLoadRoleConfig_syntaxError(t *testing.T) {
+	t.Parallel()
+
+	if _, err := LoadRoleConfig(strings.NewReader(`{"roles": [`)); err == nil {
+		t.Errorf("LoadRoleConfig() error = nil, want syntax error")
+	}
+}
+
+func TestRoleConfig_Validate(t *testing.T) {
+	t.Parallel()
+
+	f, err := os.Open("testdata/roles_invalid.yaml")
+	if err != nil {
+		t.Fatalf("os.Open() error = %v", err)
+	}
+	defer f.Close()
+
+	config, err := LoadRoleConfig(f)
+	if err != nil {
+		t.Fatalf("LoadRoleConfig() error = %v", err)
+	}
+
+	err = config.Validate(permissionStore{})
+	var errs RoleConfigErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("RoleConfig.Validate() error = %v, want RoleConfigErrors", err)
+	}
+
+	got := make([]string, 0, len(errs))
+	for _, e := range errs {
+		got = append(got, e.Error())
+	}
+	want := []string{
+		"testdata/roles_invalid.yaml:4: role Viewer: resource Settings does not require permission Delete",
+		"testdata/roles_invalid.yaml:5: role Viewer: resource Reports does not require a permission or does not exist",
+		"testdata/roles_invalid.yaml:6: role Viewer: role is declared more than once",
+		"testdata/roles_invalid.yaml:7: role Administrator: role is added with all permissions and cannot be declared",
+		"testdata/roles_invalid.yaml:9: role Reader: replaced by Author, which is not a role in the config",
+		"testdata/roles_invalid.yaml:10: role name cannot be empty",
+		"testdata/roles_invalid.yaml:15: role B: parent Missing is not a role in the config",
+		"testdata/roles_invalid.yaml:15: role B: parents form a cycle: A -> B -> A",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("RoleConfig.Validate() mismatch (-want +got):\n%s", diff)
+	}
+
+	valid := &RoleConfig{Roles: []*Role{{Name: "Viewer", Permissions: map[accesstypes.Permission][]accesstypes.Resource{"Read": {"Settings"}}}}}
+	if err := valid.Validate(permissionStore{}); err != nil {
+		t.Errorf("RoleConfig.Validate() error = %v", err)
+	}
+}
+
+// immutableStore makes Update a permission of Settings, which is immutable.
+type immutableStore struct {
+	permissionStore
+}
+
+func (immutableStore) List() map[accesstypes.Permission][]accesstypes.Resource {
+	list := permissionStore{}.List()
+	list[accesstypes.Update] = []accesstypes.Resource{"Settings"}
+
+	return list
+}
+
+func (immutableStore) IsResourceImmutable(_ accesstypes.PermissionScope, res accesstypes.Resource) bool {
+	return res == "Settings"
+}
+
+func TestRoleConfig_Validate_immutable(t *testing.T) {
+	t.Parallel()
+
+	update := map[accesstypes.Permission][]accesstypes.Resource{accesstypes.Update: {"Settings"}}
+	config := &RoleConfig{Roles: []*Role{
+		{Name: "Editor", Permissions: update},
+		{Name: "Viewer", Denials: update},
+	}}
+
+	err := config.Validate(immutableStore{})
+	var errs RoleConfigErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("RoleConfig.Validate() error = %v, want RoleConfigErrors", err)
+	}
+
+	// denying an update on an immutable resource is allowed
+	if len(errs) != 1 || errs[0].Role != "Editor" {
+		t.Errorf("RoleConfig.Validate() errors = %v, want only the update permission of Editor", errs)
+	}
+}
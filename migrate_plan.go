@@ -0,0 +1,237 @@
+package access
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/cccteam/ccc/accesstypes"
+	"github.com/go-playground/errors/v5"
+)
+
+// MigrationPlan lists the changes a role migration makes in each domain. Domains without changes are omitted.
+type MigrationPlan struct {
+	Domains []*DomainPlan `json:"domains"`
+}
+
+// DomainPlan lists the roles added and removed in a domain and the permission changes for each role.
+// SkipRoles are roles missing from the config that are kept because they still have users.
+type DomainPlan struct {
+	Domain        accesstypes.Domain  `json:"domain"`
+	AddRoles      []accesstypes.Role  `json:"addRoles,omitempty"`
+	RemoveRoles   []accesstypes.Role  `json:"removeRoles,omitempty"`
+	SkipRoles     []accesstypes.Role  `json:"skipRoles,omitempty"`
+	ReassignUsers []*RoleReassignment `json:"reassignUsers,omitempty"`
+	Roles         []*RolePlan         `json:"roles,omitempty"`
+}
+
+// RoleReassignment moves the users of a retired role to its replacement. Users whose assignment to the retired role
+// is time-bound are assigned the replacement until it expires.
+type RoleReassignment struct {
+	From  accesstypes.Role   `json:"from"`
+	To    accesstypes.Role   `json:"to"`
+	Users []accesstypes.User `json:"users"`
+	// ExpiresAt is when the time-bound assignments of the users expire, by user.
+	ExpiresAt map[accesstypes.User]time.Time `json:"expiresAt,omitempty"`
+}
+
+// RolePlan lists the permissions, denials and parent roles added to and removed from a role in a domain.
+type RolePlan struct {
+	Role              accesstypes.Role                                  `json:"role"`
+	AddParents        []accesstypes.Role                                `json:"addParents,omitempty"`
+	RemoveParents     []accesstypes.Role                                `json:"removeParents,omitempty"`
+	AddPermissions    map[accesstypes.Permission][]accesstypes.Resource `json:"addPermissions,omitempty"`
+	RemovePermissions map[accesstypes.Permission][]accesstypes.Resource `json:"removePermissions,omitempty"`
+	AddDenials        map[accesstypes.Permission][]accesstypes.Resource `json:"addDenials,omitempty"`
+	RemoveDenials     map[accesstypes.Permission][]accesstypes.Resource `json:"removeDenials,omitempty"`
+}
+
+// MigrationResult summarizes the changes a role migration made. Permission and denial counts are the number of
+// permission and resource pairs.
+type MigrationResult struct {
+	RolesAdded         int           `json:"rolesAdded"`
+	RolesRemoved       int           `json:"rolesRemoved"`
+	PermissionsAdded   int           `json:"permissionsAdded"`
+	PermissionsRemoved int           `json:"permissionsRemoved"`
+	DenialsAdded       int           `json:"denialsAdded"`
+	DenialsRemoved     int           `json:"denialsRemoved"`
+	ParentsAdded       int           `json:"parentsAdded"`
+	ParentsRemoved     int           `json:"parentsRemoved"`
+	UsersReassigned    int           `json:"usersReassigned"`
+	RolesSkipped       int           `json:"rolesSkipped"`
+	Domains            []*DomainPlan `json:"domains"`
+}
+
+func (r *MigrationResult) add(d *DomainPlan) {
+	if !d.hasChanges() {
+		return
+	}
+
+	r.Domains = append(r.Domains, d)
+	r.RolesAdded += len(d.AddRoles)
+	r.RolesRemoved += len(d.RemoveRoles)
+	r.RolesSkipped += len(d.SkipRoles)
+	for _, reassignment := range d.ReassignUsers {
+		r.UsersReassigned += len(reassignment.Users)
+	}
+	for _, role := range d.Roles {
+		r.PermissionsAdded += countResources(role.AddPermissions)
+		r.PermissionsRemoved += countResources(role.RemovePermissions)
+		r.DenialsAdded += countResources(role.AddDenials)
+		r.DenialsRemoved += countResources(role.RemoveDenials)
+		r.ParentsAdded += len(role.AddParents)
+		r.ParentsRemoved += len(role.RemoveParents)
+	}
+}
+
+func countResources(permissions map[accesstypes.Permission][]accesstypes.Resource) int {
+	var n int
+	for _, resources := range permissions {
+		n += len(resources)
+	}
+
+	return n
+}
+
+// HasChanges reports whether applying the plan changes anything.
+func (p *MigrationPlan) HasChanges() bool {
+	return len(p.Domains) > 0
+}
+
+// WriteJSON writes the plan to w as indented JSON.
+func (p *MigrationPlan) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(p); err != nil {
+		return errors.Wrap(err, "json.Encoder.Encode()")
+	}
+
+	return nil
+}
+
+// WriteText writes the plan to w as a human readable diff, with + for additions and - for removals.
+func (p *MigrationPlan) WriteText(w io.Writer) error {
+	if _, err := io.WriteString(w, p.String()); err != nil {
+		return errors.Wrap(err, "io.WriteString()")
+	}
+
+	return nil
+}
+
+// String returns the plan as a human readable diff, with + for additions and - for removals.
+func (p *MigrationPlan) String() string {
+	if !p.HasChanges() {
+		return "No changes\n"
+	}
+
+	var b strings.Builder
+	for _, d := range p.Domains {
+		fmt.Fprintf(&b, "domain %s\n", d.Domain)
+		for _, role := range d.AddRoles {
+			fmt.Fprintf(&b, "  + role %s\n", role)
+		}
+		for _, role := range d.RemoveRoles {
+			fmt.Fprintf(&b, "  - role %s\n", role)
+		}
+		for _, role := range d.SkipRoles {
+			fmt.Fprintf(&b, "  ! role %s kept, it has users\n", role)
+		}
+		for _, r := range d.ReassignUsers {
+			users := make([]string, 0, len(r.Users))
+			for _, user := range r.Users {
+				if expiresAt, ok := r.ExpiresAt[user]; ok {
+					users = append(users, fmt.Sprintf("%s (until %s)", user, expiresAt.UTC().Format(expiryLayout)))
+				} else {
+					users = append(users, string(user))
+				}
+			}
+			fmt.Fprintf(&b, "  ~ users %s move from role %s to %s\n", strings.Join(users, ", "), r.From, r.To)
+		}
+		for _, r := range d.Roles {
+			fmt.Fprintf(&b, "  role %s\n", r.Role)
+			for _, parent := range r.AddParents {
+				fmt.Fprintf(&b, "    + parent %s\n", parent)
+			}
+			for _, parent := range r.RemoveParents {
+				fmt.Fprintf(&b, "    - parent %s\n", parent)
+			}
+			writePermissions(&b, "+ permission", r.AddPermissions)
+			writePermissions(&b, "- permission", r.RemovePermissions)
+			writePermissions(&b, "+ denial", r.AddDenials)
+			writePermissions(&b, "- denial", r.RemoveDenials)
+		}
+	}
+
+	return b.String()
+}
+
+func writePermissions(b *strings.Builder, prefix string, permissions map[accesstypes.Permission][]accesstypes.Resource) {
+	for _, perm := range slices.Sorted(maps.Keys(permissions)) {
+		resources := make([]string, 0, len(permissions[perm]))
+		for _, res := range permissions[perm] {
+			resources = append(resources, string(res))
+		}
+		slices.Sort(resources)
+
+		fmt.Fprintf(b, "    %s %s: %s\n", prefix, perm, strings.Join(resources, ", "))
+	}
+}
+
+func (d *DomainPlan) hasChanges() bool {
+	return len(d.AddRoles) > 0 || len(d.RemoveRoles) > 0 || len(d.SkipRoles) > 0 || len(d.ReassignUsers) > 0 || len(d.Roles) > 0
+}
+
+func (r *RolePlan) hasChanges() bool {
+	return len(r.AddParents) > 0 || len(r.RemoveParents) > 0 ||
+		len(r.AddPermissions) > 0 || len(r.RemovePermissions) > 0 || len(r.AddDenials) > 0 || len(r.RemoveDenials) > 0
+}
+
+// changes returns the changes that move the users, assigning the replacement before removing the retired role. Users
+// whose time-bound assignment expired before now are only removed from the retired role.
+func (r *RoleReassignment) changes(domain accesstypes.Domain, now time.Time) []Change {
+	var permanent []accesstypes.User
+	var changes []Change
+	for _, user := range r.Users {
+		expiresAt, ok := r.ExpiresAt[user]
+		switch {
+		case !ok:
+			permanent = append(permanent, user)
+		case expiresAt.After(now):
+			changes = append(changes, AddRoleUsersUntilChange(domain, r.To, expiresAt, user))
+		}
+	}
+	if len(permanent) > 0 {
+		changes = append(changes, AddRoleUsersChange(domain, r.To, permanent...))
+	}
+
+	return append(changes, DeleteRoleUsersChange(domain, r.From, r.Users...))
+}
+
+// changes returns the plan's permission changes in the order they are applied, removals first.
+func (r *RolePlan) changes(domain accesstypes.Domain) []Change {
+	var changes []Change
+	if len(r.RemoveParents) > 0 {
+		changes = append(changes, DeleteRoleParentsChange(domain, r.Role, r.RemoveParents...))
+	}
+	for perm, resources := range r.RemovePermissions {
+		changes = append(changes, DeleteRolePermissionResourcesChange(domain, r.Role, perm, resources...))
+	}
+	for perm, resources := range r.RemoveDenials {
+		changes = append(changes, DeleteRolePermissionResourceDenialsChange(domain, r.Role, perm, resources...))
+	}
+	for perm, resources := range r.AddPermissions {
+		changes = append(changes, AddRolePermissionResourcesChange(domain, r.Role, perm, resources...))
+	}
+	for perm, resources := range r.AddDenials {
+		changes = append(changes, AddRolePermissionResourceDenialsChange(domain, r.Role, perm, resources...))
+	}
+	if len(r.AddParents) > 0 {
+		changes = append(changes, AddRoleParentsChange(domain, r.Role, r.AddParents...))
+	}
+
+	return changes
+}
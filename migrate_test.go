@@ -2,12 +2,194 @@
 package access
 
 import (
+	"bytes"
+	"context"
+	"log/slog"
 	"reflect"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/casbin/casbin/v2"
 	"github.com/cccteam/ccc/accesstypes"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"go.uber.org/mock/gomock"
 )
 
+type permissionStore struct{}
+
+func (permissionStore) List() map[accesstypes.Permission][]accesstypes.Resource {
+	return map[accesstypes.Permission][]accesstypes.Resource{
+		"Read":   {"Documents", "Settings"},
+		"Delete": {"Documents"},
+	}
+}
+
+func (permissionStore) Scope(res accesstypes.Resource) accesstypes.PermissionScope {
+	switch res {
+	case "Settings":
+		return accesstypes.GlobalPermissionScope
+	case "Documents":
+		return accesstypes.DomainPermissionScope
+	default:
+		return ""
+	}
+}
+
+func (permissionStore) IsResourceImmutable(accesstypes.PermissionScope, accesstypes.Resource) bool {
+	return false
+}
+
+func newMigrateUserManager(t *testing.T, policyPath string) *userManager {
+	t.Helper()
+
+	enforcer, err := mockEnforcer(policyPath)
+	if err != nil {
+		t.Fatalf("failed to load policies. err=%s", err)
+	}
+
+	domains := NewMockDomains(gomock.NewController(t))
+	domains.EXPECT().DomainIDs(gomock.Any()).Return([]string{"tenant1"}, nil).AnyTimes()
+	domains.EXPECT().DomainExists(gomock.Any(), "tenant1").Return(true, nil).AnyTimes()
+
+	return &userManager{
+		domains: domains,
+		Enforcer: func() (casbin.IEnforcer, error) {
+			return enforcer, nil
+		},
+	}
+}
+
+func TestPlanMigration(t *testing.T) {
+	t.Parallel()
+
+	roleConfig := &RoleConfig{
+		Roles: []*Role{
+			{
+				Name:        "Editor",
+				Parents:     []accesstypes.Role{"Viewer"},
+				Permissions: map[accesstypes.Permission][]accesstypes.Resource{"Read": {"Documents", "Settings"}},
+			},
+			{
+				Name:        "Viewer",
+				Permissions: map[accesstypes.Permission][]accesstypes.Resource{"Read": {"Documents"}},
+				Denials:     map[accesstypes.Permission][]accesstypes.Resource{"Delete": {"Documents"}},
+			},
+		},
+	}
+	want := &MigrationPlan{
+		Domains: []*DomainPlan{
+			{
+				Domain:   accesstypes.GlobalDomain,
+				AddRoles: []accesstypes.Role{"Viewer", "Administrator"},
+				Roles: []*RolePlan{
+					{
+						Role:       "Editor",
+						AddParents: []accesstypes.Role{"Viewer"},
+					},
+					{
+						Role:           "Administrator",
+						AddPermissions: map[accesstypes.Permission][]accesstypes.Resource{"Read": {"Settings"}},
+					},
+				},
+			},
+			{
+				Domain:      "tenant1",
+				AddRoles:    []accesstypes.Role{"Viewer", "Administrator"},
+				RemoveRoles: []accesstypes.Role{"Retired"},
+				Roles: []*RolePlan{
+					{
+						Role:              "Editor",
+						AddParents:        []accesstypes.Role{"Viewer"},
+						RemoveParents:     []accesstypes.Role{"Retired"},
+						RemovePermissions: map[accesstypes.Permission][]accesstypes.Resource{"Delete": {"Documents"}},
+					},
+					{
+						Role:           "Viewer",
+						AddPermissions: map[accesstypes.Permission][]accesstypes.Resource{"Read": {"Documents"}},
+						AddDenials:     map[accesstypes.Permission][]accesstypes.Resource{"Delete": {"Documents"}},
+					},
+					{
+						Role:           "Administrator",
+						AddPermissions: map[accesstypes.Permission][]accesstypes.Resource{"Read": {"Documents"}, "Delete": {"Documents"}},
+					},
+				},
+			},
+		},
+	}
+	wantText := `domain global
+  + role Viewer
+  + role Administrator
+  role Editor
+    + parent Viewer
+  role Administrator
+    + permission Read: Settings
+domain tenant1
+  + role Viewer
+  + role Administrator
+  - role Retired
+  role Editor
+    + parent Viewer
+    - parent Retired
+    - permission Delete: Documents
+  role Viewer
+    + permission Read: Documents
+    + denial Delete: Documents
+  role Administrator
+    + permission Delete: Documents
+    + permission Read: Documents
+`
+
+	ctx := context.Background()
+	u := newMigrateUserManager(t, "testdata/policy_migrate.csv")
+
+	plan, err := PlanMigration(ctx, u, permissionStore{}, roleConfig)
+	if err != nil {
+		t.Fatalf("PlanMigration() error = %v", err)
+	}
+	if diff := cmp.Diff(want, plan, cmpopts.EquateEmpty()); diff != "" {
+		t.Errorf("PlanMigration() mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(wantText, plan.String()); diff != "" {
+		t.Errorf("MigrationPlan.String() mismatch (-want +got):\n%s", diff)
+	}
+	if len(roleConfig.Roles) != 2 {
+		t.Errorf("PlanMigration() modified roleConfig, got %d roles, want 2", len(roleConfig.Roles))
+	}
+
+	var logs bytes.Buffer
+	result, err := ApplyMigrationPlan(ctx, u, plan, WithMigrationLogger(slog.New(slog.NewJSONHandler(&logs, nil))))
+	if err != nil {
+		t.Fatalf("ApplyMigrationPlan() error = %v", err)
+	}
+	wantResult := &MigrationResult{
+		RolesAdded:         4,
+		RolesRemoved:       1,
+		PermissionsAdded:   4,
+		PermissionsRemoved: 1,
+		DenialsAdded:       1,
+		ParentsAdded:       2,
+		ParentsRemoved:     1,
+		Domains:            want.Domains,
+	}
+	if diff := cmp.Diff(wantResult, result, cmpopts.EquateEmpty()); diff != "" {
+		t.Errorf("ApplyMigrationPlan() mismatch (-want +got):\n%s", diff)
+	}
+	if got := strings.Count(logs.String(), `"msg":"added role"`); got != 4 {
+		t.Errorf("ApplyMigrationPlan() logged %d added roles, want 4", got)
+	}
+
+	plan, err = PlanMigration(ctx, u, permissionStore{}, roleConfig)
+	if err != nil {
+		t.Fatalf("PlanMigration() error = %v", err)
+	}
+	if plan.HasChanges() {
+		t.Errorf("PlanMigration() after ApplyMigrationPlan() has changes:\n%s", plan)
+	}
+}
+
 func Test_exclude(t *testing.T) {
 	t.Parallel()
 
@@ -54,3 +236,178 @@ func Test_exclude(t *testing.T) {
 		})
 	}
 }
+
+func TestMigrateRoles_orphanedRoles(t *testing.T) {
+	t.Parallel()
+
+	editor := &Role{
+		Name:        "Editor",
+		Permissions: map[accesstypes.Permission][]accesstypes.Resource{"Read": {"Documents"}},
+	}
+
+	tests := []struct {
+		name      string
+		roles     []*Role
+		opts      []MigrateOption
+		wantErr   bool
+		wantRoles []accesstypes.Role
+		wantUsers map[accesstypes.Role][]accesstypes.User
+		wantSkip  int
+		wantMoved int
+	}{
+		{
+			name:      "fails when orphaned roles have users",
+			roles:     []*Role{editor},
+			wantErr:   true,
+			wantRoles: []accesstypes.Role{"Editor", "Legacy", "Writer"},
+		},
+		{
+			name:      "skips orphaned roles with users",
+			roles:     []*Role{editor},
+			opts:      []MigrateOption{WithOrphanedRoles(OrphanedRolesSkip)},
+			wantRoles: []accesstypes.Role{"Administrator", "Editor", "Legacy", "Writer"},
+			wantUsers: map[accesstypes.Role][]accesstypes.User{"Legacy": {"bob"}, "Writer": {"alice"}},
+			wantSkip:  2,
+		},
+		{
+			name:      "moves users to replacement role",
+			roles:     []*Role{editor, {Name: "Writer", ReplacedBy: "Editor"}},
+			opts:      []MigrateOption{WithOrphanedRoles(OrphanedRolesSkip)},
+			wantRoles: []accesstypes.Role{"Administrator", "Editor", "Legacy"},
+			wantUsers: map[accesstypes.Role][]accesstypes.User{"Editor": {"alice"}, "Legacy": {"bob"}},
+			wantSkip:  1,
+			wantMoved: 1,
+		},
+		{
+			name:      "fails when replacement is not in config",
+			roles:     []*Role{editor, {Name: "Writer", ReplacedBy: "Author"}},
+			opts:      []MigrateOption{WithOrphanedRoles(OrphanedRolesSkip)},
+			wantErr:   true,
+			wantRoles: []accesstypes.Role{"Editor", "Legacy", "Writer"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+			u := newMigrateUserManager(t, "testdata/policy_migrate_orphaned.csv")
+			opts := append(tt.opts, WithMigrationLogger(slog.New(slog.DiscardHandler)))
+
+			result, err := MigrateRoles(ctx, u, permissionStore{}, &RoleConfig{Roles: tt.roles}, opts...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("MigrateRoles() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if result.RolesSkipped != tt.wantSkip {
+				t.Errorf("MigrateRoles() RolesSkipped = %d, want %d", result.RolesSkipped, tt.wantSkip)
+			}
+			if result.UsersReassigned != tt.wantMoved {
+				t.Errorf("MigrateRoles() UsersReassigned = %d, want %d", result.UsersReassigned, tt.wantMoved)
+			}
+
+			roles, err := u.Roles(ctx, "tenant1")
+			if err != nil {
+				t.Fatalf("userManager.Roles() error = %v", err)
+			}
+			if diff := cmp.Diff(tt.wantRoles, roles); diff != "" {
+				t.Errorf("userManager.Roles() mismatch (-want +got):\n%s", diff)
+			}
+
+			for role, want := range tt.wantUsers {
+				users, err := u.RoleUsers(ctx, "tenant1", role)
+				if err != nil {
+					t.Fatalf("userManager.RoleUsers() error = %v", err)
+				}
+				if diff := cmp.Diff(want, users); diff != "" {
+					t.Errorf("userManager.RoleUsers(%s) mismatch (-want +got):\n%s", role, diff)
+				}
+			}
+		})
+	}
+}
+
+func TestMigrateRoles_timedReassignment(t *testing.T) {
+	t.Parallel()
+
+	const policy = `p, role:Editor, domain:tenant1, resource:Documents, perm:Read, allow
+p, role:Writer, domain:tenant1, resource:Documents, perm:Read, allow
+g, noop, role:Editor, domain:tenant1
+g, noop, role:Writer, domain:tenant1
+g, user:alice, role:Writer, domain:tenant1, 2999-01-01T00:00:00Z
+`
+	expiresAt := time.Date(2999, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name          string
+		policy        string
+		wantExpiresAt map[accesstypes.User]time.Time
+		want          [][]string
+	}{
+		{
+			name:          "keeps the expiry on the replacement role",
+			policy:        policy,
+			wantExpiresAt: map[accesstypes.User]time.Time{"alice": expiresAt},
+			want:          [][]string{{"user:alice", "role:Editor", "domain:tenant1", "2999-01-01T00:00:00Z"}},
+		},
+		{
+			name:          "keeps the later expiry of the replacement role",
+			policy:        policy + "g, user:alice, role:Editor, domain:tenant1, 2999-06-01T00:00:00Z\n",
+			wantExpiresAt: map[accesstypes.User]time.Time{"alice": time.Date(2999, 6, 1, 0, 0, 0, 0, time.UTC)},
+			want:          [][]string{{"user:alice", "role:Editor", "domain:tenant1", "2999-06-01T00:00:00Z"}},
+		},
+		{
+			name:   "keeps a permanent assignment to the replacement role",
+			policy: policy + "g, user:alice, role:Editor, domain:tenant1\n",
+			want:   [][]string{{"user:alice", "role:Editor", "domain:tenant1"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+			memory, err := NewMemoryAdapter(tt.policy)
+			if err != nil {
+				t.Fatalf("NewMemoryAdapter() error = %v", err)
+			}
+			enforcer, err := casbin.NewSyncedEnforcer(newTestModel(t), memory)
+			if err != nil {
+				t.Fatalf("casbin.NewSyncedEnforcer() error = %v", err)
+			}
+
+			domains := NewMockDomains(gomock.NewController(t))
+			domains.EXPECT().DomainIDs(gomock.Any()).Return([]string{"tenant1"}, nil).AnyTimes()
+			domains.EXPECT().DomainExists(gomock.Any(), "tenant1").Return(true, nil).AnyTimes()
+			u := &userManager{
+				domains: domains,
+				Enforcer: func() (casbin.IEnforcer, error) {
+					return enforcer, nil
+				},
+			}
+
+			roleConfig := &RoleConfig{Roles: []*Role{
+				{Name: "Editor", Permissions: map[accesstypes.Permission][]accesstypes.Resource{"Read": {"Documents"}}},
+				{Name: "Writer", ReplacedBy: "Editor"},
+			}}
+			result, err := MigrateRoles(ctx, u, permissionStore{}, roleConfig, WithMigrationLogger(slog.New(slog.DiscardHandler)))
+			if err != nil {
+				t.Fatalf("MigrateRoles() error = %v", err)
+			}
+			i := slices.IndexFunc(result.Domains, func(d *DomainPlan) bool { return d.Domain == "tenant1" })
+			if i < 0 || len(result.Domains[i].ReassignUsers) != 1 {
+				t.Fatalf("MigrateRoles() Domains = %v, want users reassigned in tenant1", result.Domains)
+			}
+			if diff := cmp.Diff(tt.wantExpiresAt, result.Domains[i].ReassignUsers[0].ExpiresAt); diff != "" {
+				t.Errorf("MigrateRoles() ExpiresAt mismatch (-want +got):\n%s", diff)
+			}
+
+			got, err := enforcer.GetFilteredGroupingPolicy(0, accesstypes.User("alice").Marshal())
+			if err != nil {
+				t.Fatalf("enforcer.GetFilteredGroupingPolicy() error = %v", err)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("enforcer.GetFilteredGroupingPolicy() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -54,6 +54,20 @@ func (mr *MockHandlersMockRecorder) AddRole() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddRole", reflect.TypeOf((*MockHandlers)(nil).AddRole))
 }
 
+// AddRolePermissionDenials mocks base method.
+func (m *MockHandlers) AddRolePermissionDenials() http.HandlerFunc {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddRolePermissionDenials")
+	ret0, _ := ret[0].(http.HandlerFunc)
+	return ret0
+}
+
+// AddRolePermissionDenials indicates an expected call of AddRolePermissionDenials.
+func (mr *MockHandlersMockRecorder) AddRolePermissionDenials() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddRolePermissionDenials", reflect.TypeOf((*MockHandlers)(nil).AddRolePermissionDenials))
+}
+
 // AddRolePermissions mocks base method.
 func (m *MockHandlers) AddRolePermissions() http.HandlerFunc {
 	m.ctrl.T.Helper()
@@ -96,6 +110,20 @@ func (mr *MockHandlersMockRecorder) DeleteRole() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteRole", reflect.TypeOf((*MockHandlers)(nil).DeleteRole))
 }
 
+// DeleteRolePermissionDenials mocks base method.
+func (m *MockHandlers) DeleteRolePermissionDenials() http.HandlerFunc {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteRolePermissionDenials")
+	ret0, _ := ret[0].(http.HandlerFunc)
+	return ret0
+}
+
+// DeleteRolePermissionDenials indicates an expected call of DeleteRolePermissionDenials.
+func (mr *MockHandlersMockRecorder) DeleteRolePermissionDenials() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteRolePermissionDenials", reflect.TypeOf((*MockHandlers)(nil).DeleteRolePermissionDenials))
+}
+
 // DeleteRolePermissions mocks base method.
 func (m *MockHandlers) DeleteRolePermissions() http.HandlerFunc {
 	m.ctrl.T.Helper()
@@ -124,6 +152,34 @@ func (mr *MockHandlersMockRecorder) DeleteRoleUsers() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteRoleUsers", reflect.TypeOf((*MockHandlers)(nil).DeleteRoleUsers))
 }
 
+// Explain mocks base method.
+func (m *MockHandlers) Explain() http.HandlerFunc {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Explain")
+	ret0, _ := ret[0].(http.HandlerFunc)
+	return ret0
+}
+
+// Explain indicates an expected call of Explain.
+func (mr *MockHandlersMockRecorder) Explain() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Explain", reflect.TypeOf((*MockHandlers)(nil).Explain))
+}
+
+// RolePermissionDenials mocks base method.
+func (m *MockHandlers) RolePermissionDenials() http.HandlerFunc {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RolePermissionDenials")
+	ret0, _ := ret[0].(http.HandlerFunc)
+	return ret0
+}
+
+// RolePermissionDenials indicates an expected call of RolePermissionDenials.
+func (mr *MockHandlersMockRecorder) RolePermissionDenials() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RolePermissionDenials", reflect.TypeOf((*MockHandlers)(nil).RolePermissionDenials))
+}
+
 // RolePermissions mocks base method.
 func (m *MockHandlers) RolePermissions() http.HandlerFunc {
 	m.ctrl.T.Helper()
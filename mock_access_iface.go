@@ -12,6 +12,7 @@ package access
 import (
 	context "context"
 	reflect "reflect"
+	time "time"
 
 	accesstypes "github.com/cccteam/ccc/accesstypes"
 	gomock "go.uber.org/mock/gomock"
@@ -41,6 +42,36 @@ func (m *MockController) EXPECT() *MockControllerMockRecorder {
 	return m.recorder
 }
 
+// CheckMany mocks base method.
+func (m *MockController) CheckMany(ctx context.Context, requests []CheckRequest) ([]CheckResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CheckMany", ctx, requests)
+	ret0, _ := ret[0].([]CheckResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CheckMany indicates an expected call of CheckMany.
+func (mr *MockControllerMockRecorder) CheckMany(ctx, requests any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckMany", reflect.TypeOf((*MockController)(nil).CheckMany), ctx, requests)
+}
+
+// Explain mocks base method.
+func (m *MockController) Explain(ctx context.Context, user accesstypes.User, domain accesstypes.Domain, perm accesstypes.Permission, resource accesstypes.Resource) (*Explanation, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Explain", ctx, user, domain, perm, resource)
+	ret0, _ := ret[0].(*Explanation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Explain indicates an expected call of Explain.
+func (mr *MockControllerMockRecorder) Explain(ctx, user, domain, perm, resource any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Explain", reflect.TypeOf((*MockController)(nil).Explain), ctx, user, domain, perm, resource)
+}
+
 // Handlers mocks base method.
 func (m *MockController) Handlers(handler LogHandler) Handlers {
 	m.ctrl.T.Helper()
@@ -55,6 +86,48 @@ func (mr *MockControllerMockRecorder) Handlers(handler any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Handlers", reflect.TypeOf((*MockController)(nil).Handlers), handler)
 }
 
+// HasAll mocks base method.
+func (m *MockController) HasAll(ctx context.Context, user accesstypes.User, domain accesstypes.Domain, permissions ...accesstypes.Permission) (bool, []accesstypes.Permission, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, user, domain}
+	for _, a := range permissions {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "HasAll", varargs...)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].([]accesstypes.Permission)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// HasAll indicates an expected call of HasAll.
+func (mr *MockControllerMockRecorder) HasAll(ctx, user, domain any, permissions ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, user, domain}, permissions...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HasAll", reflect.TypeOf((*MockController)(nil).HasAll), varargs...)
+}
+
+// HasAny mocks base method.
+func (m *MockController) HasAny(ctx context.Context, user accesstypes.User, domain accesstypes.Domain, permissions ...accesstypes.Permission) (bool, []accesstypes.Permission, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, user, domain}
+	for _, a := range permissions {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "HasAny", varargs...)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].([]accesstypes.Permission)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// HasAny indicates an expected call of HasAny.
+func (mr *MockControllerMockRecorder) HasAny(ctx, user, domain any, permissions ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, user, domain}, permissions...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HasAny", reflect.TypeOf((*MockController)(nil).HasAny), varargs...)
+}
+
 // RequireAll mocks base method.
 func (m *MockController) RequireAll(ctx context.Context, user accesstypes.User, domain accesstypes.Domain, permissions ...accesstypes.Permission) error {
 	m.ctrl.T.Helper()
@@ -168,6 +241,63 @@ func (mr *MockUserManagerMockRecorder) AddRole(ctx, domain, role any) *gomock.Ca
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddRole", reflect.TypeOf((*MockUserManager)(nil).AddRole), ctx, domain, role)
 }
 
+// AddRoleParents mocks base method.
+func (m *MockUserManager) AddRoleParents(ctx context.Context, domain accesstypes.Domain, role accesstypes.Role, parents ...accesstypes.Role) error {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, domain, role}
+	for _, a := range parents {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "AddRoleParents", varargs...)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddRoleParents indicates an expected call of AddRoleParents.
+func (mr *MockUserManagerMockRecorder) AddRoleParents(ctx, domain, role any, parents ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, domain, role}, parents...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddRoleParents", reflect.TypeOf((*MockUserManager)(nil).AddRoleParents), varargs...)
+}
+
+// AddRolePermissionDenials mocks base method.
+func (m *MockUserManager) AddRolePermissionDenials(ctx context.Context, domain accesstypes.Domain, role accesstypes.Role, permissions ...accesstypes.Permission) error {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, domain, role}
+	for _, a := range permissions {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "AddRolePermissionDenials", varargs...)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddRolePermissionDenials indicates an expected call of AddRolePermissionDenials.
+func (mr *MockUserManagerMockRecorder) AddRolePermissionDenials(ctx, domain, role any, permissions ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, domain, role}, permissions...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddRolePermissionDenials", reflect.TypeOf((*MockUserManager)(nil).AddRolePermissionDenials), varargs...)
+}
+
+// AddRolePermissionResourceDenials mocks base method.
+func (m *MockUserManager) AddRolePermissionResourceDenials(ctx context.Context, domain accesstypes.Domain, role accesstypes.Role, permission accesstypes.Permission, resources ...accesstypes.Resource) error {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, domain, role, permission}
+	for _, a := range resources {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "AddRolePermissionResourceDenials", varargs...)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddRolePermissionResourceDenials indicates an expected call of AddRolePermissionResourceDenials.
+func (mr *MockUserManagerMockRecorder) AddRolePermissionResourceDenials(ctx, domain, role, permission any, resources ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, domain, role, permission}, resources...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddRolePermissionResourceDenials", reflect.TypeOf((*MockUserManager)(nil).AddRolePermissionResourceDenials), varargs...)
+}
+
 // AddRolePermissionResources mocks base method.
 func (m *MockUserManager) AddRolePermissionResources(ctx context.Context, domain accesstypes.Domain, role accesstypes.Role, permission accesstypes.Permission, resources ...accesstypes.Resource) error {
 	m.ctrl.T.Helper()
@@ -225,6 +355,25 @@ func (mr *MockUserManagerMockRecorder) AddRoleUsers(ctx, domain, role any, users
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddRoleUsers", reflect.TypeOf((*MockUserManager)(nil).AddRoleUsers), varargs...)
 }
 
+// AddRoleUsersUntil mocks base method.
+func (m *MockUserManager) AddRoleUsersUntil(ctx context.Context, domain accesstypes.Domain, role accesstypes.Role, expiresAt time.Time, users ...accesstypes.User) error {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, domain, role, expiresAt}
+	for _, a := range users {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "AddRoleUsersUntil", varargs...)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddRoleUsersUntil indicates an expected call of AddRoleUsersUntil.
+func (mr *MockUserManagerMockRecorder) AddRoleUsersUntil(ctx, domain, role, expiresAt any, users ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, domain, role, expiresAt}, users...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddRoleUsersUntil", reflect.TypeOf((*MockUserManager)(nil).AddRoleUsersUntil), varargs...)
+}
+
 // AddUserRoles mocks base method.
 func (m *MockUserManager) AddUserRoles(ctx context.Context, domain accesstypes.Domain, user accesstypes.User, roles ...accesstypes.Role) error {
 	m.ctrl.T.Helper()
@@ -244,6 +393,25 @@ func (mr *MockUserManagerMockRecorder) AddUserRoles(ctx, domain, user any, roles
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddUserRoles", reflect.TypeOf((*MockUserManager)(nil).AddUserRoles), varargs...)
 }
 
+// Apply mocks base method.
+func (m *MockUserManager) Apply(ctx context.Context, changes ...Change) error {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx}
+	for _, a := range changes {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "Apply", varargs...)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Apply indicates an expected call of Apply.
+func (mr *MockUserManagerMockRecorder) Apply(ctx any, changes ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx}, changes...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Apply", reflect.TypeOf((*MockUserManager)(nil).Apply), varargs...)
+}
+
 // DeleteAllRolePermissions mocks base method.
 func (m *MockUserManager) DeleteAllRolePermissions(ctx context.Context, domain accesstypes.Domain, role accesstypes.Role) error {
 	m.ctrl.T.Helper()
@@ -258,6 +426,21 @@ func (mr *MockUserManagerMockRecorder) DeleteAllRolePermissions(ctx, domain, rol
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteAllRolePermissions", reflect.TypeOf((*MockUserManager)(nil).DeleteAllRolePermissions), ctx, domain, role)
 }
 
+// DeleteExpiredRoleUsers mocks base method.
+func (m *MockUserManager) DeleteExpiredRoleUsers(ctx context.Context) ([]RoleAssignment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteExpiredRoleUsers", ctx)
+	ret0, _ := ret[0].([]RoleAssignment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteExpiredRoleUsers indicates an expected call of DeleteExpiredRoleUsers.
+func (mr *MockUserManagerMockRecorder) DeleteExpiredRoleUsers(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteExpiredRoleUsers", reflect.TypeOf((*MockUserManager)(nil).DeleteExpiredRoleUsers), ctx)
+}
+
 // DeleteRole mocks base method.
 func (m *MockUserManager) DeleteRole(ctx context.Context, domain accesstypes.Domain, role accesstypes.Role) (bool, error) {
 	m.ctrl.T.Helper()
@@ -273,6 +456,78 @@ func (mr *MockUserManagerMockRecorder) DeleteRole(ctx, domain, role any) *gomock
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteRole", reflect.TypeOf((*MockUserManager)(nil).DeleteRole), ctx, domain, role)
 }
 
+// DeleteRoleAllDomains mocks base method.
+func (m *MockUserManager) DeleteRoleAllDomains(ctx context.Context, role accesstypes.Role) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteRoleAllDomains", ctx, role)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteRoleAllDomains indicates an expected call of DeleteRoleAllDomains.
+func (mr *MockUserManagerMockRecorder) DeleteRoleAllDomains(ctx, role any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteRoleAllDomains", reflect.TypeOf((*MockUserManager)(nil).DeleteRoleAllDomains), ctx, role)
+}
+
+// DeleteRoleParents mocks base method.
+func (m *MockUserManager) DeleteRoleParents(ctx context.Context, domain accesstypes.Domain, role accesstypes.Role, parents ...accesstypes.Role) error {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, domain, role}
+	for _, a := range parents {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "DeleteRoleParents", varargs...)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteRoleParents indicates an expected call of DeleteRoleParents.
+func (mr *MockUserManagerMockRecorder) DeleteRoleParents(ctx, domain, role any, parents ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, domain, role}, parents...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteRoleParents", reflect.TypeOf((*MockUserManager)(nil).DeleteRoleParents), varargs...)
+}
+
+// DeleteRolePermissionDenials mocks base method.
+func (m *MockUserManager) DeleteRolePermissionDenials(ctx context.Context, domain accesstypes.Domain, role accesstypes.Role, permissions ...accesstypes.Permission) error {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, domain, role}
+	for _, a := range permissions {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "DeleteRolePermissionDenials", varargs...)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteRolePermissionDenials indicates an expected call of DeleteRolePermissionDenials.
+func (mr *MockUserManagerMockRecorder) DeleteRolePermissionDenials(ctx, domain, role any, permissions ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, domain, role}, permissions...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteRolePermissionDenials", reflect.TypeOf((*MockUserManager)(nil).DeleteRolePermissionDenials), varargs...)
+}
+
+// DeleteRolePermissionResourceDenials mocks base method.
+func (m *MockUserManager) DeleteRolePermissionResourceDenials(ctx context.Context, domain accesstypes.Domain, role accesstypes.Role, permission accesstypes.Permission, resources ...accesstypes.Resource) error {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, domain, role, permission}
+	for _, a := range resources {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "DeleteRolePermissionResourceDenials", varargs...)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteRolePermissionResourceDenials indicates an expected call of DeleteRolePermissionResourceDenials.
+func (mr *MockUserManagerMockRecorder) DeleteRolePermissionResourceDenials(ctx, domain, role, permission any, resources ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, domain, role, permission}, resources...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteRolePermissionResourceDenials", reflect.TypeOf((*MockUserManager)(nil).DeleteRolePermissionResourceDenials), varargs...)
+}
+
 // DeleteRolePermissionResources mocks base method.
 func (m *MockUserManager) DeleteRolePermissionResources(ctx context.Context, domain accesstypes.Domain, role accesstypes.Role, permission accesstypes.Permission, resources ...accesstypes.Resource) error {
 	m.ctrl.T.Helper()
@@ -380,11 +635,12 @@ func (mr *MockUserManagerMockRecorder) Domains(ctx any) *gomock.Call {
 }
 
 // RoleExists mocks base method.
-func (m *MockUserManager) RoleExists(ctx context.Context, domain accesstypes.Domain, role accesstypes.Role) bool {
+func (m *MockUserManager) RoleExists(ctx context.Context, domain accesstypes.Domain, role accesstypes.Role) (bool, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "RoleExists", ctx, domain, role)
 	ret0, _ := ret[0].(bool)
-	return ret0
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
 // RoleExists indicates an expected call of RoleExists.
@@ -393,6 +649,36 @@ func (mr *MockUserManagerMockRecorder) RoleExists(ctx, domain, role any) *gomock
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RoleExists", reflect.TypeOf((*MockUserManager)(nil).RoleExists), ctx, domain, role)
 }
 
+// RoleParents mocks base method.
+func (m *MockUserManager) RoleParents(ctx context.Context, domain accesstypes.Domain, role accesstypes.Role) ([]accesstypes.Role, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RoleParents", ctx, domain, role)
+	ret0, _ := ret[0].([]accesstypes.Role)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RoleParents indicates an expected call of RoleParents.
+func (mr *MockUserManagerMockRecorder) RoleParents(ctx, domain, role any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RoleParents", reflect.TypeOf((*MockUserManager)(nil).RoleParents), ctx, domain, role)
+}
+
+// RolePermissionDenials mocks base method.
+func (m *MockUserManager) RolePermissionDenials(ctx context.Context, domain accesstypes.Domain, role accesstypes.Role) (accesstypes.RolePermissionCollection, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RolePermissionDenials", ctx, domain, role)
+	ret0, _ := ret[0].(accesstypes.RolePermissionCollection)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RolePermissionDenials indicates an expected call of RolePermissionDenials.
+func (mr *MockUserManagerMockRecorder) RolePermissionDenials(ctx, domain, role any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RolePermissionDenials", reflect.TypeOf((*MockUserManager)(nil).RolePermissionDenials), ctx, domain, role)
+}
+
 // RolePermissions mocks base method.
 func (m *MockUserManager) RolePermissions(ctx context.Context, domain accesstypes.Domain, role accesstypes.Role) (accesstypes.RolePermissionCollection, error) {
 	m.ctrl.T.Helper()
@@ -0,0 +1,222 @@
+package access
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/casbin/casbin/v2/persist"
+)
+
+const (
+	defaultPolicyRefreshInterval = time.Minute
+	defaultLoadRetryAttempts     = 3
+	defaultLoadRetryBackoff      = 100 * time.Millisecond
+)
+
+// Option configures a Client created by New.
+type Option func(o *options)
+
+type options struct {
+	watcher               persist.Watcher
+	policyRefreshInterval *time.Duration
+	logger                *slog.Logger
+	model                 string
+	autoSave              bool
+	loadRetryAttempts     int
+	loadRetryBackoff      time.Duration
+	serveLastGoodPolicy   bool
+	policyFilter          any
+	globalDomainGrants    bool
+	domainCache           *domainCacheOptions
+	auditSink             AuditSink
+	auditOptions          []AuditOption
+	actor                 ActorFunc
+}
+
+type domainCacheOptions struct {
+	ttl         time.Duration
+	negativeTTL time.Duration
+	maxSize     int
+}
+
+func newOptions(opts ...Option) *options {
+	o := &options{
+		logger:   slog.New(slog.DiscardHandler),
+		autoSave: true,
+
+		loadRetryAttempts: defaultLoadRetryAttempts,
+		loadRetryBackoff:  defaultLoadRetryBackoff,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	if o.model == "" {
+		o.model = rbacModel()
+		if o.globalDomainGrants {
+			o.model = globalDomainGrantsModel()
+		}
+	}
+
+	if o.policyRefreshInterval == nil {
+		interval := defaultPolicyRefreshInterval
+		if o.watcher != nil {
+			interval = 0
+		}
+		o.policyRefreshInterval = &interval
+	}
+
+	return o
+}
+
+// WithWatcher sets a casbin watcher that tells other instances when the policy changes.
+// Policy is reloaded when the watcher reports a change instead of on a timer, unless WithPolicyRefreshInterval is also set.
+func WithWatcher(watcher persist.Watcher) Option {
+	return func(o *options) {
+		o.watcher = watcher
+	}
+}
+
+// WithPolicyRefreshInterval sets how long a loaded policy is used before it is reloaded from the adapter.
+// An interval of zero or less never reloads on a timer and relies on a watcher. Defaults to one minute without a watcher.
+func WithPolicyRefreshInterval(interval time.Duration) Option {
+	return func(o *options) {
+		interval = max(interval, 0)
+		o.policyRefreshInterval = &interval
+	}
+}
+
+// WithLogger sets the logger used for policy loading. Defaults to discarding log output, which a nil logger keeps.
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *options) {
+		if logger != nil {
+			o.logger = logger
+		}
+	}
+}
+
+// WithModel sets the casbin model configuration used in place of the default RBAC model.
+// The model must accept the policies written by UserManager. When combined with WithGlobalDomainGrants,
+// the model must also apply global domain role assignments in every domain.
+func WithModel(model string) Option {
+	return func(o *options) {
+		o.model = model
+	}
+}
+
+// WithAutoSave sets whether changes made through the UserManager are written to the adapter as they are made.
+// Defaults to true. Without auto save changes are only made to the loaded policy, and are lost when it is reloaded.
+func WithAutoSave(autoSave bool) Option {
+	return func(o *options) {
+		o.autoSave = autoSave
+	}
+}
+
+// WithLoadRetry sets how many times creating the adapter or loading the policy is attempted before an error is returned,
+// and the backoff before the first retry, which doubles after each failure. Defaults to 3 attempts starting at 100ms.
+func WithLoadRetry(attempts int, backoff time.Duration) Option {
+	return func(o *options) {
+		o.loadRetryAttempts = max(attempts, 1)
+		o.loadRetryBackoff = max(backoff, 0)
+	}
+}
+
+// WithServeLastGoodPolicy keeps enforcing the last successfully loaded policy when a reload fails instead of
+// returning an error. The failure is logged and the reload is tried again later. Errors are still returned until
+// the policy has been loaded once.
+func WithServeLastGoodPolicy() Option {
+	return func(o *options) {
+		o.serveLastGoodPolicy = true
+	}
+}
+
+// WithPolicyFilter loads only the rules matching filter, such as a SQLFilter for SQLAdapter, instead of the whole
+// policy. The adapter must support casbin filtered loading.
+func WithPolicyFilter(filter any) Option {
+	return func(o *options) {
+		o.policyFilter = filter
+	}
+}
+
+// WithGlobalDomainGrants makes a role assigned to a user in accesstypes.GlobalDomain apply in every domain, granting
+// the permissions the role has in each domain without assigning it there. UserRoles and UserPermissions report these
+// roles and permissions in every domain.
+func WithGlobalDomainGrants() Option {
+	return func(o *options) {
+		o.globalDomainGrants = true
+	}
+}
+
+// WithDomainCache caches the results of Domains in a DomainCache so permission checks don't look up the domain
+// each time. Results are cached for ttl, domains that don't exist for negativeTTL, and at most maxSize domains are
+// cached. Use Client.InvalidateDomains when domains are created or deleted.
+func WithDomainCache(ttl, negativeTTL time.Duration, maxSize int) Option {
+	return func(o *options) {
+		o.domainCache = &domainCacheOptions{ttl: ttl, negativeTTL: negativeTTL, maxSize: maxSize}
+	}
+}
+
+// WithAuditSink records every change made through Client.UserManager, and the handlers that use it, in sink.
+// See NewAuditedUserManager.
+func WithAuditSink(sink AuditSink, opts ...AuditOption) Option {
+	return func(o *options) {
+		o.auditSink = sink
+		o.auditOptions = opts
+	}
+}
+
+// WithActorExtractor sets how the Handlers find the principal making a request. The actor is set on the request
+// context with WithActor before UserManager is called, so it is recorded by WithAuditSink and available to ActorFrom.
+func WithActorExtractor(actor ActorFunc) Option {
+	return func(o *options) {
+		o.actor = actor
+	}
+}
+
+// OrphanedRoles decides what a migration does with roles that are missing from the config but still have users.
+// Roles with ReplacedBy set in the config always have their users moved to the replacement.
+type OrphanedRoles int
+
+const (
+	// OrphanedRolesFail stops the migration before any change is made, listing the roles that have users.
+	OrphanedRolesFail OrphanedRoles = iota
+	// OrphanedRolesSkip keeps the roles and reports them in the plan and result.
+	OrphanedRolesSkip
+)
+
+// MigrateOption configures PlanMigration, MigrateRoles and ApplyMigrationPlan.
+type MigrateOption func(o *migrateOptions)
+
+type migrateOptions struct {
+	logger        *slog.Logger
+	orphanedRoles OrphanedRoles
+}
+
+func newMigrateOptions(opts ...MigrateOption) *migrateOptions {
+	o := &migrateOptions{
+		logger: slog.Default(),
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return o
+}
+
+// WithMigrationLogger sets the logger that records each change a migration makes. Defaults to slog.Default(), which a
+// nil logger keeps.
+func WithMigrationLogger(logger *slog.Logger) MigrateOption {
+	return func(o *migrateOptions) {
+		if logger != nil {
+			o.logger = logger
+		}
+	}
+}
+
+// WithOrphanedRoles sets what a migration does with roles missing from the config that still have users.
+// Defaults to OrphanedRolesFail.
+func WithOrphanedRoles(orphanedRoles OrphanedRoles) MigrateOption {
+	return func(o *migrateOptions) {
+		o.orphanedRoles = orphanedRoles
+	}
+}
@@ -0,0 +1,220 @@
+package access
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/cccteam/ccc/accesstypes"
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_newOptions_policyRefreshInterval(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		opts []Option
+		want time.Duration
+	}{
+		{
+			name: "default",
+			want: time.Minute,
+		},
+		{
+			name: "watcher without interval never refreshes",
+			opts: []Option{WithWatcher(&fakeWatcher{})},
+			want: 0,
+		},
+		{
+			name: "watcher with interval",
+			opts: []Option{WithWatcher(&fakeWatcher{}), WithPolicyRefreshInterval(time.Hour)},
+			want: time.Hour,
+		},
+		{
+			name: "custom interval",
+			opts: []Option{WithPolicyRefreshInterval(5 * time.Second)},
+			want: 5 * time.Second,
+		},
+		{
+			name: "negative interval never refreshes",
+			opts: []Option{WithPolicyRefreshInterval(-time.Second)},
+			want: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := *newOptions(tt.opts...).policyRefreshInterval; got != tt.want {
+				t.Errorf("newOptions() policyRefreshInterval = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_newOptions_model(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		opts []Option
+		want string
+	}{
+		{
+			name: "default",
+			want: rbacModel(),
+		},
+		{
+			name: "global domain grants",
+			opts: []Option{WithGlobalDomainGrants()},
+			want: globalDomainGrantsModel(),
+		},
+		{
+			name: "custom model is kept with global domain grants",
+			opts: []Option{WithModel("custom"), WithGlobalDomainGrants()},
+			want: "custom",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := newOptions(tt.opts...).model; got != tt.want {
+				t.Errorf("newOptions() model = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNew_WithModel(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		model   string
+		wantErr bool
+	}{
+		{
+			name:  "default model",
+			model: rbacModel(),
+		},
+		{
+			name:    "invalid model",
+			model:   "[request_definition]",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := New(&MockDomains{}, fileAdapter("testdata/policy.csv"), WithModel(tt.model))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("New() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_newOptions_logger(t *testing.T) {
+	t.Parallel()
+
+	logger := slog.New(slog.DiscardHandler)
+
+	tests := []struct {
+		name string
+		opts []Option
+		want *slog.Logger
+	}{
+		{
+			name: "default",
+		},
+		{
+			name: "nil keeps the default",
+			opts: []Option{WithLogger(nil)},
+		},
+		{
+			name: "custom logger",
+			opts: []Option{WithLogger(logger)},
+			want: logger,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got := newOptions(tt.opts...).logger
+			if got == nil {
+				t.Fatal("newOptions() logger = nil")
+			}
+			if tt.want != nil && got != tt.want {
+				t.Errorf("newOptions() logger = %p, want %p", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNew_autoSave(t *testing.T) {
+	t.Parallel()
+
+	const policy = `g, noop, role:Editor, domain:tenant1
+`
+
+	tests := []struct {
+		name string
+		opts []Option
+		want string
+	}{
+		{
+			name: "default saves changes",
+			want: policy + "g, user:zach, role:Editor, domain:tenant1\n",
+		},
+		{
+			name: "auto save disabled",
+			opts: []Option{WithAutoSave(false)},
+			want: policy,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+			adapter, err := NewMemoryAdapter(policy)
+			if err != nil {
+				t.Fatalf("NewMemoryAdapter() error = %v", err)
+			}
+			client, err := New(tenant1Domains(t), adapter, tt.opts...)
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+
+			if err := client.UserManager().AddRoleUsers(ctx, "tenant1", "Editor", "zach"); err != nil {
+				t.Fatalf("UserManager.AddRoleUsers() error = %v", err)
+			}
+
+			// the change is made to the loaded policy either way
+			users, err := client.UserManager().RoleUsers(ctx, "tenant1", "Editor")
+			if err != nil {
+				t.Fatalf("UserManager.RoleUsers() error = %v", err)
+			}
+			if diff := cmp.Diff([]accesstypes.User{"zach"}, users); diff != "" {
+				t.Errorf("UserManager.RoleUsers() mismatch (-want +got):\n%s", diff)
+			}
+
+			if diff := cmp.Diff(tt.want, adapter.Policy()); diff != "" {
+				t.Errorf("MemoryAdapter.Policy() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_newMigrateOptions_logger(t *testing.T) {
+	t.Parallel()
+
+	if got := newMigrateOptions(WithMigrationLogger(nil)).logger; got != slog.Default() {
+		t.Errorf("newMigrateOptions() logger = %p, want slog.Default()", got)
+	}
+}
@@ -1,13 +1,28 @@
 package access
 
-import "github.com/cccteam/ccc/accesstypes"
+import (
+	"time"
+
+	"github.com/cccteam/ccc/accesstypes"
+)
 
 // PermissionsListFunc returns available permissions.
 type PermissionsListFunc func() []accesstypes.Permission
 
 // UserAccess contains user's name, roles by domain, and effective permissions by domain and resource.
+// RoleExpirations holds when time-bound role assignments expire, by domain and role.
 type UserAccess struct {
-	Name        string
-	Roles       accesstypes.RoleCollection
-	Permissions accesstypes.UserPermissionCollection
+	Name            string
+	Roles           accesstypes.RoleCollection
+	Permissions     accesstypes.UserPermissionCollection
+	RoleExpirations map[accesstypes.Domain]map[accesstypes.Role]time.Time
+}
+
+// RoleAssignment is a time-bound assignment of role to user in domain that expires at ExpiresAt. ExpiresAt is the
+// zero time for an expiry that can't be parsed.
+type RoleAssignment struct {
+	Domain    accesstypes.Domain
+	Role      accesstypes.Role
+	User      accesstypes.User
+	ExpiresAt time.Time
 }
@@ -2,12 +2,16 @@ package access
 
 import (
 	"context"
+	"log/slog"
 	"maps"
 	"slices"
 	"sort"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/casbin/casbin/v2"
+	"github.com/casbin/casbin/v2/persist"
 	"github.com/cccteam/ccc/accesstypes"
 	"github.com/cccteam/ccc/tracer"
 	"github.com/cccteam/httpio"
@@ -16,31 +20,79 @@ import (
 
 var _ UserManager = &userManager{}
 
+const (
+	effectAllow = "allow"
+	effectDeny  = "deny"
+)
+
+// rolePrefix is the prefix accesstypes.Role.Marshal adds to role names
+var rolePrefix = accesstypes.Role("").Marshal()
+
 // userManager implements UserManager with casbin enforcement and thread-safe operations.
 type userManager struct {
-	Enforcer func() casbin.IEnforcer // Exposed for testing
+	Enforcer func() (casbin.IEnforcer, error) // Exposed for testing
 	domains  Domains
 	adapter  Adapter
-
-	policyMu     sync.RWMutex
-	policyLoaded bool
+	watcher  persist.Watcher
+	logger   *slog.Logger
+
+	policyRefreshInterval time.Duration
+	loadRetryAttempts     int
+	loadRetryBackoff      time.Duration
+	serveLastGoodPolicy   bool
+	policyFilter          any
+	globalDomainGrants    bool
+	policyMu              sync.RWMutex
+	policyLoaded          bool
+	lastGoodPolicy        bool
+	// refreshTimer marks the policy as stale, it is guarded by policyMu and reset on each load
+	refreshTimer *time.Timer
 
 	enforcerMu          sync.RWMutex
 	enforcer            casbin.IEnforcer
 	enforcerInitialized bool
+
+	expiry      *expiringAdapter
+	expiryMu    sync.Mutex
+	expiryTimer *time.Timer
+	expiryAt    time.Time
 }
 
-// newUserManager creates userManager. Errors if casbin enforcer creation fails.
-func newUserManager(domains Domains, adapter Adapter) (*userManager, error) {
-	enforcer, err := createEnforcer(rbacModel())
+// newUserManager creates userManager. Errors if casbin enforcer creation or watcher setup fails.
+func newUserManager(domains Domains, adapter Adapter, opts *options) (*userManager, error) {
+	enforcer, err := createEnforcer(opts.model, opts.autoSave)
 	if err != nil {
 		return nil, err
 	}
 
+	if opts.domainCache != nil {
+		domains = NewDomainCache(domains, opts.domainCache.ttl, opts.domainCache.negativeTTL, opts.domainCache.maxSize)
+	}
+
 	u := &userManager{
 		adapter:  adapter,
 		enforcer: enforcer,
 		domains:  domains,
+		watcher:  opts.watcher,
+		logger:   opts.logger,
+
+		policyRefreshInterval: *opts.policyRefreshInterval,
+		loadRetryAttempts:     opts.loadRetryAttempts,
+		loadRetryBackoff:      opts.loadRetryBackoff,
+		serveLastGoodPolicy:   opts.serveLastGoodPolicy,
+		policyFilter:          opts.policyFilter,
+		globalDomainGrants:    opts.globalDomainGrants,
+	}
+
+	if u.watcher != nil {
+		if err := u.enforcer.SetWatcher(u.watcher); err != nil {
+			return nil, errors.Wrap(err, "casbin.SyncedEnforcer.SetWatcher()")
+		}
+
+		// SetWatcher installs a callback that reloads immediately, replace it so the reload happens on next use
+		if err := u.watcher.SetUpdateCallback(u.invalidatePolicy); err != nil {
+			return nil, errors.Wrap(err, "persist.Watcher.SetUpdateCallback()")
+		}
 	}
 
 	u.Enforcer = u.refreshEnforcer
@@ -54,17 +106,28 @@ func (u *userManager) AddRoleUsers(ctx context.Context, domain accesstypes.Domai
 	ctx, span := tracer.Start(ctx)
 	defer span.End()
 
-	roleFound := u.RoleExists(ctx, domain, role)
-	if !roleFound {
+	if roleFound, err := u.RoleExists(ctx, domain, role); err != nil {
+		return err
+	} else if !roleFound {
 		return httpio.NewNotFoundMessagef("role %q is not a valid role. Please check that the role exists.", string(role))
 	}
 
+	enforcer, err := u.Enforcer()
+	if err != nil {
+		return errors.Wrap(err, "userManager.Enforcer()")
+	}
+
 	for _, user := range users {
 		if user == "" {
 			return httpio.NewBadRequestMessage("user cannot be empty string")
 		}
 
-		if _, err := u.Enforcer().AddRoleForUser(user.Marshal(), role.Marshal(), domain.Marshal()); err != nil {
+		// the role manager keeps one link per assignment, so time-bound assignments are removed before the link is added
+		if err := removeTimedAssignments(enforcer, domain, role, user); err != nil {
+			return err
+		}
+
+		if _, err := enforcer.AddRoleForUser(user.Marshal(), role.Marshal(), domain.Marshal()); err != nil {
 			return errors.Wrapf(err, "casbin.SyncedEnforcer.AddRoleForUser(): role %q to %q", role.Marshal(), user)
 		}
 	}
@@ -79,7 +142,9 @@ func (u *userManager) AddUserRoles(ctx context.Context, domain accesstypes.Domai
 	defer span.End()
 
 	for _, role := range roles {
-		if roleFound := u.RoleExists(ctx, domain, role); !roleFound {
+		if roleFound, err := u.RoleExists(ctx, domain, role); err != nil {
+			return err
+		} else if !roleFound {
 			return httpio.NewNotFoundMessagef("role %q is not a valid role. Please check that the role exists.", role)
 		}
 	}
@@ -88,8 +153,18 @@ func (u *userManager) AddUserRoles(ctx context.Context, domain accesstypes.Domai
 		return httpio.NewBadRequestMessage("user cannot be empty string")
 	}
 
+	enforcer, err := u.Enforcer()
+	if err != nil {
+		return errors.Wrap(err, "userManager.Enforcer()")
+	}
+
 	for _, role := range roles {
-		if _, err := u.Enforcer().AddRoleForUser(user.Marshal(), role.Marshal(), domain.Marshal()); err != nil {
+		// the role manager keeps one link per assignment, so time-bound assignments are removed before the link is added
+		if err := removeTimedAssignments(enforcer, domain, role, user); err != nil {
+			return err
+		}
+
+		if _, err := enforcer.AddRoleForUser(user.Marshal(), role.Marshal(), domain.Marshal()); err != nil {
 			return errors.Wrapf(err, "casbin.SyncedEnforcer.AddRoleForUser(): role %q to %q", role, user)
 		}
 	}
@@ -103,31 +178,46 @@ func (u *userManager) DeleteRoleUsers(ctx context.Context, domain accesstypes.Do
 	ctx, span := tracer.Start(ctx)
 	defer span.End()
 
-	if roleFound := u.RoleExists(ctx, domain, role); !roleFound {
+	if roleFound, err := u.RoleExists(ctx, domain, role); err != nil {
+		return err
+	} else if !roleFound {
 		return httpio.NewNotFoundMessagef("role %q is not a valid role. Please check that the role exists.", string(role))
 	}
 
+	enforcer, err := u.Enforcer()
+	if err != nil {
+		return errors.Wrap(err, "userManager.Enforcer()")
+	}
+
 	for _, user := range users {
-		if _, err := u.Enforcer().DeleteRoleForUser(user.Marshal(), role.Marshal(), domain.Marshal()); err != nil {
-			return errors.Wrapf(err, "casbin.SyncedEnforcer.AddRoleForUser(): role %q to %q", role.Marshal(), user)
+		// filtered removal also removes time-bound assignments, which have an expiry after the domain
+		if _, err := enforcer.RemoveFilteredGroupingPolicy(0, user.Marshal(), role.Marshal(), domain.Marshal()); err != nil {
+			return errors.Wrapf(err, "casbin.SyncedEnforcer.RemoveFilteredGroupingPolicy(): role %q from %q", role.Marshal(), user)
 		}
 	}
 
 	return nil
 }
 
-// DeleteAllRolePermissions removes all permissions (both global and resource-specific) from a role within a domain.
+// DeleteAllRolePermissions removes all permissions and permission denials (both global and resource-specific) from a
+// role within a domain.
 func (u *userManager) DeleteAllRolePermissions(ctx context.Context, domain accesstypes.Domain, role accesstypes.Role) error {
 	ctx, span := tracer.Start(ctx)
 	defer span.End()
 
-	perms, err := u.RolePermissions(ctx, domain, role)
+	if exists, err := u.RoleExists(ctx, domain, role); err != nil {
+		return err
+	} else if !exists {
+		return httpio.NewNotFoundMessagef("Permissions cannot be removed from a role that doesn't exist")
+	}
+
+	enforcer, err := u.Enforcer()
 	if err != nil {
-		return errors.Wrap(err, "client.RolePermissions()")
+		return errors.Wrap(err, "userManager.Enforcer()")
 	}
 
-	if err := u.DeleteRolePermissions(ctx, domain, role, slices.Collect(maps.Keys(perms))...); err != nil {
-		return errors.Wrap(err, "client.DeleteRolePermissions()")
+	if _, err := enforcer.RemoveFilteredPolicy(0, role.Marshal(), domain.Marshal()); err != nil {
+		return errors.Wrapf(err, "enforcer.RemoveFilteredPolicy() role=%q, domain=%q", role, domain)
 	}
 
 	return nil
@@ -139,9 +229,14 @@ func (u *userManager) DeleteUserRoles(ctx context.Context, domain accesstypes.Do
 	_, span := tracer.Start(ctx)
 	defer span.End()
 
+	enforcer, err := u.Enforcer()
+	if err != nil {
+		return errors.Wrap(err, "userManager.Enforcer()")
+	}
+
 	for _, role := range roles {
-		if _, err := u.Enforcer().DeleteRoleForUser(user.Marshal(), role.Marshal(), domain.Marshal()); err != nil {
-			return errors.Wrapf(err, "casbin.SyncedEnforcer.DeleteRoleForUser(): role %q to %q", role.Marshal(), user)
+		if _, err := enforcer.RemoveFilteredGroupingPolicy(0, user.Marshal(), role.Marshal(), domain.Marshal()); err != nil {
+			return errors.Wrapf(err, "casbin.SyncedEnforcer.RemoveFilteredGroupingPolicy(): role %q from %q", role.Marshal(), user)
 		}
 	}
 
@@ -179,10 +274,16 @@ func (u *userManager) user(ctx context.Context, user accesstypes.User, domains [
 		return nil, err
 	}
 
+	expirations, err := u.userRoleExpirations(user, domains)
+	if err != nil {
+		return nil, err
+	}
+
 	return &UserAccess{
-		Name:        string(user),
-		Roles:       roles,
-		Permissions: permissions,
+		Name:            string(user),
+		Roles:           roles,
+		Permissions:     permissions,
+		RoleExpirations: expirations,
 	}, nil
 }
 
@@ -214,12 +315,18 @@ func (u *userManager) users(ctx context.Context, domains []accesstypes.Domain) (
 
 	var users []*UserAccess
 	userMap := make(map[string]bool)
-	roles, err := u.Enforcer().GetAllRoles()
+
+	enforcer, err := u.Enforcer()
+	if err != nil {
+		return nil, errors.Wrap(err, "userManager.Enforcer()")
+	}
+
+	roles, err := enforcer.GetAllRoles()
 	if err != nil {
 		return nil, errors.Wrap(err, "enforcer.GetAllRoles()")
 	}
 
-	subjects, err := u.Enforcer().GetAllSubjects()
+	subjects, err := enforcer.GetAllSubjects()
 	if err != nil {
 		return nil, errors.Wrap(err, "enforcer.GetAllSubjects()")
 	}
@@ -227,6 +334,9 @@ SUB:
 	// loop through the subjects (containing both roles and usernames)
 	// and if it is a a role, skip it, otherwise add user to the map
 	for _, user := range subjects {
+		if isRoleSubject(user) {
+			continue
+		}
 		for _, role := range roles {
 			if role == user || user == accesstypes.NoopUser {
 				continue SUB
@@ -242,14 +352,14 @@ SUB:
 		userMap[user] = true
 	}
 	// now get the grouping policy and look for users in there
-	groupingPolicy, err := u.Enforcer().GetGroupingPolicy()
+	groupingPolicy, err := enforcer.GetGroupingPolicy()
 	if err != nil {
 		return nil, errors.Wrap(err, "enforcer.GetGroupingPolicy()")
 	}
 GP:
 	for _, gp := range groupingPolicy {
 		user := gp[0]
-		if userMap[user] || user == accesstypes.NoopUser {
+		if userMap[user] || user == accesstypes.NoopUser || isRoleSubject(user) {
 			continue
 		}
 
@@ -276,7 +386,8 @@ GP:
 }
 
 // UserRoles returns the roles assigned to a user across specified domains.
-// If no domains are specified, returns roles across all domains.
+// If no domains are specified, returns roles across all domains. With global domain grants enabled, roles assigned
+// in the global domain are included in every domain.
 func (u *userManager) UserRoles(ctx context.Context, user accesstypes.User, domains ...accesstypes.Domain) (accesstypes.RoleCollection, error) {
 	ctx, span := tracer.Start(ctx)
 	defer span.End()
@@ -301,13 +412,32 @@ func (u *userManager) userRoles(ctx context.Context, user accesstypes.User, doma
 	_, span := tracer.Start(ctx)
 	defer span.End()
 
+	enforcer, err := u.Enforcer()
+	if err != nil {
+		return nil, errors.Wrap(err, "userManager.Enforcer()")
+	}
+
+	var globalRoles []string
+	if u.globalDomainGrants {
+		globalRoles, err = enforcer.GetRolesForUser(user.Marshal(), accesstypes.GlobalDomain.Marshal())
+		if err != nil {
+			return nil, errors.Wrapf(err, "casbin.SyncedEnforcer.GetRolesForUser(): user: %q", user)
+		}
+	}
+
 	userRoles := make(accesstypes.RoleCollection)
 	for _, domain := range domains {
-		strRoles, err := u.Enforcer().GetRolesForUser(user.Marshal(), domain.Marshal())
+		strRoles, err := enforcer.GetRolesForUser(user.Marshal(), domain.Marshal())
 		if err != nil {
 			return nil, errors.Wrapf(err, "casbin.SyncedEnforcer.GetRolesForUser(): user: %q", user)
 		}
 
+		for _, role := range globalRoles {
+			if !slices.Contains(strRoles, role) {
+				strRoles = append(strRoles, role)
+			}
+		}
+
 		roles := make([]accesstypes.Role, 0, len(strRoles))
 		for _, role := range strRoles {
 			roles = append(roles, accesstypes.UnmarshalRole(role))
@@ -319,7 +449,8 @@ func (u *userManager) userRoles(ctx context.Context, user accesstypes.User, doma
 }
 
 // UserPermissions returns the effective permissions for a user across specified domains.
-// If no domains are specified, returns permissions across all domains.
+// If no domains are specified, returns permissions across all domains. With global domain grants enabled, the
+// permissions that the user's global domain roles have in each domain are included.
 func (u *userManager) UserPermissions(ctx context.Context, user accesstypes.User, domains ...accesstypes.Domain) (accesstypes.UserPermissionCollection, error) {
 	ctx, span := tracer.Start(ctx)
 	defer span.End()
@@ -344,16 +475,51 @@ func (u *userManager) userPermissions(ctx context.Context, user accesstypes.User
 	_, span := tracer.Start(ctx)
 	defer span.End()
 
+	enforcer, err := u.Enforcer()
+	if err != nil {
+		return nil, errors.Wrap(err, "userManager.Enforcer()")
+	}
+
+	var globalRoles []string
+	if u.globalDomainGrants {
+		globalRoles, err = enforcer.GetImplicitRolesForUser(user.Marshal(), accesstypes.GlobalDomain.Marshal())
+		if err != nil {
+			return nil, errors.Wrap(err, "enforcer.GetImplicitRolesForUser()")
+		}
+	}
+
 	userPermissions := make(accesstypes.UserPermissionCollection)
 	for _, domain := range domains {
 		userPermissions[domain] = make(map[accesstypes.Resource][]accesstypes.Permission)
 
-		strPerms, err := u.Enforcer().GetImplicitPermissionsForUser(user.Marshal(), domain.Marshal())
+		strPerms, err := enforcer.GetImplicitPermissionsForUser(user.Marshal(), domain.Marshal())
 		if err != nil {
 			return nil, errors.Wrap(err, "enforcer.GetImplicitPermissionsForUser()")
 		}
 
+		// global domain roles grant the permissions the role has in this domain
+		if domain != accesstypes.GlobalDomain {
+			for _, role := range globalRoles {
+				policies, err := enforcer.GetFilteredPolicy(0, role, domain.Marshal())
+				if err != nil {
+					return nil, errors.Wrap(err, "enforcer.GetFilteredPolicy()")
+				}
+				strPerms = append(strPerms, policies...)
+			}
+		}
+
+		// denials take precedence over any role that allows the same permission
+		denied := make(map[[2]string]bool)
+		for _, perm := range strPerms {
+			if perm[4] == effectDeny {
+				denied[[2]string{perm[2], perm[3]}] = true
+			}
+		}
+
 		for _, perm := range strPerms {
+			if perm[4] != effectAllow || denied[[2]string{perm[2], perm[3]}] {
+				continue
+			}
 			if slices.Contains(userPermissions[domain][accesstypes.UnmarshalResource(perm[2])], accesstypes.UnmarshalPermission(perm[3])) {
 				continue
 			}
@@ -374,7 +540,9 @@ func (u *userManager) AddRole(ctx context.Context, domain accesstypes.Domain, ro
 		return httpio.NewNotFoundMessagef("domain %q does not exist", string(domain))
 	}
 
-	if roleDoesExist := u.RoleExists(ctx, domain, role); roleDoesExist {
+	if roleDoesExist, err := u.RoleExists(ctx, domain, role); err != nil {
+		return err
+	} else if roleDoesExist {
 		return httpio.NewConflictMessagef("role %q already exists", string(role))
 	}
 
@@ -382,7 +550,12 @@ func (u *userManager) AddRole(ctx context.Context, domain accesstypes.Domain, ro
 		return httpio.NewBadRequestMessage("role cannot be empty string")
 	}
 
-	if _, err := u.Enforcer().AddGroupingPolicy(accesstypes.NoopUser, role.Marshal(), domain.Marshal()); err != nil {
+	enforcer, err := u.Enforcer()
+	if err != nil {
+		return errors.Wrap(err, "userManager.Enforcer()")
+	}
+
+	if _, err := enforcer.AddGroupingPolicy(accesstypes.NoopUser, role.Marshal(), domain.Marshal()); err != nil {
 		return errors.Wrap(err, "enforcer.AddGroupingPolicy()")
 	}
 
@@ -399,8 +572,13 @@ func (u *userManager) Roles(ctx context.Context, domain accesstypes.Domain) ([]a
 		return nil, httpio.NewNotFoundMessagef("domain %q does not exist", string(domain))
 	}
 
+	enforcer, err := u.Enforcer()
+	if err != nil {
+		return nil, errors.Wrap(err, "userManager.Enforcer()")
+	}
+
 	// filter by domain
-	grouping, err := u.Enforcer().GetFilteredGroupingPolicy(2, domain.Marshal())
+	grouping, err := enforcer.GetFilteredGroupingPolicy(2, domain.Marshal())
 	if err != nil {
 		return nil, errors.Wrap(err, "enforcer.GetFilteredGroupingPolicy()")
 	}
@@ -434,7 +612,67 @@ func (u *userManager) DeleteRole(ctx context.Context, domain accesstypes.Domain,
 		return false, httpio.NewBadRequestMessagef("Users assigned to the role. You cannot delete a role that has users assigned")
 	}
 
-	deleted, err := u.Enforcer().DeleteRole(role.Marshal())
+	enforcer, err := u.Enforcer()
+	if err != nil {
+		return false, errors.Wrap(err, "userManager.Enforcer()")
+	}
+
+	// only remove the role's grouping and permission policies in this domain, the role may also exist in other domains
+	assigned, err := enforcer.RemoveFilteredGroupingPolicy(1, role.Marshal(), domain.Marshal())
+	if err != nil {
+		return false, errors.Wrapf(err, "enforcer.RemoveFilteredGroupingPolicy() role=%q, domain=%q", role, domain)
+	}
+
+	inherited, err := enforcer.RemoveFilteredGroupingPolicy(0, role.Marshal(), "", domain.Marshal())
+	if err != nil {
+		return false, errors.Wrapf(err, "enforcer.RemoveFilteredGroupingPolicy() role=%q, domain=%q", role, domain)
+	}
+
+	permissions, err := enforcer.RemoveFilteredPolicy(0, role.Marshal(), domain.Marshal())
+	if err != nil {
+		return false, errors.Wrapf(err, "enforcer.RemoveFilteredPolicy() role=%q, domain=%q", role, domain)
+	}
+
+	return assigned || inherited || permissions, nil
+}
+
+func (u *userManager) DeleteRoleAllDomains(ctx context.Context, role accesstypes.Role) (bool, error) {
+	ctx, span := tracer.Start(ctx)
+	defer span.End()
+
+	enforcer, err := u.Enforcer()
+	if err != nil {
+		return false, errors.Wrap(err, "userManager.Enforcer()")
+	}
+
+	grouping, err := enforcer.GetFilteredGroupingPolicy(1, role.Marshal())
+	if err != nil {
+		return false, errors.Wrap(err, "enforcer.GetFilteredGroupingPolicy()")
+	}
+
+	policies, err := enforcer.GetFilteredPolicy(0, role.Marshal())
+	if err != nil {
+		return false, errors.Wrap(err, "enforcer.GetFilteredPolicy()")
+	}
+
+	domains := make(map[accesstypes.Domain]bool)
+	for _, g := range grouping {
+		domains[accesstypes.UnmarshalDomain(g[2])] = true
+	}
+	for _, p := range policies {
+		domains[accesstypes.UnmarshalDomain(p[1])] = true
+	}
+
+	// check every domain before deleting anything so a role with users is left untouched everywhere
+	for _, domain := range slices.Sorted(maps.Keys(domains)) {
+		if hasUsers, err := u.hasUsersAssigned(ctx, domain, role); err != nil {
+			return false, errors.Wrap(err, "client.hasUsersAssigned()")
+		} else if hasUsers {
+			return false, httpio.NewBadRequestMessagef("Users assigned to the role in domain %s. You cannot delete a role that has users assigned", domain)
+		}
+	}
+
+	deleted, err := enforcer.DeleteRole(role.Marshal())
 	if err != nil {
 		return false, errors.Wrap(err, "enforcer.DeleteRole()")
 	}
@@ -442,20 +680,194 @@ func (u *userManager) DeleteRole(ctx context.Context, domain accesstypes.Domain,
 	return deleted, nil
 }
 
+// AddRoleParents makes role inherit the permissions and denials of parents in domain.
+// Returns an error if role or any parent doesn't exist, or if a parent already inherits role.
+func (u *userManager) AddRoleParents(ctx context.Context, domain accesstypes.Domain, role accesstypes.Role, parents ...accesstypes.Role) error {
+	ctx, span := tracer.Start(ctx)
+	defer span.End()
+
+	for _, r := range append([]accesstypes.Role{role}, parents...) {
+		if exists, err := u.RoleExists(ctx, domain, r); err != nil {
+			return err
+		} else if !exists {
+			return httpio.NewNotFoundMessagef("role %q is not a valid role. Please check that the role exists.", string(r))
+		}
+	}
+
+	enforcer, err := u.Enforcer()
+	if err != nil {
+		return errors.Wrap(err, "userManager.Enforcer()")
+	}
+
+	for _, parent := range parents {
+		if cycle, err := inherits(enforcer, domain, parent, role); err != nil {
+			return err
+		} else if cycle {
+			return httpio.NewBadRequestMessagef("role %q cannot inherit %q because %q already inherits %q", string(role), string(parent), string(parent), string(role))
+		}
+
+		if _, err := enforcer.AddGroupingPolicy(role.Marshal(), parent.Marshal(), domain.Marshal()); err != nil {
+			return errors.Wrapf(err, "enforcer.AddGroupingPolicy(): role %q to %q", parent, role)
+		}
+	}
+
+	return nil
+}
+
+// DeleteRoleParents stops role inheriting parents in domain. Returns an error if role doesn't exist.
+func (u *userManager) DeleteRoleParents(ctx context.Context, domain accesstypes.Domain, role accesstypes.Role, parents ...accesstypes.Role) error {
+	ctx, span := tracer.Start(ctx)
+	defer span.End()
+
+	if exists, err := u.RoleExists(ctx, domain, role); err != nil {
+		return err
+	} else if !exists {
+		return httpio.NewNotFoundMessagef("role %q is not a valid role. Please check that the role exists.", string(role))
+	}
+
+	enforcer, err := u.Enforcer()
+	if err != nil {
+		return errors.Wrap(err, "userManager.Enforcer()")
+	}
+
+	for _, parent := range parents {
+		if _, err := enforcer.RemoveGroupingPolicy(role.Marshal(), parent.Marshal(), domain.Marshal()); err != nil {
+			return errors.Wrapf(err, "enforcer.RemoveGroupingPolicy(): role %q from %q", parent, role)
+		}
+	}
+
+	return nil
+}
+
+// RoleParents returns the roles that role directly inherits in domain. Returns an error if role doesn't exist.
+func (u *userManager) RoleParents(ctx context.Context, domain accesstypes.Domain, role accesstypes.Role) ([]accesstypes.Role, error) {
+	ctx, span := tracer.Start(ctx)
+	defer span.End()
+
+	if exists, err := u.RoleExists(ctx, domain, role); err != nil {
+		return nil, err
+	} else if !exists {
+		return nil, httpio.NewNotFoundMessagef("role %s doesn't exist", role)
+	}
+
+	enforcer, err := u.Enforcer()
+	if err != nil {
+		return nil, errors.Wrap(err, "userManager.Enforcer()")
+	}
+
+	grouping, err := enforcer.GetFilteredGroupingPolicy(0, role.Marshal(), "", domain.Marshal())
+	if err != nil {
+		return nil, errors.Wrap(err, "enforcer.GetFilteredGroupingPolicy()")
+	}
+
+	parents := make([]accesstypes.Role, 0, len(grouping))
+	for _, g := range grouping {
+		parents = append(parents, accesstypes.UnmarshalRole(g[1]))
+	}
+	slices.Sort(parents)
+
+	return parents, nil
+}
+
+// inherits reports whether role is ancestor or inherits it, directly or through other roles, in domain.
+func inherits(enforcer casbin.IEnforcer, domain accesstypes.Domain, role, ancestor accesstypes.Role) (bool, error) {
+	if role == ancestor {
+		return true, nil
+	}
+
+	roles, err := enforcer.GetImplicitRolesForUser(role.Marshal(), domain.Marshal())
+	if err != nil {
+		return false, errors.Wrap(err, "enforcer.GetImplicitRolesForUser()")
+	}
+
+	return slices.Contains(roles, ancestor.Marshal()), nil
+}
+
+// isRoleSubject reports whether the subject of a grouping policy is a role inheriting another role rather than a user.
+func isRoleSubject(subject string) bool {
+	return strings.HasPrefix(subject, rolePrefix)
+}
+
 func (u *userManager) AddRolePermissions(ctx context.Context, domain accesstypes.Domain, role accesstypes.Role, permissions ...accesstypes.Permission) error {
 	ctx, span := tracer.Start(ctx)
 	defer span.End()
 
-	if !u.RoleExists(ctx, domain, role) {
+	return u.addRolePermissions(ctx, domain, role, effectAllow, permissions...)
+}
+
+func (u *userManager) AddRolePermissionResources(ctx context.Context, domain accesstypes.Domain, role accesstypes.Role, permission accesstypes.Permission, resources ...accesstypes.Resource) error {
+	ctx, span := tracer.Start(ctx)
+	defer span.End()
+
+	return u.addRolePermissionResources(ctx, domain, role, effectAllow, permission, resources...)
+}
+
+func (u *userManager) DeleteRolePermissions(ctx context.Context, domain accesstypes.Domain, role accesstypes.Role, permissions ...accesstypes.Permission) error {
+	ctx, span := tracer.Start(ctx)
+	defer span.End()
+
+	return u.deleteRolePermissions(ctx, domain, role, effectAllow, permissions...)
+}
+
+func (u *userManager) DeleteRolePermissionResources(
+	ctx context.Context, domain accesstypes.Domain, role accesstypes.Role, permission accesstypes.Permission, resources ...accesstypes.Resource,
+) error {
+	ctx, span := tracer.Start(ctx)
+	defer span.End()
+
+	return u.deleteRolePermissionResources(ctx, domain, role, effectAllow, permission, resources...)
+}
+
+func (u *userManager) AddRolePermissionDenials(ctx context.Context, domain accesstypes.Domain, role accesstypes.Role, permissions ...accesstypes.Permission) error {
+	ctx, span := tracer.Start(ctx)
+	defer span.End()
+
+	return u.addRolePermissions(ctx, domain, role, effectDeny, permissions...)
+}
+
+func (u *userManager) AddRolePermissionResourceDenials(
+	ctx context.Context, domain accesstypes.Domain, role accesstypes.Role, permission accesstypes.Permission, resources ...accesstypes.Resource,
+) error {
+	ctx, span := tracer.Start(ctx)
+	defer span.End()
+
+	return u.addRolePermissionResources(ctx, domain, role, effectDeny, permission, resources...)
+}
+
+func (u *userManager) DeleteRolePermissionDenials(ctx context.Context, domain accesstypes.Domain, role accesstypes.Role, permissions ...accesstypes.Permission) error {
+	ctx, span := tracer.Start(ctx)
+	defer span.End()
+
+	return u.deleteRolePermissions(ctx, domain, role, effectDeny, permissions...)
+}
+
+func (u *userManager) DeleteRolePermissionResourceDenials(
+	ctx context.Context, domain accesstypes.Domain, role accesstypes.Role, permission accesstypes.Permission, resources ...accesstypes.Resource,
+) error {
+	ctx, span := tracer.Start(ctx)
+	defer span.End()
+
+	return u.deleteRolePermissionResources(ctx, domain, role, effectDeny, permission, resources...)
+}
+
+func (u *userManager) addRolePermissions(ctx context.Context, domain accesstypes.Domain, role accesstypes.Role, effect string, permissions ...accesstypes.Permission) error {
+	if exists, err := u.RoleExists(ctx, domain, role); err != nil {
+		return err
+	} else if !exists {
 		return httpio.NewNotFoundMessagef("Permissions cannot be added to a role that doesn't exist")
 	}
 
+	enforcer, err := u.Enforcer()
+	if err != nil {
+		return errors.Wrap(err, "userManager.Enforcer()")
+	}
+
 	for _, permission := range permissions {
 		if permission == "" {
 			return httpio.NewBadRequestMessage("permission cannot be empty string")
 		}
 
-		if _, err := u.Enforcer().AddPolicy(role.Marshal(), domain.Marshal(), accesstypes.GlobalResource.Marshal(), permission.Marshal(), "allow"); err != nil {
+		if _, err := enforcer.AddPolicy(role.Marshal(), domain.Marshal(), accesstypes.GlobalResource.Marshal(), permission.Marshal(), effect); err != nil {
 			return errors.Wrap(err, "enforcer.AddPolicy()")
 		}
 	}
@@ -463,20 +875,26 @@ func (u *userManager) AddRolePermissions(ctx context.Context, domain accesstypes
 	return nil
 }
 
-func (u *userManager) AddRolePermissionResources(ctx context.Context, domain accesstypes.Domain, role accesstypes.Role, permission accesstypes.Permission, resources ...accesstypes.Resource) error {
-	ctx, span := tracer.Start(ctx)
-	defer span.End()
-
-	if !u.RoleExists(ctx, domain, role) {
+func (u *userManager) addRolePermissionResources(
+	ctx context.Context, domain accesstypes.Domain, role accesstypes.Role, effect string, permission accesstypes.Permission, resources ...accesstypes.Resource,
+) error {
+	if exists, err := u.RoleExists(ctx, domain, role); err != nil {
+		return err
+	} else if !exists {
 		return httpio.NewNotFoundMessagef("Permissions cannot be added to a role that doesn't exist")
 	}
 
+	enforcer, err := u.Enforcer()
+	if err != nil {
+		return errors.Wrap(err, "userManager.Enforcer()")
+	}
+
 	for _, resource := range resources {
 		if resource == "" {
 			return httpio.NewBadRequestMessage("resource cannot be empty string")
 		}
 
-		if _, err := u.Enforcer().AddPolicy(role.Marshal(), domain.Marshal(), resource.Marshal(), permission.Marshal(), "allow"); err != nil {
+		if _, err := enforcer.AddPolicy(role.Marshal(), domain.Marshal(), resource.Marshal(), permission.Marshal(), effect); err != nil {
 			return errors.Wrap(err, "enforcer.AddPolicy()")
 		}
 	}
@@ -484,16 +902,20 @@ func (u *userManager) AddRolePermissionResources(ctx context.Context, domain acc
 	return nil
 }
 
-func (u *userManager) DeleteRolePermissions(ctx context.Context, domain accesstypes.Domain, role accesstypes.Role, permissions ...accesstypes.Permission) error {
-	ctx, span := tracer.Start(ctx)
-	defer span.End()
-
-	if !u.RoleExists(ctx, domain, role) {
+func (u *userManager) deleteRolePermissions(ctx context.Context, domain accesstypes.Domain, role accesstypes.Role, effect string, permissions ...accesstypes.Permission) error {
+	if exists, err := u.RoleExists(ctx, domain, role); err != nil {
+		return err
+	} else if !exists {
 		return httpio.NewNotFoundMessagef("Permissions cannot be removed from a role that doesn't exist")
 	}
 
+	enforcer, err := u.Enforcer()
+	if err != nil {
+		return errors.Wrap(err, "userManager.Enforcer()")
+	}
+
 	for _, permission := range permissions {
-		if _, err := u.Enforcer().RemoveFilteredPolicy(0, role.Marshal(), domain.Marshal(), accesstypes.GlobalResource.Marshal(), permission.Marshal()); err != nil {
+		if _, err := enforcer.RemoveFilteredPolicy(0, role.Marshal(), domain.Marshal(), accesstypes.GlobalResource.Marshal(), permission.Marshal(), effect); err != nil {
 			return errors.Wrapf(err, "enforcer.RemoveFilteredPolicy() role=%q, domain=%q", role, domain)
 		}
 	}
@@ -501,18 +923,22 @@ func (u *userManager) DeleteRolePermissions(ctx context.Context, domain accessty
 	return nil
 }
 
-func (u *userManager) DeleteRolePermissionResources(
-	ctx context.Context, domain accesstypes.Domain, role accesstypes.Role, permission accesstypes.Permission, resources ...accesstypes.Resource,
+func (u *userManager) deleteRolePermissionResources(
+	ctx context.Context, domain accesstypes.Domain, role accesstypes.Role, effect string, permission accesstypes.Permission, resources ...accesstypes.Resource,
 ) error {
-	ctx, span := tracer.Start(ctx)
-	defer span.End()
-
-	if !u.RoleExists(ctx, domain, role) {
+	if exists, err := u.RoleExists(ctx, domain, role); err != nil {
+		return err
+	} else if !exists {
 		return httpio.NewNotFoundMessagef("Permissions cannot be removed from a role that doesn't exist")
 	}
 
+	enforcer, err := u.Enforcer()
+	if err != nil {
+		return errors.Wrap(err, "userManager.Enforcer()")
+	}
+
 	for _, resource := range resources {
-		if _, err := u.Enforcer().RemoveFilteredPolicy(0, role.Marshal(), domain.Marshal(), resource.Marshal(), permission.Marshal()); err != nil {
+		if _, err := enforcer.RemoveFilteredPolicy(0, role.Marshal(), domain.Marshal(), resource.Marshal(), permission.Marshal(), effect); err != nil {
 			return errors.Wrapf(err, "enforcer.RemoveFilteredPolicy() role=%q, domain=%q", role, domain)
 		}
 	}
@@ -524,14 +950,19 @@ func (u *userManager) RoleUsers(ctx context.Context, domain accesstypes.Domain,
 	_, span := tracer.Start(ctx)
 	defer span.End()
 
-	users, err := u.Enforcer().GetUsersForRole(role.Marshal(), domain.Marshal())
+	enforcer, err := u.Enforcer()
+	if err != nil {
+		return nil, errors.Wrap(err, "userManager.Enforcer()")
+	}
+
+	users, err := enforcer.GetUsersForRole(role.Marshal(), domain.Marshal())
 	if err != nil {
 		return nil, errors.Wrap(err, "enforcer.GetUsersForRole()")
 	}
 
 	actualUsers := make([]accesstypes.User, 0, len(users))
 	for _, u := range users {
-		if u == accesstypes.NoopUser {
+		if u == accesstypes.NoopUser || isRoleSubject(u) {
 			continue
 		}
 		actualUsers = append(actualUsers, accesstypes.UnmarshalUser(u))
@@ -544,11 +975,29 @@ func (u *userManager) RolePermissions(ctx context.Context, domain accesstypes.Do
 	ctx, span := tracer.Start(ctx)
 	defer span.End()
 
-	if !u.RoleExists(ctx, domain, role) {
+	return u.rolePermissions(ctx, domain, role, effectAllow)
+}
+
+func (u *userManager) RolePermissionDenials(ctx context.Context, domain accesstypes.Domain, role accesstypes.Role) (accesstypes.RolePermissionCollection, error) {
+	ctx, span := tracer.Start(ctx)
+	defer span.End()
+
+	return u.rolePermissions(ctx, domain, role, effectDeny)
+}
+
+func (u *userManager) rolePermissions(ctx context.Context, domain accesstypes.Domain, role accesstypes.Role, effect string) (accesstypes.RolePermissionCollection, error) {
+	if exists, err := u.RoleExists(ctx, domain, role); err != nil {
+		return nil, err
+	} else if !exists {
 		return nil, httpio.NewNotFoundMessagef("role %s doesn't exist", role)
 	}
 
-	policies, err := u.Enforcer().GetFilteredPolicy(0, role.Marshal(), domain.Marshal())
+	enforcer, err := u.Enforcer()
+	if err != nil {
+		return nil, errors.Wrap(err, "userManager.Enforcer()")
+	}
+
+	policies, err := enforcer.GetFilteredPolicy(0, role.Marshal(), domain.Marshal(), "", "", effect)
 	if err != nil {
 		return nil, errors.Wrap(err, "enforcer.GetFilteredPolicy()")
 	}
@@ -561,13 +1010,18 @@ func (u *userManager) RolePermissions(ctx context.Context, domain accesstypes.Do
 	return permissions, nil
 }
 
-func (u *userManager) RoleExists(ctx context.Context, domain accesstypes.Domain, role accesstypes.Role) bool {
+func (u *userManager) RoleExists(ctx context.Context, domain accesstypes.Domain, role accesstypes.Role) (bool, error) {
 	_, span := tracer.Start(ctx)
 	defer span.End()
 
-	roles := u.Enforcer().GetRolesForUserInDomain(accesstypes.NoopUser, domain.Marshal())
+	enforcer, err := u.Enforcer()
+	if err != nil {
+		return false, errors.Wrap(err, "userManager.Enforcer()")
+	}
 
-	return slices.Contains(roles, role.Marshal())
+	roles := enforcer.GetRolesForUserInDomain(accesstypes.NoopUser, domain.Marshal())
+
+	return slices.Contains(roles, role.Marshal()), nil
 }
 
 func (u *userManager) Domains(ctx context.Context) ([]accesstypes.Domain, error) {
@@ -592,18 +1046,23 @@ func (u *userManager) hasUsersAssigned(ctx context.Context, domain accesstypes.D
 	_, span := tracer.Start(ctx)
 	defer span.End()
 
-	users, err := u.Enforcer().GetUsersForRole(role.Marshal(), domain.Marshal())
+	enforcer, err := u.Enforcer()
+	if err != nil {
+		return false, errors.Wrap(err, "userManager.Enforcer()")
+	}
+
+	users, err := enforcer.GetUsersForRole(role.Marshal(), domain.Marshal())
 	if err != nil {
 		return false, errors.Wrap(err, "enforcer.GetUsersForRole()")
 	}
 
-	// We aren't checking the single user to be someone else as it should always be noop if length is 1.
-	// Do we need to throw an error if it is someone other than noop?
-	if len(users) <= 1 {
-		return false, nil
+	for _, user := range users {
+		if user != accesstypes.NoopUser && !isRoleSubject(user) {
+			return true, nil
+		}
 	}
 
-	return true, nil
+	return false, nil
 }
 
 // DomainExists checks if the domain exists in the application.
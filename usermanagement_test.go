@@ -3,12 +3,16 @@ package access
 import (
 	"context"
 	"reflect"
+	"slices"
 	"testing"
 
 	"github.com/casbin/casbin/v2"
+	"github.com/casbin/casbin/v2/model"
+	fileadapter "github.com/casbin/casbin/v2/persist/file-adapter"
 	"github.com/cccteam/ccc/accesstypes"
 	"github.com/go-playground/errors/v5"
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
 	"go.uber.org/mock/gomock"
 )
 
@@ -125,8 +129,8 @@ func Test_userManager_User_Add_Delete(t *testing.T) {
 			}
 			c := &userManager{
 				domains: domains,
-				Enforcer: func() casbin.IEnforcer {
-					return enforcer
+				Enforcer: func() (casbin.IEnforcer, error) {
+					return enforcer, nil
 				},
 			}
 			got, err := c.User(tt.args.ctx, tt.args.username)
@@ -258,8 +262,8 @@ func Test_userManager_Users(t *testing.T) {
 
 			c := &userManager{
 				domains: domains,
-				Enforcer: func() casbin.IEnforcer {
-					return enforcer
+				Enforcer: func() (casbin.IEnforcer, error) {
+					return enforcer, nil
 				},
 			}
 
@@ -324,8 +328,8 @@ func Test_userManager_RolePermissions(t *testing.T) {
 			ctx := context.Background()
 
 			c := &userManager{
-				Enforcer: func() casbin.IEnforcer {
-					return enforcer
+				Enforcer: func() (casbin.IEnforcer, error) {
+					return enforcer, nil
 				},
 			}
 
@@ -387,8 +391,8 @@ func Test_userManager_RoleUsers(t *testing.T) {
 			}
 
 			c := &userManager{
-				Enforcer: func() casbin.IEnforcer {
-					return enforcer
+				Enforcer: func() (casbin.IEnforcer, error) {
+					return enforcer, nil
 				},
 			}
 
@@ -448,8 +452,8 @@ func Test_userManager_DeleteRoleUsers(t *testing.T) {
 			}
 
 			c := &userManager{
-				Enforcer: func() casbin.IEnforcer {
-					return enforcer
+				Enforcer: func() (casbin.IEnforcer, error) {
+					return enforcer, nil
 				},
 			}
 
@@ -552,8 +556,8 @@ func Test_userManager_AddRole(t *testing.T) {
 
 			c := &userManager{
 				domains: domains,
-				Enforcer: func() casbin.IEnforcer {
-					return enforcer
+				Enforcer: func() (casbin.IEnforcer, error) {
+					return enforcer, nil
 				},
 			}
 
@@ -648,8 +652,8 @@ func Test_userManager_AddUserRoles(t *testing.T) {
 
 			u := &userManager{
 				domains: domains,
-				Enforcer: func() casbin.IEnforcer {
-					return enforcer
+				Enforcer: func() (casbin.IEnforcer, error) {
+					return enforcer, nil
 				},
 			}
 
@@ -756,8 +760,8 @@ func Test_userManager_Roles(t *testing.T) {
 
 			c := &userManager{
 				domains: domains,
-				Enforcer: func() casbin.IEnforcer {
-					return enforcer
+				Enforcer: func() (casbin.IEnforcer, error) {
+					return enforcer, nil
 				},
 			}
 
@@ -843,11 +847,13 @@ func Test_userManager_DeleteRole(t *testing.T) {
 		domain accesstypes.Domain
 	}
 	tests := []struct {
-		name      string
-		args      args
-		want      bool
-		wantErr   bool
-		wantExist bool
+		name           string
+		args           args
+		want           bool
+		wantErr        bool
+		wantExist      bool
+		otherDomain    accesstypes.Domain
+		wantOtherExist bool
 	}{
 		{
 			name: "Success",
@@ -865,19 +871,23 @@ func Test_userManager_DeleteRole(t *testing.T) {
 				role:   accesstypes.Role("Writer"),
 				domain: accesstypes.Domain("tenant2"),
 			},
-			want:      true,
-			wantErr:   false,
-			wantExist: false,
+			want:           true,
+			wantErr:        false,
+			wantExist:      false,
+			otherDomain:    accesstypes.Domain("tenant1"),
+			wantOtherExist: true,
 		},
 		{
-			name: "Success when it doesn't exist already",
+			name: "Nothing deleted when it doesn't exist in domain",
 			args: args{
 				role:   accesstypes.Role("Viewer"),
 				domain: accesstypes.Domain("tenant2"),
 			},
-			want:      true,
-			wantErr:   false,
-			wantExist: false,
+			want:           false,
+			wantErr:        false,
+			wantExist:      false,
+			otherDomain:    accesstypes.Domain("tenant1"),
+			wantOtherExist: true,
 		},
 		{
 			name: "Fails when users are assigned",
@@ -900,8 +910,8 @@ func Test_userManager_DeleteRole(t *testing.T) {
 			}
 
 			c := &userManager{
-				Enforcer: func() casbin.IEnforcer {
-					return enforcer
+				Enforcer: func() (casbin.IEnforcer, error) {
+					return enforcer, nil
 				},
 			}
 
@@ -914,10 +924,89 @@ func Test_userManager_DeleteRole(t *testing.T) {
 				t.Errorf("Client.DeleteRole() = %v, want %v", got, tt.want)
 			}
 
-			exists := c.RoleExists(ctx, tt.args.domain, tt.args.role)
+			exists, err := c.RoleExists(ctx, tt.args.domain, tt.args.role)
+			if err != nil {
+				t.Fatalf("Client.RoleExists() error = %v", err)
+			}
 			if exists != tt.wantExist {
 				t.Errorf("Client.roleExists() = %v, want %v", exists, tt.wantExist)
 			}
+
+			if tt.otherDomain != "" {
+				otherExists, err := c.RoleExists(ctx, tt.otherDomain, tt.args.role)
+				if err != nil {
+					t.Fatalf("Client.RoleExists() error = %v", err)
+				}
+				if otherExists != tt.wantOtherExist {
+					t.Errorf("Client.roleExists() in %s = %v, want %v", tt.otherDomain, otherExists, tt.wantOtherExist)
+				}
+			}
+		})
+	}
+}
+
+func Test_userManager_DeleteRoleAllDomains(t *testing.T) {
+	t.Parallel()
+
+	policyPath := "testdata/policy_deleterole.csv"
+
+	tests := []struct {
+		name       string
+		role       accesstypes.Role
+		want       bool
+		wantErr    bool
+		wantExists []accesstypes.Domain
+	}{
+		{
+			name: "Success in every domain",
+			role: accesstypes.Role("Writer"),
+			want: true,
+		},
+		{
+			name:       "Fails when users are assigned in one domain",
+			role:       accesstypes.Role("Editor"),
+			wantErr:    true,
+			wantExists: []accesstypes.Domain{},
+		},
+		{
+			name:       "Fails when users are assigned",
+			role:       accesstypes.Role("Administrator"),
+			wantErr:    true,
+			wantExists: []accesstypes.Domain{"tenant1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			ctx := context.Background()
+			enforcer, err := mockEnforcer(policyPath)
+			if err != nil {
+				t.Fatalf("failed to load policies. err=%s", err)
+			}
+
+			c := &userManager{
+				Enforcer: func() (casbin.IEnforcer, error) {
+					return enforcer, nil
+				},
+			}
+
+			got, err := c.DeleteRoleAllDomains(ctx, tt.role)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Client.DeleteRoleAllDomains() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Client.DeleteRoleAllDomains() = %v, want %v", got, tt.want)
+			}
+
+			for _, domain := range []accesstypes.Domain{"tenant1", "tenant2"} {
+				exists, err := c.RoleExists(ctx, domain, tt.role)
+				if err != nil {
+					t.Fatalf("Client.RoleExists() error = %v", err)
+				}
+				if want := slices.Contains(tt.wantExists, domain); exists != want {
+					t.Errorf("Client.RoleExists() in %s = %v, want %v", domain, exists, want)
+				}
+			}
 		})
 	}
 }
@@ -980,8 +1069,8 @@ func Test_userManager_DeleteRolePermissions(t *testing.T) {
 			}
 
 			c := &userManager{
-				Enforcer: func() casbin.IEnforcer {
-					return enforcer
+				Enforcer: func() (casbin.IEnforcer, error) {
+					return enforcer, nil
 				},
 			}
 
@@ -1057,8 +1146,8 @@ func Test_userManager_DeleteAllRolePermissions(t *testing.T) {
 			}
 
 			c := &userManager{
-				Enforcer: func() casbin.IEnforcer {
-					return enforcer
+				Enforcer: func() (casbin.IEnforcer, error) {
+					return enforcer, nil
 				},
 			}
 
@@ -1077,6 +1166,14 @@ func Test_userManager_DeleteAllRolePermissions(t *testing.T) {
 			if diff := cmp.Diff(tt.want, permsAfter); diff != "" {
 				t.Fatalf("Client.DeleteRolePermissions() mismatch (-want +got):\n%s", diff)
 			}
+
+			denialsAfter, err := c.RolePermissionDenials(ctx, tt.args.domain, tt.args.role)
+			if err != nil {
+				t.Errorf("Client.RolePermissionDenials() error= %v", err)
+			}
+			if diff := cmp.Diff(tt.want, denialsAfter); diff != "" {
+				t.Fatalf("Client.DeleteAllRolePermissions() denials mismatch (-want +got):\n%s", diff)
+			}
 		})
 	}
 }
@@ -1148,8 +1245,8 @@ func Test_userManager_AddRolePermissions(t *testing.T) {
 			}
 
 			c := &userManager{
-				Enforcer: func() casbin.IEnforcer {
-					return enforcer
+				Enforcer: func() (casbin.IEnforcer, error) {
+					return enforcer, nil
 				},
 			}
 
@@ -1314,8 +1411,8 @@ func Test_userManager_AddRoleUsers(t *testing.T) {
 
 			u := &userManager{
 				domains: domains,
-				Enforcer: func() casbin.IEnforcer {
-					return enforcer
+				Enforcer: func() (casbin.IEnforcer, error) {
+					return enforcer, nil
 				},
 			}
 
@@ -1393,8 +1490,8 @@ func Test_userManager_AddRolePermissionResources(t *testing.T) {
 
 			u := &userManager{
 				domains: domains,
-				Enforcer: func() casbin.IEnforcer {
-					return enforcer
+				Enforcer: func() (casbin.IEnforcer, error) {
+					return enforcer, nil
 				},
 			}
 
@@ -1404,3 +1501,572 @@ func Test_userManager_AddRolePermissionResources(t *testing.T) {
 		})
 	}
 }
+
+func Test_userManager_RolePermissionDenials(t *testing.T) {
+	t.Parallel()
+
+	enforcer, err := mockEnforcer("testdata/policy_denials.csv")
+	if err != nil {
+		t.Fatalf("failed to load policies. err=%s", err)
+	}
+
+	type args struct {
+		role   accesstypes.Role
+		domain accesstypes.Domain
+	}
+	tests := []struct {
+		name            string
+		args            args
+		wantPermissions accesstypes.RolePermissionCollection
+		wantDenials     accesstypes.RolePermissionCollection
+		wantErr         bool
+	}{
+		{
+			name:            "reports allows and denials separately",
+			args:            args{role: "Auditor", domain: "tenant1"},
+			wantPermissions: accesstypes.RolePermissionCollection{"ViewUsers": {"global"}},
+			wantDenials:     accesstypes.RolePermissionCollection{"DeleteUsers": {"global"}, "Update": {"Users.name"}},
+		},
+		{
+			name:            "role without denials",
+			args:            args{role: "Editor", domain: "tenant1"},
+			wantPermissions: accesstypes.RolePermissionCollection{"ViewUsers": {"global"}, "DeleteUsers": {"global"}, "Update": {"Users.name"}},
+			wantDenials:     accesstypes.RolePermissionCollection{},
+		},
+		{
+			name:    "Bad role",
+			args:    args{role: "asdvsdb", domain: "tenant1"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			ctx := context.Background()
+
+			c := &userManager{
+				Enforcer: func() (casbin.IEnforcer, error) {
+					return enforcer, nil
+				},
+			}
+
+			gotDenials, err := c.RolePermissionDenials(ctx, tt.args.domain, tt.args.role)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Client.RolePermissionDenials() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if diff := cmp.Diff(tt.wantDenials, gotDenials); diff != "" {
+				t.Errorf("Client.RolePermissionDenials() mismatch (-want +got):\n%s", diff)
+			}
+
+			gotPermissions, err := c.RolePermissions(ctx, tt.args.domain, tt.args.role)
+			if err != nil {
+				t.Fatalf("Client.RolePermissions() error = %v", err)
+			}
+			if diff := cmp.Diff(tt.wantPermissions, gotPermissions); diff != "" {
+				t.Errorf("Client.RolePermissions() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_userManager_AddRolePermissionDenials(t *testing.T) {
+	t.Parallel()
+
+	type args struct {
+		permissions []accesstypes.Permission
+		permission  accesstypes.Permission
+		resources   []accesstypes.Resource
+		role        accesstypes.Role
+		domain      accesstypes.Domain
+	}
+	tests := []struct {
+		name    string
+		args    args
+		wantErr bool
+		want    accesstypes.RolePermissionCollection
+	}{
+		{
+			name: "denies global and resource permissions",
+			args: args{
+				permissions: []accesstypes.Permission{"ViewUsers"},
+				permission:  "Update",
+				resources:   []accesstypes.Resource{"Users.email"},
+				role:        "Editor",
+				domain:      "tenant1",
+			},
+			want: accesstypes.RolePermissionCollection{"ViewUsers": {"global"}, "Update": {"Users.email"}},
+		},
+		{
+			name: "fails due to missing role",
+			args: args{
+				permissions: []accesstypes.Permission{"ViewUsers"},
+				role:        "Viewer",
+				domain:      "tenant1",
+			},
+			wantErr: true,
+		},
+		{
+			name: "fails due to empty permission",
+			args: args{
+				permissions: []accesstypes.Permission{""},
+				role:        "Editor",
+				domain:      "tenant1",
+			},
+			wantErr: true,
+		},
+		{
+			name: "fails due to empty resource",
+			args: args{
+				permission: "Update",
+				resources:  []accesstypes.Resource{""},
+				role:       "Editor",
+				domain:     "tenant1",
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			ctx := context.Background()
+			enforcer, err := mockEnforcer("testdata/policy_denials.csv")
+			if err != nil {
+				t.Fatalf("failed to load policies. err=%s", err)
+			}
+
+			c := &userManager{
+				Enforcer: func() (casbin.IEnforcer, error) {
+					return enforcer, nil
+				},
+			}
+
+			if err := c.AddRolePermissionDenials(ctx, tt.args.domain, tt.args.role, tt.args.permissions...); err != nil {
+				if tt.wantErr {
+					return
+				}
+				t.Fatalf("Client.AddRolePermissionDenials() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if err := c.AddRolePermissionResourceDenials(ctx, tt.args.domain, tt.args.role, tt.args.permission, tt.args.resources...); err != nil {
+				if tt.wantErr {
+					return
+				}
+				t.Fatalf("Client.AddRolePermissionResourceDenials() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				t.Fatalf("expected error, got none")
+			}
+
+			got, err := c.RolePermissionDenials(ctx, tt.args.domain, tt.args.role)
+			if err != nil {
+				t.Fatalf("Client.RolePermissionDenials() error = %v", err)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("Client.AddRolePermissionDenials() mismatch (-want +got):\n%s", diff)
+			}
+
+			allowed, err := enforcer.Enforce(accesstypes.User("bob").Marshal(), accesstypes.Domain("tenant1").Marshal(), accesstypes.GlobalResource.Marshal(), accesstypes.Permission("ViewUsers").Marshal())
+			if err != nil {
+				t.Fatalf("enforcer.Enforce() error = %v", err)
+			}
+			if allowed {
+				t.Errorf("enforcer.Enforce() = %v, want denied", allowed)
+			}
+		})
+	}
+}
+
+func Test_userManager_DeleteRolePermissionDenials(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	enforcer, err := mockEnforcer("testdata/policy_denials.csv")
+	if err != nil {
+		t.Fatalf("failed to load policies. err=%s", err)
+	}
+
+	c := &userManager{
+		Enforcer: func() (casbin.IEnforcer, error) {
+			return enforcer, nil
+		},
+	}
+
+	if err := c.DeleteRolePermissionDenials(ctx, "tenant1", "Auditor", "DeleteUsers"); err != nil {
+		t.Fatalf("Client.DeleteRolePermissionDenials() error = %v", err)
+	}
+	if err := c.DeleteRolePermissionResourceDenials(ctx, "tenant1", "Auditor", "Update", "Users.name"); err != nil {
+		t.Fatalf("Client.DeleteRolePermissionResourceDenials() error = %v", err)
+	}
+
+	got, err := c.RolePermissionDenials(ctx, "tenant1", "Auditor")
+	if err != nil {
+		t.Fatalf("Client.RolePermissionDenials() error = %v", err)
+	}
+	if diff := cmp.Diff(accesstypes.RolePermissionCollection{}, got); diff != "" {
+		t.Errorf("Client.DeleteRolePermissionDenials() mismatch (-want +got):\n%s", diff)
+	}
+
+	// removing a denial must not remove an allow for the same permission
+	if err := c.DeleteRolePermissions(ctx, "tenant1", "Auditor", "ViewUsers"); err != nil {
+		t.Fatalf("Client.DeleteRolePermissions() error = %v", err)
+	}
+	if err := c.AddRolePermissionDenials(ctx, "tenant1", "Editor", "ViewUsers"); err != nil {
+		t.Fatalf("Client.AddRolePermissionDenials() error = %v", err)
+	}
+	if err := c.DeleteRolePermissionDenials(ctx, "tenant1", "Editor", "ViewUsers"); err != nil {
+		t.Fatalf("Client.DeleteRolePermissionDenials() error = %v", err)
+	}
+
+	perms, err := c.RolePermissions(ctx, "tenant1", "Editor")
+	if err != nil {
+		t.Fatalf("Client.RolePermissions() error = %v", err)
+	}
+	if !slices.Contains(perms["ViewUsers"], accesstypes.GlobalResource) {
+		t.Errorf("Client.DeleteRolePermissionDenials() removed allowed permission, got %v", perms)
+	}
+}
+
+func Test_userManager_UserPermissions_Denials(t *testing.T) {
+	t.Parallel()
+
+	enforcer, err := mockEnforcer("testdata/policy_denials.csv")
+	if err != nil {
+		t.Fatalf("failed to load policies. err=%s", err)
+	}
+
+	tests := []struct {
+		name string
+		user accesstypes.User
+		want accesstypes.UserPermissionCollection
+	}{
+		{
+			name: "denials remove permissions granted by other roles",
+			user: "charlie",
+			want: accesstypes.UserPermissionCollection{"tenant1": {"global": {"ViewUsers"}}},
+		},
+		{
+			name: "user without denials",
+			user: "bob",
+			want: accesstypes.UserPermissionCollection{"tenant1": {"global": {"ViewUsers", "DeleteUsers"}, "Users.name": {"Update"}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			c := &userManager{
+				Enforcer: func() (casbin.IEnforcer, error) {
+					return enforcer, nil
+				},
+			}
+
+			got, err := c.UserPermissions(context.Background(), tt.user, "tenant1")
+			if err != nil {
+				t.Fatalf("Client.UserPermissions() error = %v", err)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("Client.UserPermissions() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_userManager_AddRoleParents(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		role    accesstypes.Role
+		parents []accesstypes.Role
+		want    []accesstypes.Role
+		wantErr bool
+	}{
+		{
+			name:    "Success",
+			role:    "Auditor",
+			parents: []accesstypes.Role{"Viewer", "Intern"},
+			want:    []accesstypes.Role{"Intern", "Viewer"},
+		},
+		{
+			name:    "Fails when parent inherits role",
+			role:    "Viewer",
+			parents: []accesstypes.Role{"Manager"},
+			want:    []accesstypes.Role{},
+			wantErr: true,
+		},
+		{
+			name:    "Fails when role inherits itself",
+			role:    "Viewer",
+			parents: []accesstypes.Role{"Viewer"},
+			want:    []accesstypes.Role{},
+			wantErr: true,
+		},
+		{
+			name:    "Fails when parent doesn't exist",
+			role:    "Auditor",
+			parents: []accesstypes.Role{"Missing"},
+			want:    []accesstypes.Role{},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			ctx := context.Background()
+			enforcer, err := mockEnforcer("testdata/policy_inheritance.csv")
+			if err != nil {
+				t.Fatalf("failed to load policies. err=%s", err)
+			}
+
+			c := &userManager{
+				Enforcer: func() (casbin.IEnforcer, error) {
+					return enforcer, nil
+				},
+			}
+
+			if err := c.AddRoleParents(ctx, "tenant1", tt.role, tt.parents...); (err != nil) != tt.wantErr {
+				t.Fatalf("Client.AddRoleParents() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			got, err := c.RoleParents(ctx, "tenant1", tt.role)
+			if err != nil {
+				t.Fatalf("Client.RoleParents() error = %v", err)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("Client.RoleParents() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_userManager_DeleteRoleParents(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	enforcer, err := mockEnforcer("testdata/policy_inheritance.csv")
+	if err != nil {
+		t.Fatalf("failed to load policies. err=%s", err)
+	}
+
+	c := &userManager{
+		Enforcer: func() (casbin.IEnforcer, error) {
+			return enforcer, nil
+		},
+	}
+
+	if err := c.DeleteRoleParents(ctx, "tenant1", "Editor", "Viewer"); err != nil {
+		t.Fatalf("Client.DeleteRoleParents() error = %v", err)
+	}
+
+	parents, err := c.RoleParents(ctx, "tenant1", "Editor")
+	if err != nil {
+		t.Fatalf("Client.RoleParents() error = %v", err)
+	}
+	if len(parents) != 0 {
+		t.Errorf("Client.RoleParents() = %v, want none", parents)
+	}
+
+	ok, err := enforcer.Enforce("user:alice", "domain:tenant1", "resource:global", "perm:ViewUsers")
+	if err != nil {
+		t.Fatalf("enforcer.Enforce() error = %v", err)
+	}
+	if ok {
+		t.Errorf("enforcer.Enforce() = true, want false after the parent is removed")
+	}
+
+	if err := c.DeleteRoleParents(ctx, "tenant1", "Missing", "Viewer"); err == nil {
+		t.Errorf("Client.DeleteRoleParents() error = nil, want error for missing role")
+	}
+}
+
+func Test_userManager_RoleInheritance(t *testing.T) {
+	t.Parallel()
+
+	enforcer, err := mockEnforcer("testdata/policy_inheritance.csv")
+	if err != nil {
+		t.Fatalf("failed to load policies. err=%s", err)
+	}
+
+	tests := []struct {
+		name            string
+		user            accesstypes.User
+		wantPermissions accesstypes.UserPermissionCollection
+		wantRoles       accesstypes.RoleCollection
+	}{
+		{
+			name:            "permissions are inherited through every level",
+			user:            "alice",
+			wantPermissions: accesstypes.UserPermissionCollection{"tenant1": {"global": {"DeleteUsers", "ViewUsers"}, "Users.name": {"Update"}}},
+			wantRoles:       accesstypes.RoleCollection{"tenant1": {"Manager"}},
+		},
+		{
+			name:            "denials remove inherited permissions",
+			user:            "bob",
+			wantPermissions: accesstypes.UserPermissionCollection{"tenant1": {"global": {"ViewUsers"}}},
+			wantRoles:       accesstypes.RoleCollection{"tenant1": {"Intern"}},
+		},
+		{
+			name:            "parent roles don't inherit from children",
+			user:            "carol",
+			wantPermissions: accesstypes.UserPermissionCollection{"tenant1": {"global": {"ViewUsers"}}},
+			wantRoles:       accesstypes.RoleCollection{"tenant1": {"Viewer"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			c := &userManager{
+				Enforcer: func() (casbin.IEnforcer, error) {
+					return enforcer, nil
+				},
+			}
+
+			user, err := c.User(context.Background(), tt.user, "tenant1")
+			if err != nil {
+				t.Fatalf("Client.User() error = %v", err)
+			}
+			if diff := cmp.Diff(tt.wantPermissions, user.Permissions, cmpopts.SortSlices(func(a, b accesstypes.Permission) bool { return a < b })); diff != "" {
+				t.Errorf("Client.User() permissions mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.wantRoles, user.Roles); diff != "" {
+				t.Errorf("Client.User() roles mismatch (-want +got):\n%s", diff)
+			}
+
+			for resource, permissions := range tt.wantPermissions["tenant1"] {
+				for _, permission := range permissions {
+					ok, err := enforcer.Enforce(tt.user.Marshal(), accesstypes.Domain("tenant1").Marshal(), resource.Marshal(), permission.Marshal())
+					if err != nil {
+						t.Fatalf("enforcer.Enforce() error = %v", err)
+					}
+					if !ok {
+						t.Errorf("enforcer.Enforce(%s, %s) = false, want true", resource, permission)
+					}
+				}
+			}
+		})
+	}
+
+	c := &userManager{
+		Enforcer: func() (casbin.IEnforcer, error) {
+			return enforcer, nil
+		},
+	}
+
+	users, err := c.RoleUsers(context.Background(), "tenant1", "Viewer")
+	if err != nil {
+		t.Fatalf("Client.RoleUsers() error = %v", err)
+	}
+	if diff := cmp.Diff([]accesstypes.User{"carol"}, users); diff != "" {
+		t.Errorf("Client.RoleUsers() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func Test_userManager_GlobalDomainGrants(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name            string
+		model           string
+		enabled         bool
+		user            accesstypes.User
+		wantRoles       accesstypes.RoleCollection
+		wantPermissions accesstypes.UserPermissionCollection
+	}{
+		{
+			name:    "global roles apply in every domain",
+			model:   globalDomainGrantsModel(),
+			enabled: true,
+			user:    "alice",
+			wantRoles: accesstypes.RoleCollection{
+				accesstypes.GlobalDomain: {"Administrator"},
+				"tenant1":                {"Viewer", "Administrator"},
+				"tenant2":                {"Restricted", "Administrator"},
+			},
+			wantPermissions: accesstypes.UserPermissionCollection{
+				accesstypes.GlobalDomain: {"Settings": {"Read"}},
+				"tenant1":                {"Documents": {"Read", "Delete"}},
+				"tenant2":                {},
+			},
+		},
+		{
+			name:    "users without global roles are unchanged",
+			model:   globalDomainGrantsModel(),
+			enabled: true,
+			user:    "bob",
+			wantRoles: accesstypes.RoleCollection{
+				accesstypes.GlobalDomain: {},
+				"tenant1":                {"Viewer"},
+				"tenant2":                {},
+			},
+			wantPermissions: accesstypes.UserPermissionCollection{
+				accesstypes.GlobalDomain: {},
+				"tenant1":                {"Documents": {"Read"}},
+				"tenant2":                {},
+			},
+		},
+		{
+			name:  "global roles apply only in the global domain by default",
+			model: rbacModel(),
+			user:  "alice",
+			wantRoles: accesstypes.RoleCollection{
+				accesstypes.GlobalDomain: {"Administrator"},
+				"tenant1":                {"Viewer"},
+				"tenant2":                {"Restricted"},
+			},
+			wantPermissions: accesstypes.UserPermissionCollection{
+				accesstypes.GlobalDomain: {"Settings": {"Read"}},
+				"tenant1":                {"Documents": {"Read"}},
+				"tenant2":                {},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			m, err := model.NewModelFromString(tt.model)
+			if err != nil {
+				t.Fatalf("model.NewModelFromString() error = %v", err)
+			}
+			enforcer, err := casbin.NewSyncedEnforcer(m, fileadapter.NewAdapter("testdata/policy_global_grants.csv"))
+			if err != nil {
+				t.Fatalf("casbin.NewSyncedEnforcer() error = %v", err)
+			}
+
+			c := &userManager{
+				globalDomainGrants: tt.enabled,
+				Enforcer: func() (casbin.IEnforcer, error) {
+					return enforcer, nil
+				},
+			}
+
+			domains := []accesstypes.Domain{accesstypes.GlobalDomain, "tenant1", "tenant2"}
+			user, err := c.User(context.Background(), tt.user, domains...)
+			if err != nil {
+				t.Fatalf("Client.User() error = %v", err)
+			}
+			if diff := cmp.Diff(tt.wantRoles, user.Roles, cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("Client.User() roles mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.wantPermissions, user.Permissions, cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("Client.User() permissions mismatch (-want +got):\n%s", diff)
+			}
+
+			// enforcement agrees with the reported permissions
+			for _, domain := range domains {
+				ok, err := enforcer.Enforce(tt.user.Marshal(), domain.Marshal(), accesstypes.Resource("Documents").Marshal(), accesstypes.Permission("Delete").Marshal())
+				if err != nil {
+					t.Fatalf("enforcer.Enforce() error = %v", err)
+				}
+				if want := slices.Contains(tt.wantPermissions[domain]["Documents"], "Delete"); ok != want {
+					t.Errorf("enforcer.Enforce() in %s = %v, want %v", domain, ok, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,290 @@
+package access
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/casbin/casbin/v2/persist"
+	"github.com/go-playground/errors/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const (
+	watcherTimeout         = 10 * time.Second
+	watcherMinReconnectGap = time.Second
+	watcherMaxReconnectGap = time.Minute
+)
+
+var _ persist.Watcher = &PostgresWatcher{}
+
+// watcherConn is the part of *pgx.Conn used by PostgresWatcher.
+type watcherConn interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+	WaitForNotification(ctx context.Context) (*pgconn.Notification, error)
+	Close(ctx context.Context) error
+}
+
+// PostgresWatcher is a casbin watcher that uses PostgreSQL LISTEN/NOTIFY to tell other instances the policy changed.
+// Notifications sent by a watcher are not delivered to its own callback.
+type PostgresWatcher struct {
+	connect         func(ctx context.Context) (watcherConn, error)
+	channel         string
+	id              string
+	minReconnectGap time.Duration
+	logger          *slog.Logger
+
+	callbackMu sync.RWMutex
+	callback   func(string)
+
+	// pending holds a notification waiting to be sent, so updates made while one is sent are coalesced
+	pending    chan struct{}
+	notifyConn watcherConn
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// WatcherOption configures NewPostgresWatcher.
+type WatcherOption func(w *PostgresWatcher)
+
+// WithWatcherLogger sets the logger that records notifications that fail to send. Defaults to discarding log output,
+// which a nil logger keeps.
+func WithWatcherLogger(logger *slog.Logger) WatcherOption {
+	return func(w *PostgresWatcher) {
+		if logger != nil {
+			w.logger = logger
+		}
+	}
+}
+
+// NewPostgresWatcher connects to PostgreSQL and listens for policy changes on channel. Errors if the listening
+// connection cannot be established. The connection is reestablished in the background if it is lost.
+func NewPostgresWatcher(ctx context.Context, connConfig *pgx.ConnConfig, channel string, opts ...WatcherOption) (*PostgresWatcher, error) {
+	connect := func(ctx context.Context) (watcherConn, error) {
+		conn, err := pgx.ConnectConfig(ctx, connConfig)
+		if err != nil {
+			return nil, errors.Wrap(err, "pgx.ConnectConfig()")
+		}
+
+		return conn, nil
+	}
+
+	return newPostgresWatcher(ctx, connect, channel, watcherMinReconnectGap, opts...)
+}
+
+func newPostgresWatcher(
+	ctx context.Context, connect func(ctx context.Context) (watcherConn, error), channel string, minReconnectGap time.Duration,
+	opts ...WatcherOption,
+) (*PostgresWatcher, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, errors.Wrap(err, "rand.Read()")
+	}
+
+	w := &PostgresWatcher{
+		connect:         connect,
+		channel:         channel,
+		id:              hex.EncodeToString(id),
+		minReconnectGap: minReconnectGap,
+		logger:          slog.New(slog.DiscardHandler),
+		pending:         make(chan struct{}, 1),
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+
+	conn, err := w.listenConn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	runCtx, cancel := context.WithCancel(context.Background())
+	w.cancel = cancel
+
+	w.wg.Add(2)
+	go w.listen(runCtx, conn)
+	go w.send(runCtx)
+
+	return w, nil
+}
+
+// SetUpdateCallback sets the function called when another instance changes the policy.
+func (w *PostgresWatcher) SetUpdateCallback(callback func(string)) error {
+	w.callbackMu.Lock()
+	defer w.callbackMu.Unlock()
+
+	w.callback = callback
+
+	return nil
+}
+
+// Update notifies other instances that the policy changed. casbin calls it while holding the enforcer lock, so the
+// notification is sent in the background and retried until it succeeds. Failures are logged with the logger set by
+// WithWatcherLogger.
+func (w *PostgresWatcher) Update() error {
+	select {
+	case w.pending <- struct{}{}:
+	default:
+		// a notification is already waiting and covers this change
+	}
+
+	return nil
+}
+
+// Close stops listening for policy changes and closes the watcher's connections. A notification still waiting to be
+// sent is tried once more.
+func (w *PostgresWatcher) Close() {
+	w.cancel()
+	w.wg.Wait()
+
+	ctx, cancel := context.WithTimeout(context.Background(), watcherTimeout)
+	defer cancel()
+
+	select {
+	case <-w.pending:
+		if err := w.publish(ctx); err != nil {
+			w.logger.Error("failed to send casbin policy change notification", "channel", w.channel, "error", err)
+		}
+	default:
+	}
+
+	if w.notifyConn != nil {
+		_ = w.notifyConn.Close(ctx)
+		w.notifyConn = nil
+	}
+}
+
+// listenConn opens a connection that listens on the watcher's channel.
+func (w *PostgresWatcher) listenConn(ctx context.Context) (watcherConn, error) {
+	conn, err := w.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{w.channel}.Sanitize()); err != nil {
+		_ = conn.Close(ctx)
+
+		return nil, errors.Wrap(err, "pgx.Conn.Exec()")
+	}
+
+	return conn, nil
+}
+
+// listen delivers notifications from other instances to the callback until ctx is canceled.
+func (w *PostgresWatcher) listen(ctx context.Context, conn watcherConn) {
+	defer w.wg.Done()
+
+	for {
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			_ = conn.Close(context.Background())
+
+			if conn = w.reconnect(ctx); conn == nil {
+				return
+			}
+
+			// notifications sent while disconnected were lost, so assume the policy changed
+			w.notify("")
+
+			continue
+		}
+
+		if n.Payload == w.id {
+			continue
+		}
+
+		w.notify(n.Payload)
+	}
+}
+
+// reconnect retries the listening connection with exponential backoff. Returns nil if ctx is canceled.
+func (w *PostgresWatcher) reconnect(ctx context.Context) watcherConn {
+	for gap := w.minReconnectGap; ; gap = min(gap*2, watcherMaxReconnectGap) {
+		if !sleep(ctx, gap) {
+			return nil
+		}
+
+		connCtx, cancel := context.WithTimeout(ctx, watcherTimeout)
+		conn, err := w.listenConn(connCtx)
+		cancel()
+		if err == nil {
+			return conn
+		}
+	}
+}
+
+// send publishes the pending notifications until ctx is canceled, retrying failures with exponential backoff.
+func (w *PostgresWatcher) send(ctx context.Context) {
+	defer w.wg.Done()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-w.pending:
+		}
+
+		for gap := w.minReconnectGap; ; gap = min(gap*2, watcherMaxReconnectGap) {
+			publishCtx, cancel := context.WithTimeout(ctx, watcherTimeout)
+			err := w.publish(publishCtx)
+			cancel()
+			if err == nil {
+				break
+			}
+
+			w.logger.Error("failed to send casbin policy change notification, retrying",
+				"channel", w.channel, "retryIn", gap, "error", err)
+			if !sleep(ctx, gap) {
+				// leave the notification for Close
+				_ = w.Update()
+
+				return
+			}
+		}
+	}
+}
+
+// publish sends a notification carrying the watcher's id, connecting first if needed.
+func (w *PostgresWatcher) publish(ctx context.Context) error {
+	if w.notifyConn == nil {
+		conn, err := w.connect(ctx)
+		if err != nil {
+			return err
+		}
+		w.notifyConn = conn
+	}
+
+	if _, err := w.notifyConn.Exec(ctx, "SELECT pg_notify($1, $2)", w.channel, w.id); err != nil {
+		_ = w.notifyConn.Close(ctx)
+		w.notifyConn = nil
+
+		return errors.Wrap(err, "pgx.Conn.Exec()")
+	}
+
+	return nil
+}
+
+func (w *PostgresWatcher) notify(payload string) {
+	w.callbackMu.RLock()
+	callback := w.callback
+	w.callbackMu.RUnlock()
+
+	if callback != nil {
+		callback(payload)
+	}
+}
+
+// sleep waits for d. Returns false if ctx is canceled first.
+func sleep(ctx context.Context, d time.Duration) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
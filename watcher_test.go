@@ -0,0 +1,220 @@
+package access
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-playground/errors/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// fakePostgres delivers the notifications sent with pg_notify to the connections listening on its channel.
+type fakePostgres struct {
+	mu         sync.Mutex
+	listeners  []*fakeWatcherConn
+	connectErr error
+	notifyErr  error
+}
+
+func (p *fakePostgres) connect(context.Context) (watcherConn, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.connectErr != nil {
+		return nil, p.connectErr
+	}
+
+	return &fakeWatcherConn{
+		postgres:      p,
+		notifications: make(chan *pgconn.Notification, 10),
+		broken:        make(chan struct{}),
+	}, nil
+}
+
+func (p *fakePostgres) set(connectErr, notifyErr error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.connectErr = connectErr
+	p.notifyErr = notifyErr
+}
+
+// breakListeners drops the listening connections.
+func (p *fakePostgres) breakListeners() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, conn := range p.listeners {
+		close(conn.broken)
+	}
+	p.listeners = nil
+}
+
+type fakeWatcherConn struct {
+	postgres      *fakePostgres
+	notifications chan *pgconn.Notification
+	broken        chan struct{}
+}
+
+func (c *fakeWatcherConn) Exec(_ context.Context, sql string, arguments ...any) (pgconn.CommandTag, error) {
+	p := c.postgres
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if sql == `LISTEN "casbin_policy"` {
+		p.listeners = append(p.listeners, c)
+
+		return pgconn.NewCommandTag("LISTEN"), nil
+	}
+
+	if p.notifyErr != nil {
+		return pgconn.CommandTag{}, p.notifyErr
+	}
+	payload, _ := arguments[1].(string)
+	for _, listener := range p.listeners {
+		listener.notifications <- &pgconn.Notification{Channel: "casbin_policy", Payload: payload}
+	}
+
+	return pgconn.NewCommandTag("SELECT 1"), nil
+}
+
+func (c *fakeWatcherConn) WaitForNotification(ctx context.Context) (*pgconn.Notification, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-c.broken:
+		return nil, errors.New("connection reset")
+	case n := <-c.notifications:
+		return n, nil
+	}
+}
+
+func (c *fakeWatcherConn) Close(context.Context) error { return nil }
+
+// newFakePostgresWatcher returns a watcher on postgres whose callback sends the payloads it receives on a channel.
+func newFakePostgresWatcher(t *testing.T, postgres *fakePostgres, opts ...WatcherOption) (*PostgresWatcher, chan string) {
+	t.Helper()
+
+	w, err := newPostgresWatcher(context.Background(), postgres.connect, "casbin_policy", time.Millisecond, opts...)
+	if err != nil {
+		t.Fatalf("newPostgresWatcher() error = %v", err)
+	}
+	t.Cleanup(w.Close)
+
+	payloads := make(chan string, 10)
+	if err := w.SetUpdateCallback(func(payload string) { payloads <- payload }); err != nil {
+		t.Fatalf("PostgresWatcher.SetUpdateCallback() error = %v", err)
+	}
+
+	return w, payloads
+}
+
+// logBuffer collects the output of a logger that writes from the watcher's goroutines.
+type logBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *logBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.Write(p)
+}
+
+func (b *logBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.String()
+}
+
+func receive(t *testing.T, payloads chan string) string {
+	t.Helper()
+
+	select {
+	case payload := <-payloads:
+		return payload
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the watcher callback")
+
+		return ""
+	}
+}
+
+func TestPostgresWatcher_Update(t *testing.T) {
+	t.Parallel()
+
+	postgres := &fakePostgres{}
+	w1, payloads1 := newFakePostgresWatcher(t, postgres)
+	w2, payloads2 := newFakePostgresWatcher(t, postgres)
+
+	if err := w1.Update(); err != nil {
+		t.Fatalf("PostgresWatcher.Update() error = %v", err)
+	}
+	if got := receive(t, payloads2); got != w1.id {
+		t.Errorf("callback payload = %q, want the id of the sender %q", got, w1.id)
+	}
+
+	// notifications are delivered in order, so the first one w1 receives shows it skipped its own
+	if err := w2.Update(); err != nil {
+		t.Fatalf("PostgresWatcher.Update() error = %v", err)
+	}
+	if got := receive(t, payloads1); got != w2.id {
+		t.Errorf("callback payload = %q, want the id of the sender %q", got, w2.id)
+	}
+}
+
+func TestPostgresWatcher_Update_retry(t *testing.T) {
+	t.Parallel()
+
+	postgres := &fakePostgres{}
+	logs := &logBuffer{}
+	w1, _ := newFakePostgresWatcher(t, postgres, WithWatcherLogger(slog.New(slog.NewTextHandler(logs, nil))))
+	_, payloads2 := newFakePostgresWatcher(t, postgres)
+
+	// Update doesn't wait for the notification, which is sent once the database is reachable again
+	postgres.set(errors.New("connection refused"), errors.New("connection reset"))
+	if err := w1.Update(); err != nil {
+		t.Fatalf("PostgresWatcher.Update() error = %v", err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	postgres.set(nil, nil)
+
+	if got := receive(t, payloads2); got != w1.id {
+		t.Errorf("callback payload = %q, want the id of the sender %q", got, w1.id)
+	}
+	if got := logs.String(); !strings.Contains(got, "failed to send casbin policy change notification, retrying") {
+		t.Errorf("watcher logs = %q, want the failed notification", got)
+	}
+}
+
+func TestPostgresWatcher_reconnect(t *testing.T) {
+	t.Parallel()
+
+	postgres := &fakePostgres{}
+	_, payloads1 := newFakePostgresWatcher(t, postgres)
+	w2, _ := newFakePostgresWatcher(t, postgres)
+
+	postgres.set(errors.New("connection refused"), nil)
+	postgres.breakListeners()
+	time.Sleep(10 * time.Millisecond)
+	postgres.set(nil, nil)
+
+	// changes missed while disconnected are reported without a payload
+	if got := receive(t, payloads1); got != "" {
+		t.Errorf("callback payload after reconnecting = %q, want empty", got)
+	}
+
+	if err := w2.Update(); err != nil {
+		t.Fatalf("PostgresWatcher.Update() error = %v", err)
+	}
+	if got := receive(t, payloads1); got != w2.id {
+		t.Errorf("callback payload = %q, want the id of the sender %q", got, w2.id)
+	}
+}